@@ -5,20 +5,33 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
+	"syscall"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+
 	"github.com/qrave1/RoomSpeak/internal/application/config"
 	"github.com/qrave1/RoomSpeak/internal/application/constant"
+	"github.com/qrave1/RoomSpeak/internal/application/metrics"
+	"github.com/qrave1/RoomSpeak/internal/application/tracing"
+	"github.com/qrave1/RoomSpeak/internal/domain/runtime"
 	"github.com/qrave1/RoomSpeak/internal/infra/adapters/memory"
 	"github.com/qrave1/RoomSpeak/internal/infra/adapters/postgres"
 	"github.com/qrave1/RoomSpeak/internal/infra/adapters/postgres/repository"
+	"github.com/qrave1/RoomSpeak/internal/infra/adapters/redis"
+	"github.com/qrave1/RoomSpeak/internal/infra/adapters/relay"
+	"github.com/qrave1/RoomSpeak/internal/infra/adapters/rtc"
+	"github.com/qrave1/RoomSpeak/internal/infra/adapters/storage"
+	"github.com/qrave1/RoomSpeak/internal/infra/adapters/turn"
 	"github.com/qrave1/RoomSpeak/internal/infra/ports/http/handlers"
+	"github.com/qrave1/RoomSpeak/internal/infra/ports/http/middleware"
 	"github.com/qrave1/RoomSpeak/internal/infra/ports/http/server"
 	"github.com/qrave1/RoomSpeak/internal/usecase"
 )
 
 func runApp() {
-	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
 	slog.SetDefault(
@@ -36,6 +49,20 @@ func runApp() {
 		os.Exit(1)
 	}
 
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
+	if err != nil {
+		slog.Error("setup tracing", slog.Any(constant.Error, err))
+		os.Exit(1)
+	}
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := shutdownTracing(flushCtx); err != nil {
+			slog.Error("shutdown tracing", slog.Any(constant.Error, err))
+		}
+	}()
+
 	// TODO DI
 	dbConn, err := postgres.NewPostgres(ctx, cfg.Postgres.DSN())
 	if err != nil {
@@ -44,33 +71,175 @@ func runApp() {
 	}
 	defer dbConn.Close()
 
+	prometheus.MustRegister(collectors.NewDBStatsCollector(dbConn.DB, "roomspeak"))
+
 	userRepo := repository.NewUserRepo(dbConn)
 	channelRepo := repository.NewChannelRepo(dbConn)
-	wsConnRepo := memory.NewWSConnectionRepository()
+	apiTokenRepo := repository.NewAPITokenRepo(dbConn)
+	transactor := repository.NewTransactor(dbConn)
+	avatarStorage, err := storage.New(cfg.Storage)
+	if err != nil {
+		slog.Error("create avatar storage", slog.Any(constant.Error, err))
+		os.Exit(1)
+	}
+
+	selfNode := runtime.Node{ID: cfg.Cluster.NodeID, PublicURL: cfg.Cluster.NodePublicURL}
+	if cfg.Cascade.Enabled {
+		selfNode.RelayAddr = cfg.Cascade.PublicAddr
+	}
+
+	var (
+		wsConnRepo      memory.WebsocketConnectionRepository
+		activeUserRepo  memory.ActiveUserRepository
+		presenceRepo    memory.PresenceRepository
+		channelNodeRepo memory.ChannelNodeRepository
+		pinnedNodeRepo  memory.ChannelNodeRepository
+		relayRepo       memory.ChannelRelayRepository
+		waitingRepo     memory.WaitingRoomRepository
+		passwordRepo    memory.PasswordAttemptRepository
+		relayTransport  = relay.NewDisabledTransport()
+	)
+
+	if cfg.TURNServer.Enabled {
+		turnServer, err := turn.NewServer(cfg.TURNServer)
+		if err != nil {
+			slog.Error("start embedded turn server", slog.Any(constant.Error, err))
+			os.Exit(1)
+		}
+		defer turnServer.Close()
+	}
+
+	webrtcAPI, closeWebrtcAPI, err := rtc.NewAPI(cfg.WebRTC)
+	if err != nil {
+		slog.Error("create webrtc api", slog.Any(constant.Error, err))
+		os.Exit(1)
+	}
+	defer closeWebrtcAPI()
+
 	pcConnRepo := memory.NewPeerConnectionRepository()
-	activeUserRepo := memory.NewActiveUserRepository()
 
-	userUsecase := usecase.NewUserUsecase([]byte(cfg.JWTSecret), userRepo, channelRepo, wsConnRepo)
+	prometheus.MustRegister(metrics.NewChannelPeersCollector(func() map[string]int {
+		counts := make(map[string]int)
+		for _, peer := range pcConnRepo.GetAll() {
+			counts[peer.ChannelID.String()]++
+		}
+
+		return counts
+	}))
+
+	// localWSRepo - соединения этой ноды, в redis режиме оборачивается маршрутизацией
+	localWSRepo := memory.NewWSConnectionRepository()
+
+	switch cfg.Cluster.StateBackend {
+	case config.StateBackendRedis:
+		redisClient, err := redis.NewRedis(ctx, cfg.Cluster.RedisURL)
+		if err != nil {
+			slog.Error("connect to redis", slog.Any(constant.Error, err))
+			os.Exit(1)
+		}
+		defer redisClient.Close()
+
+		routedWSRepo := redis.NewWebsocketConnectionRepository(redisClient, selfNode.ID, localWSRepo)
+
+		go redis.RunNodeHeartbeat(ctx, redisClient, selfNode)
+		go routedWSRepo.Run(ctx)
+
+		wsConnRepo = routedWSRepo
+		activeUserRepo = redis.NewActiveUserRepository(redisClient)
+		presenceRepo = redis.NewPresenceRepository(redisClient)
+		waitingRepo = redis.NewWaitingRoomRepository(redisClient)
+		passwordRepo = redis.NewPasswordAttemptRepository(redisClient)
+
+		if cfg.Cascade.Enabled {
+			// Канал не закрепляется за нодой, RTP пересылается между нодами канала
+			relayTransport, err = relay.NewUDPTransport(cfg.Cascade.ListenAddr, cfg.Cascade.Secret)
+			if err != nil {
+				slog.Error("create relay transport", slog.Any(constant.Error, err))
+				os.Exit(1)
+			}
+
+			redisRelayRepo := redis.NewChannelRelayRepository(redisClient, selfNode)
+
+			go relayTransport.Run(ctx)
+			go redisRelayRepo.Run(ctx)
+
+			// ptt каналы не каскадируются: слово в них раздает одна нода
+			redisPinnedNodeRepo := redis.NewChannelNodeRepository(redisClient, selfNode)
+
+			go redisPinnedNodeRepo.Run(ctx)
+
+			channelNodeRepo = memory.NewChannelNodeRepository(selfNode)
+			pinnedNodeRepo = redisPinnedNodeRepo
+			relayRepo = redisRelayRepo
+		} else {
+			redisChannelNodeRepo := redis.NewChannelNodeRepository(redisClient, selfNode)
+
+			go redisChannelNodeRepo.Run(ctx)
+
+			channelNodeRepo = redisChannelNodeRepo
+			pinnedNodeRepo = redisChannelNodeRepo
+			relayRepo = memory.NewChannelRelayRepository()
+		}
+	default:
+		wsConnRepo = localWSRepo
+		activeUserRepo = memory.NewActiveUserRepository()
+		presenceRepo = memory.NewPresenceRepository()
+		waitingRepo = memory.NewWaitingRoomRepository()
+		passwordRepo = memory.NewPasswordAttemptRepository()
+		channelNodeRepo = memory.NewChannelNodeRepository(selfNode)
+		pinnedNodeRepo = channelNodeRepo
+		relayRepo = memory.NewChannelRelayRepository()
+	}
+
+	presenceUsecase := usecase.NewPresenceUsecase(cfg.PresenceIdleTimeout, presenceRepo, wsConnRepo)
+	userUsecase := usecase.NewUserUsecase([]byte(cfg.JWTSecret), cfg.Cookie.TTL, userRepo, channelRepo, wsConnRepo, avatarStorage, presenceUsecase)
+	profileUsecase := usecase.NewProfileUsecase(cfg.Storage.AvatarMaxSize, userRepo, avatarStorage)
+	tokenUsecase := usecase.NewTokenUsecase(userRepo, apiTokenRepo)
 	channelUsecase := usecase.NewChannelUsecase(channelRepo, activeUserRepo)
-	peerUsecase := usecase.NewPeerUsecase(cfg, pcConnRepo, wsConnRepo, activeUserRepo)
-	signalingUsecase := usecase.NewSignalingUsecase(channelRepo, userRepo, pcConnRepo, wsConnRepo, activeUserRepo, peerUsecase)
+	qualityUsecase := usecase.NewQualityUsecase(cfg.QualityReportInterval, channelRepo, pcConnRepo, wsConnRepo)
+	floorUsecase := usecase.NewFloorUsecase(channelRepo, pcConnRepo, wsConnRepo)
+	peerUsecase := usecase.NewPeerUsecase(cfg, webrtcAPI, pcConnRepo, wsConnRepo, activeUserRepo, relayRepo, relayTransport)
+	signalingUsecase := usecase.NewTracedSignalingUsecase(
+		usecase.NewSignalingUsecase(channelRepo, userRepo, pcConnRepo, wsConnRepo, activeUserRepo, channelNodeRepo, pinnedNodeRepo, relayRepo, waitingRepo, passwordRepo, avatarStorage, peerUsecase, presenceUsecase, qualityUsecase, floorUsecase),
+	)
 
-	authHandler := handlers.NewAuthHandler(userUsecase)
+	sessionCookies := middleware.NewSessionCookies(cfg)
+
+	accountUsecase := usecase.NewAccountUsecase(userRepo, channelRepo, apiTokenRepo, transactor, wsConnRepo, activeUserRepo, avatarStorage, signalingUsecase)
+
+	authHandler := handlers.NewAuthHandler(userUsecase, sessionCookies)
+	tokenHandler := handlers.NewTokenHandler(tokenUsecase)
+	profileHandler := handlers.NewProfileHandler(profileUsecase)
+	accountHandler := handlers.NewAccountHandler(accountUsecase, sessionCookies)
 	channelHandler := handlers.NewChannelHandler(channelUsecase, userRepo)
 	iceHandler := handlers.NewIceHandler(cfg)
-	wsHandler := handlers.NewWebSocketHandler(cfg, signalingUsecase, wsConnRepo)
+	qualityHandler := handlers.NewQualityHandler(qualityUsecase)
+	wsHandler := handlers.NewWebSocketHandler(cfg, signalingUsecase, presenceUsecase, floorUsecase, wsConnRepo)
+
+	healthHandler := handlers.NewHealthHandler(cfg, dbConn, pcConnRepo, localWSRepo, activeUserRepo, signalingUsecase)
 
-	echoSrv := server.New(cfg, authHandler, channelHandler, iceHandler, wsHandler)
+	echoSrv := server.New(cfg, sessionCookies, authHandler, tokenHandler, profileHandler, accountHandler, tokenUsecase, channelHandler, qualityHandler, iceHandler, wsHandler, healthHandler)
 
-	srvCh := make(chan error, 1)
+	go presenceUsecase.Run(ctx)
+	go qualityUsecase.Run(ctx)
+	go signalingUsecase.RunBreakoutCleanup(ctx)
+	go signalingUsecase.RunStageSync(ctx)
+	go signalingUsecase.RunAdmissions(ctx)
+
+	metricsSrv := server.NewMetrics(cfg)
+
+	srvCh := make(chan error, 2)
 	go func() {
 		srvCh <- echoSrv.Start(":" + cfg.Port)
 	}()
+	go func() {
+		srvCh <- metricsSrv.ListenAndServe()
+	}()
 
 	select {
 	case <-ctx.Done():
 		slog.Info("Shutting down server due to context cancel")
-	case <-srvCh:
+	case err := <-srvCh:
 		slog.Error(
 			"HTTP server failed",
 			slog.Any(constant.Error, err),
@@ -79,10 +248,41 @@ func runApp() {
 		os.Exit(1)
 	}
 
-	timeoutCtx, timeoutCancel := context.WithTimeout(ctx, 5*time.Second)
-	defer timeoutCancel()
+	// ctx уже отменен, остановка идет на отдельном контексте
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), cfg.Shutdown.DrainTimeout)
+	defer drainCancel()
 
-	if err := echoSrv.Shutdown(timeoutCtx); err != nil {
+	signalingUsecase.Drain(drainCtx, cfg.Shutdown.ReconnectAfter)
+
+	// Хайджекнутые WebSocket соединения Shutdown не закрывает, закрываем сами
+	// и ждем, пока обработчики допишут и выйдут
+	localWSRepo.CloseAll()
+	waitWebsocketsClosed(drainCtx, localWSRepo)
+
+	if err := echoSrv.Shutdown(drainCtx); err != nil {
 		slog.Error("Failed to gracefully shutdown server", slog.Any(constant.Error, err))
 	}
+
+	if err := metricsSrv.Shutdown(drainCtx); err != nil {
+		slog.Error("Failed to shutdown metrics server", slog.Any(constant.Error, err))
+	}
+
+	slog.Info("Server stopped")
+}
+
+func waitWebsocketsClosed(ctx context.Context, wsRepo memory.WebsocketConnectionRepository) {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for len(wsRepo.GetAllConnected()) > 0 {
+		select {
+		case <-ctx.Done():
+			// Клиенты, не ответившие на close frame, отключаются принудительно
+			slog.Warn("drain timeout, closing websocket connections left", slog.Int("count", len(wsRepo.GetAllConnected())))
+			wsRepo.ForceCloseAll()
+
+			return
+		case <-ticker.C:
+		}
+	}
 }
@@ -2,9 +2,14 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/caarlos0/env/v11"
-	"github.com/pion/webrtc/v4"
+	"github.com/google/uuid"
 )
 
 type Config struct {
@@ -13,11 +18,172 @@ type Config struct {
 	Domain    string `env:"DOMAIN" envDefault:"http://localhost:3000"`
 	JWTSecret string `env:"JWT_SECRET,required"`
 
-	TurnUDPServer webrtc.ICEServer
-	TurnTCPServer webrtc.ICEServer
+	// AdminUserIDs - пользователи с доступом к диагностике (/debug/state)
+	AdminUserIDs []uuid.UUID `env:"ADMIN_USER_IDS" envSeparator:","`
 
+	// PresenceIdleTimeout - через сколько без активности пользователь становится away
+	PresenceIdleTimeout time.Duration `env:"PRESENCE_IDLE_TIMEOUT" envDefault:"5m"`
+
+	// QualityReportInterval - как часто замерять соединения и рассылать connection_quality
+	QualityReportInterval time.Duration `env:"QUALITY_REPORT_INTERVAL" envDefault:"5s"`
+
+	ICE          ICEConfig
 	CoturnServer CoturnConfig
+	TURNServer   TURNServerConfig
 	Postgres     PostgresConfig
+	Cookie       CookieConfig
+	Storage      StorageConfig
+	Cluster      ClusterConfig
+	Cascade      CascadeConfig
+	Metrics      MetricsConfig
+	Tracing      TracingConfig
+	Shutdown     ShutdownConfig
+	WebRTC       WebRTCConfig
+}
+
+// WebRTCConfig - настройки ICE для всех PeerConnection сервера
+type WebRTCConfig struct {
+	// UDPPortMin, UDPPortMax - диапазон портов для ICE кандидатов, 0 - любые порты
+	UDPPortMin uint16 `env:"WEBRTC_UDP_PORT_MIN" envDefault:"0"`
+	UDPPortMax uint16 `env:"WEBRTC_UDP_PORT_MAX" envDefault:"0"`
+
+	// UDPMuxPort - весь ICE трафик через один UDP порт, 0 - выключено
+	UDPMuxPort int `env:"WEBRTC_UDP_MUX_PORT" envDefault:"0"`
+
+	// TCPMuxPort - ICE-TCP через один TCP порт, 0 - выключено
+	TCPMuxPort int `env:"WEBRTC_TCP_MUX_PORT" envDefault:"0"`
+
+	// NAT1To1IPs - публичные IP ноды за NAT, подставляются в host кандидаты
+	NAT1To1IPs []string `env:"WEBRTC_NAT_1TO1_IPS" envSeparator:","`
+
+	// Interfaces - сетевые интерфейсы для сбора кандидатов, пусто - все.
+	// ExcludeInterfaces отбрасывает интерфейсы по префиксу имени (docker, veth)
+	Interfaces        []string `env:"WEBRTC_INTERFACES" envSeparator:","`
+	ExcludeInterfaces []string `env:"WEBRTC_EXCLUDE_INTERFACES" envSeparator:","`
+
+	ICEDisconnectedTimeout time.Duration `env:"WEBRTC_ICE_DISCONNECTED_TIMEOUT" envDefault:"5s"`
+	ICEFailedTimeout       time.Duration `env:"WEBRTC_ICE_FAILED_TIMEOUT" envDefault:"25s"`
+	ICEKeepaliveInterval   time.Duration `env:"WEBRTC_ICE_KEEPALIVE_INTERVAL" envDefault:"2s"`
+
+	// NACKBufferSize - сколько последних пакетов каждого потока хранится для повторов, степень двойки
+	NACKBufferSize uint16 `env:"WEBRTC_NACK_BUFFER_SIZE" envDefault:"512"`
+
+	// BWE* - границы оценки полосы до получателя в бит/с
+	BWEInitialBitrate int `env:"WEBRTC_BWE_INITIAL_BITRATE" envDefault:"1000000"`
+	BWEMinBitrate     int `env:"WEBRTC_BWE_MIN_BITRATE" envDefault:"100000"`
+	BWEMaxBitrate     int `env:"WEBRTC_BWE_MAX_BITRATE" envDefault:"5000000"`
+}
+
+// MetricsConfig - Prometheus метрики. Отдаются на отдельном адресе,
+// который не публикуется наружу вместе с API
+type MetricsConfig struct {
+	ListenAddr string `env:"METRICS_LISTEN_ADDR" envDefault:":9090"`
+}
+
+// ShutdownConfig - остановка ноды с выводом звонков
+type ShutdownConfig struct {
+	// DrainTimeout - сколько ждать закрытия соединений клиентов и текущих HTTP запросов.
+	// Не закрывшиеся за это время WebSocket соединения закрываются принудительно
+	DrainTimeout time.Duration `env:"SHUTDOWN_DRAIN_TIMEOUT" envDefault:"15s"`
+
+	// ReconnectAfter - базовая задержка переподключения в событии server_shutdown
+	ReconnectAfter time.Duration `env:"SHUTDOWN_RECONNECT_AFTER" envDefault:"3s"`
+}
+
+const (
+	TracingExporterNone = "none"
+	TracingExporterOTLP = "otlp"
+)
+
+// TracingConfig - OpenTelemetry трейсинг. По умолчанию спаны никуда не отправляются
+type TracingConfig struct {
+	// Exporter - none или otlp (OTLP/HTTP)
+	Exporter string `env:"TRACING_EXPORTER" envDefault:"none"`
+
+	// Endpoint - host:port OTLP коллектора
+	Endpoint string `env:"TRACING_OTLP_ENDPOINT" envDefault:"localhost:4318"`
+	Insecure bool   `env:"TRACING_OTLP_INSECURE" envDefault:"true"`
+
+	// SampleRatio - доля сэмплируемых трейсов от 0 до 1, решение родителя учитывается
+	SampleRatio float64 `env:"TRACING_SAMPLE_RATIO" envDefault:"1"`
+	ServiceName string  `env:"TRACING_SERVICE_NAME" envDefault:"roomspeak"`
+}
+
+// CascadeConfig - каскадирование SFU: участники одного канала на разных нодах,
+// RTP пересылается между нодами по UDP. Требует STATE_BACKEND=redis
+type CascadeConfig struct {
+	Enabled bool `env:"CASCADE_ENABLED" envDefault:"false"`
+
+	// ListenAddr - UDP адрес, на котором нода принимает RTP от других нод
+	ListenAddr string `env:"CASCADE_LISTEN_ADDR" envDefault:":5004"`
+
+	// PublicAddr - host:port, по которому другие ноды отправляют RTP этой ноде
+	PublicAddr string `env:"CASCADE_PUBLIC_ADDR"`
+
+	// Secret - общий секрет нод для подписи пакетов
+	Secret string `env:"CASCADE_SECRET"`
+}
+
+const (
+	StateBackendMemory = "memory"
+	StateBackendRedis  = "redis"
+)
+
+// ClusterConfig - общее состояние для запуска нескольких нод RoomSpeak
+type ClusterConfig struct {
+	// StateBackend - memory для одной ноды или redis для нескольких
+	StateBackend string `env:"STATE_BACKEND" envDefault:"memory"`
+	RedisURL     string `env:"REDIS_URL" envDefault:"redis://localhost:6379/0"`
+
+	// NodeID - уникальное имя ноды, по умолчанию hostname
+	NodeID string `env:"NODE_ID"`
+
+	// NodePublicURL - адрес, по которому клиенты могут подключиться именно к этой ноде.
+	// Туда перенаправляются участники канала, закрепленного за нодой
+	NodePublicURL string `env:"NODE_PUBLIC_URL"`
+}
+
+// CookieConfig - настройки cookie с JWT сессией
+type CookieConfig struct {
+	Name string `env:"COOKIE_NAME" envDefault:"jwt"`
+
+	// Domain - "auto" вычисляет зарегистрированный домен из DOMAIN с учетом public suffix list
+	// (app.example.co.uk -> .example.co.uk), пустое значение - cookie только для текущего хоста,
+	// любое другое значение используется как есть
+	Domain string `env:"COOKIE_DOMAIN" envDefault:"auto"`
+
+	// Secure - "auto" включает Secure, если DOMAIN начинается с https://
+	Secure string `env:"COOKIE_SECURE" envDefault:"auto"`
+
+	// SameSite - lax, strict, none или auto (none для Secure cookie, иначе lax)
+	SameSite string `env:"COOKIE_SAMESITE" envDefault:"auto"`
+
+	// TTL - время жизни cookie и JWT токена
+	TTL time.Duration `env:"COOKIE_TTL" envDefault:"72h"`
+}
+
+const (
+	StorageBackendLocal = "local"
+	StorageBackendS3    = "s3"
+)
+
+// StorageConfig - хранилище загружаемых файлов (аватары)
+type StorageConfig struct {
+	Backend string `env:"STORAGE_BACKEND" envDefault:"local"`
+
+	// PublicURL - префикс ссылок на файлы (CDN, публичный бакет).
+	// По умолчанию для local файлы раздает сам сервер по /uploads, для s3 - endpoint/bucket
+	PublicURL string `env:"STORAGE_PUBLIC_URL"`
+	LocalDir  string `env:"STORAGE_LOCAL_DIR" envDefault:"data/uploads"`
+
+	S3Endpoint  string `env:"STORAGE_S3_ENDPOINT"`
+	S3Region    string `env:"STORAGE_S3_REGION"`
+	S3Bucket    string `env:"STORAGE_S3_BUCKET"`
+	S3AccessKey string `env:"STORAGE_S3_ACCESS_KEY"`
+	S3SecretKey string `env:"STORAGE_S3_SECRET_KEY"`
+	S3UseSSL    bool   `env:"STORAGE_S3_USE_SSL" envDefault:"true"`
+
+	AvatarMaxSize int64 `env:"AVATAR_MAX_SIZE" envDefault:"2097152"`
 }
 
 type PostgresConfig struct {
@@ -46,13 +212,102 @@ func (p *PostgresConfig) DSN() string {
 	)
 }
 
+// CoturnConfig - внешний coturn, обязателен без встроенного TURN сервера
 type CoturnConfig struct {
-	Host     string `env:"COTURN_HOST,required"`
-	Username string `env:"COTURN_USERNAME,required"`
-	Password string `env:"COTURN_PASSWORD,required"`
+	Host string `env:"COTURN_HOST"`
 
 	// Secret - нужен для генерации временных кредов для фронта
-	Secret string `env:"COTURN_SECRET,required"`
+	Secret string `env:"COTURN_SECRET"`
+}
+
+// TURNServerConfig - встроенный TURN/STUN сервер вместо отдельного coturn
+type TURNServerConfig struct {
+	Enabled bool `env:"TURN_ENABLED" envDefault:"false"`
+
+	// ListenAddr - адрес для UDP и TCP
+	ListenAddr string `env:"TURN_LISTEN_ADDR" envDefault:"0.0.0.0:3478"`
+
+	// PublicIP - адрес ноды, который клиенты получают в TURN адресах и relay кандидатах
+	PublicIP string `env:"TURN_PUBLIC_IP"`
+	Realm    string `env:"TURN_REALM" envDefault:"roomspeak"`
+
+	// Secret - общий секрет для временных кредов, как static-auth-secret в coturn
+	Secret string `env:"TURN_SECRET"`
+
+	RelayPortMin uint16 `env:"TURN_RELAY_PORT_MIN" envDefault:"49152"`
+	RelayPortMax uint16 `env:"TURN_RELAY_PORT_MAX" envDefault:"65535"`
+}
+
+func (t *TURNServerConfig) validate() error {
+	if net.ParseIP(t.PublicIP) == nil {
+		return fmt.Errorf("TURN_PUBLIC_IP must be an ip address, got %q", t.PublicIP)
+	}
+
+	if t.Secret == "" {
+		return fmt.Errorf("TURN_SECRET is required with TURN_ENABLED")
+	}
+
+	if t.RelayPortMin == 0 || t.RelayPortMin > t.RelayPortMax {
+		return fmt.Errorf("invalid TURN relay port range %d-%d", t.RelayPortMin, t.RelayPortMax)
+	}
+
+	if _, _, err := net.SplitHostPort(t.ListenAddr); err != nil {
+		return fmt.Errorf("invalid TURN_LISTEN_ADDR: %w", err)
+	}
+
+	return nil
+}
+
+// defaultTURNHost - host:port для TURN адресов, если ICE_TURN_URLS не задан
+func (c *Config) defaultTURNHost() (string, error) {
+	if c.TURNServer.Enabled {
+		_, port, _ := net.SplitHostPort(c.TURNServer.ListenAddr)
+
+		return net.JoinHostPort(c.TURNServer.PublicIP, port), nil
+	}
+
+	host := c.CoturnServer.Host
+	if host == "" || strings.Contains(host, "://") || strings.ContainsAny(host, " ?") {
+		return "", fmt.Errorf("invalid COTURN_HOST %q", host)
+	}
+
+	return host, nil
+}
+
+// TURNSecret - секрет временных TURN кредов встроенного сервера или coturn
+func (c *Config) TURNSecret() string {
+	if c.TURNServer.Enabled {
+		return c.TURNServer.Secret
+	}
+
+	return c.CoturnServer.Secret
+}
+
+// CheckTURN проверяет, что из конфига можно выдать клиентам рабочие TURN сервера
+func (c *Config) CheckTURN() error {
+	if c.TURNSecret() == "" {
+		return fmt.Errorf("empty turn secret")
+	}
+
+	if len(c.ICE.TURNURLs) == 0 {
+		return fmt.Errorf("no turn servers configured")
+	}
+
+	return nil
+}
+
+// IsAdmin - есть ли у пользователя доступ к диагностике
+// CookieSecure - выставлять ли Secure у cookie сессии с учетом COOKIE_SECURE=auto
+func (c *Config) CookieSecure() bool {
+	if c.Cookie.Secure == "auto" {
+		return strings.HasPrefix(c.Domain, "https://")
+	}
+
+	return c.Cookie.Secure == "true"
+}
+
+func (c *Config) IsAdmin(userID uuid.UUID) bool {
+	return slices.Contains(c.AdminUserIDs, userID)
 }
 
 func New() (*Config, error) {
@@ -61,16 +316,89 @@ func New() (*Config, error) {
 		return nil, fmt.Errorf("parse env: %w", err)
 	}
 
-	c.TurnUDPServer = webrtc.ICEServer{
-		URLs:       []string{fmt.Sprintf("turn:%s?transport=udp", c.CoturnServer.Host)},
-		Username:   c.CoturnServer.Username,
-		Credential: c.CoturnServer.Password,
+	if c.Cluster.NodeID == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("get hostname for node id: %w", err)
+		}
+
+		c.Cluster.NodeID = hostname
+	}
+
+	c.Cookie.Secure = strings.ToLower(c.Cookie.Secure)
+	if !slices.Contains([]string{"auto", "true", "false"}, c.Cookie.Secure) {
+		return nil, fmt.Errorf("COOKIE_SECURE must be auto, true or false")
+	}
+
+	c.Cookie.SameSite = strings.ToLower(c.Cookie.SameSite)
+	if c.Cookie.SameSite == "" {
+		c.Cookie.SameSite = "auto"
+	}
+
+	if !slices.Contains([]string{"auto", "lax", "strict", "none"}, c.Cookie.SameSite) {
+		return nil, fmt.Errorf("COOKIE_SAMESITE must be auto, lax, strict or none")
+	}
+
+	// Браузеры отбрасывают SameSite=None cookie без Secure, сессия бы молча не сохранялась
+	if c.Cookie.SameSite == "none" && !c.CookieSecure() {
+		return nil, fmt.Errorf("COOKIE_SAMESITE=none requires a Secure cookie: set COOKIE_SECURE=true or an https DOMAIN")
+	}
+
+	if c.Cascade.Enabled {
+		if c.Cluster.StateBackend != StateBackendRedis {
+			return nil, fmt.Errorf("cascade requires STATE_BACKEND=%s", StateBackendRedis)
+		}
+
+		if c.Cascade.PublicAddr == "" || c.Cascade.Secret == "" {
+			return nil, fmt.Errorf("cascade requires CASCADE_PUBLIC_ADDR and CASCADE_SECRET")
+		}
+	}
+
+	if c.WebRTC.UDPPortMin > c.WebRTC.UDPPortMax {
+		return nil, fmt.Errorf("WEBRTC_UDP_PORT_MIN is greater than WEBRTC_UDP_PORT_MAX")
+	}
+
+	if size := c.WebRTC.NACKBufferSize; size == 0 || size&(size-1) != 0 {
+		return nil, fmt.Errorf("WEBRTC_NACK_BUFFER_SIZE must be a power of two")
+	}
+
+	if c.WebRTC.BWEMinBitrate > c.WebRTC.BWEInitialBitrate || c.WebRTC.BWEInitialBitrate > c.WebRTC.BWEMaxBitrate {
+		return nil, fmt.Errorf("WEBRTC_BWE_INITIAL_BITRATE must be between WEBRTC_BWE_MIN_BITRATE and WEBRTC_BWE_MAX_BITRATE")
+	}
+
+	switch c.Tracing.Exporter {
+	case TracingExporterNone, TracingExporterOTLP:
+	default:
+		return nil, fmt.Errorf("unknown TRACING_EXPORTER %q", c.Tracing.Exporter)
 	}
 
-	c.TurnTCPServer = webrtc.ICEServer{
-		URLs:       []string{fmt.Sprintf("turn:%s?transport=tcp", c.CoturnServer.Host)},
-		Username:   c.CoturnServer.Username,
-		Credential: c.CoturnServer.Password,
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		return nil, fmt.Errorf("TRACING_SAMPLE_RATIO must be between 0 and 1")
+	}
+
+	if c.TURNServer.Enabled {
+		if err = c.TURNServer.validate(); err != nil {
+			return nil, err
+		}
+	} else if c.CoturnServer.Secret == "" {
+		return nil, fmt.Errorf("COTURN_SECRET is required without TURN_ENABLED")
+	}
+
+	// Кред с нулевым сроком истекает сразу, TURN его отклонит
+	if c.ICE.CredentialTTL <= 0 {
+		return nil, fmt.Errorf("ICE_CREDENTIAL_TTL must be positive")
+	}
+
+	if len(c.ICE.TURNURLs) == 0 {
+		host, err := c.defaultTURNHost()
+		if err != nil {
+			return nil, err
+		}
+
+		c.ICE.TURNURLs = []ICEURL{
+			{URL: fmt.Sprintf("turn:%s?transport=udp", host)},
+			{URL: fmt.Sprintf("turn:%s?transport=tcp", host)},
+		}
 	}
 
 	return &c, nil
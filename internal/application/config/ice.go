@@ -0,0 +1,105 @@
+package config
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+const publicSTUNURL = "stun:stun.l.google.com:19302"
+
+// ICEConfig - STUN/TURN сервера для клиентов и серверных PeerConnection
+type ICEConfig struct {
+	// PublicSTUN - добавлять публичный STUN Google, выключается для закрытых контуров
+	PublicSTUN bool     `env:"ICE_PUBLIC_STUN" envDefault:"true"`
+	STUNURLs   []ICEURL `env:"ICE_STUN_URLS" envSeparator:","`
+
+	// TURNURLs - turn: и turns: адреса. По умолчанию udp и tcp на COTURN_HOST
+	TURNURLs []ICEURL `env:"ICE_TURN_URLS" envSeparator:","`
+
+	// CredentialTTL - время жизни временных TURN кредов
+	CredentialTTL time.Duration `env:"ICE_CREDENTIAL_TTL" envDefault:"1h"`
+}
+
+// ICEURL - адрес STUN/TURN сервера. Приоритет задается суффиксом ;priority=N,
+// сервера с большим приоритетом отдаются первыми
+type ICEURL struct {
+	URL      string
+	Priority int
+}
+
+func (u *ICEURL) UnmarshalText(text []byte) error {
+	raw, priority, hasPriority := strings.Cut(strings.TrimSpace(string(text)), ";priority=")
+
+	if !strings.HasPrefix(raw, "stun:") && !strings.HasPrefix(raw, "stuns:") &&
+		!strings.HasPrefix(raw, "turn:") && !strings.HasPrefix(raw, "turns:") {
+		return fmt.Errorf("unsupported ice url %q", raw)
+	}
+
+	u.URL = raw
+	u.Priority = 0
+
+	if hasPriority {
+		p, err := strconv.Atoi(priority)
+		if err != nil {
+			return fmt.Errorf("parse priority of %q: %w", raw, err)
+		}
+
+		u.Priority = p
+	}
+
+	return nil
+}
+
+// ICEServers возвращает STUN и TURN сервера в порядке приоритета, TURN - с переданными кредами
+func (c ICEConfig) ICEServers(username, credential string) []webrtc.ICEServer {
+	urls := slices.Clone(c.STUNURLs)
+	if c.PublicSTUN {
+		urls = append(urls, ICEURL{URL: publicSTUNURL})
+	}
+	urls = append(urls, c.TURNURLs...)
+
+	slices.SortStableFunc(urls, func(a, b ICEURL) int {
+		return cmp.Compare(b.Priority, a.Priority)
+	})
+
+	servers := make([]webrtc.ICEServer, 0, len(urls))
+
+	for _, u := range urls {
+		server := webrtc.ICEServer{URLs: []string{u.URL}}
+
+		if isTURN(u.URL) {
+			server.Username = username
+			server.Credential = credential
+		}
+
+		servers = append(servers, server)
+	}
+
+	return servers
+}
+
+// TURN возвращает только TURN адреса в порядке приоритета
+func (c ICEConfig) TURN() []string {
+	urls := slices.Clone(c.TURNURLs)
+
+	slices.SortStableFunc(urls, func(a, b ICEURL) int {
+		return cmp.Compare(b.Priority, a.Priority)
+	})
+
+	result := make([]string, 0, len(urls))
+	for _, u := range urls {
+		result = append(result, u.URL)
+	}
+
+	return result
+}
+
+func isTURN(url string) bool {
+	return strings.HasPrefix(url, "turn:") || strings.HasPrefix(url, "turns:")
+}
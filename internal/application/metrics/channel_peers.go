@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// channelPeersLimit - сколько самых больших каналов ноды получают свой ряд channel_peers.
+// Метка channel_id не ограничена, поэтому ряды есть только у верхушки
+const channelPeersLimit = 20
+
+var channelPeersDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "", "channel_peers"),
+	"Number of WebRTC peers on this node in the largest channels.",
+	[]string{"channel_id"},
+	nil,
+)
+
+// channelPeersCollector считает пиров по каналам в момент сбора метрик
+type channelPeersCollector struct {
+	counts func() map[string]int
+}
+
+// NewChannelPeersCollector - gauge channel_peers для channelPeersLimit самых больших каналов.
+// counts возвращает число пиров по channel_id
+func NewChannelPeersCollector(counts func() map[string]int) prometheus.Collector {
+	return &channelPeersCollector{counts: counts}
+}
+
+func (c *channelPeersCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- channelPeersDesc
+}
+
+func (c *channelPeersCollector) Collect(ch chan<- prometheus.Metric) {
+	type channelPeers struct {
+		channelID string
+		peers     int
+	}
+
+	counts := c.counts()
+
+	channels := make([]channelPeers, 0, len(counts))
+	for channelID, peers := range counts {
+		channels = append(channels, channelPeers{channelID: channelID, peers: peers})
+	}
+
+	// При равенстве порядок по channel_id, чтобы состав верхушки не прыгал между сборами
+	slices.SortFunc(channels, func(a, b channelPeers) int {
+		return cmp.Or(cmp.Compare(b.peers, a.peers), cmp.Compare(a.channelID, b.channelID))
+	})
+
+	for _, channel := range channels[:min(len(channels), channelPeersLimit)] {
+		ch <- prometheus.MustNewConstMetric(channelPeersDesc, prometheus.GaugeValue, float64(channel.peers), channel.channelID)
+	}
+}
@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "roomspeak"
+
+var (
+	WebsocketConnections = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "websocket_connections",
+		Help:      "Number of WebSocket connections on this node.",
+	})
+
+	WebsocketWriteErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "websocket_write_errors_total",
+		Help:      "Failed writes to WebSocket connections.",
+	})
+
+	// Без метки channel_id: число каналов не ограничено, а каждая метка - отдельный ряд.
+	// Пиры по каналам отдает channel_peers, только для самых больших каналов
+	Peers = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "peers",
+		Help:      "Number of WebRTC peers on this node.",
+	})
+
+	ActiveChannels = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_channels",
+		Help:      "Number of channels with at least one WebRTC peer on this node.",
+	})
+
+	RTPPacketsForwarded = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rtp_packets_forwarded_total",
+		Help:      "RTP packets written to receivers.",
+	})
+
+	RTPBytesForwarded = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rtp_bytes_forwarded_total",
+		Help:      "RTP payload bytes written to receivers.",
+	})
+
+	RTPPacketsDropped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rtp_packets_dropped_total",
+		Help:      "RTP packets that were not delivered to a receiver, by reason.",
+	}, []string{"reason"})
+
+	RTCPPacketsReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rtcp_packets_received_total",
+		Help:      "RTCP feedback packets received from receivers, by type.",
+	}, []string{"type"})
+
+	ICEConnectionStates = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ice_connection_state_changes_total",
+		Help:      "ICE connection state transitions of server peers.",
+	}, []string{"state"})
+
+	SignalingMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "signaling_messages_total",
+		Help:      "Signaling messages received over WebSocket, by type and result.",
+	}, []string{"type", "result"})
+
+	SignalingDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "signaling_handle_duration_seconds",
+		Help:      "Time spent handling a signaling message.",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 14),
+	}, []string{"type"})
+
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method and route.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+)
+
+// Причины потери RTP пакетов
+const (
+	DropReasonWriteError = "write_error"
+	DropReasonRelayError = "relay_error"
+	// DropReasonCodecMismatch - получатель договорился о другом аудио кодеке
+	DropReasonCodecMismatch = "codec_mismatch"
+	// DropReasonListenOnly - слушатель stage канала или участник ptt канала без слова
+	DropReasonListenOnly = "listen_only"
+)
+
+// Типы RTCP пакетов от получателей
+const (
+	RTCPTypeNACK            = "nack"
+	RTCPTypeKeyframeRequest = "keyframe_request"
+	RTCPTypeTWCC            = "twcc"
+	RTCPTypeREMB            = "remb"
+	RTCPTypeReceiverReport  = "receiver_report"
+	RTCPTypeOther           = "other"
+)
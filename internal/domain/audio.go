@@ -0,0 +1,90 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/pion/sdp/v3"
+	"github.com/pion/webrtc/v4"
+
+	"github.com/qrave1/RoomSpeak/internal/domain/models"
+)
+
+var ErrNoAudioCodec = errors.New("no supported audio codec in offer")
+
+// Статические payload type аудио кодеков, совпадают с пионовскими по умолчанию
+const (
+	OpusPayloadType = 111
+	G722PayloadType = 9
+	PCMUPayloadType = 0
+)
+
+// OpusCodec - Opus с параметрами профиля канала
+func OpusCodec(profile models.AudioProfile) webrtc.RTPCodecCapability {
+	return webrtc.RTPCodecCapability{
+		MimeType:    webrtc.MimeTypeOpus,
+		ClockRate:   48000,
+		Channels:    2,
+		SDPFmtpLine: profile.OpusFmtp(),
+	}
+}
+
+// LegacyAudioCodecs - кодеки для клиентов без Opus в порядке предпочтения
+func LegacyAudioCodecs() []webrtc.RTPCodecParameters {
+	return []webrtc.RTPCodecParameters{
+		{
+			RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeG722, ClockRate: 8000},
+			PayloadType:        G722PayloadType,
+		},
+		{
+			RTPCodecCapability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypePCMU, ClockRate: 8000},
+			PayloadType:        PCMUPayloadType,
+		},
+	}
+}
+
+// AudioCodecForOffer выбирает кодек звука, который сервер отправляет клиенту: Opus,
+// а при разрешенных в канале legacy кодеках - первый из них, который есть в offer
+func AudioCodecForOffer(offer string, profile models.AudioProfile) (webrtc.RTPCodecCapability, error) {
+	var desc sdp.SessionDescription
+	if err := desc.UnmarshalString(offer); err != nil {
+		return webrtc.RTPCodecCapability{}, err
+	}
+
+	offered := make(map[string]bool)
+	for _, media := range desc.MediaDescriptions {
+		if media.MediaName.Media != "audio" {
+			continue
+		}
+
+		for _, attr := range media.Attributes {
+			if attr.Key != "rtpmap" {
+				continue
+			}
+
+			// rtpmap: "<payload type> <кодек>/<частота>[/<каналы>]"
+			_, codec, ok := strings.Cut(attr.Value, " ")
+			if !ok {
+				continue
+			}
+
+			name, _, _ := strings.Cut(codec, "/")
+			offered[strings.ToLower("audio/"+name)] = true
+		}
+	}
+
+	// Клиент без аудио секции получает Opus, как раньше
+	if len(offered) == 0 || offered[strings.ToLower(webrtc.MimeTypeOpus)] {
+		return OpusCodec(profile), nil
+	}
+
+	if profile.LegacyCodecs {
+		for _, codec := range LegacyAudioCodecs() {
+			if offered[strings.ToLower(codec.MimeType)] {
+				return codec.RTPCodecCapability, nil
+			}
+		}
+	}
+
+	return webrtc.RTPCodecCapability{}, ErrNoAudioCodec
+}
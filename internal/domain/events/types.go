@@ -2,6 +2,7 @@ package events
 
 import (
 	"encoding/json"
+	"time"
 
 	"github.com/pion/webrtc/v4"
 )
@@ -15,6 +16,8 @@ type Message struct {
 // JoinEvent - событие при подключении нового участника в комнату
 type JoinEvent struct {
 	ChannelID string `json:"channel_id"`
+	// Password - пароль публичного канала, если создатель его задал
+	Password string `json:"password,omitempty"`
 }
 
 // SdpEvent - события связанные с SDP (offer, answer, ice)
@@ -29,10 +32,15 @@ type IceCandidateEvent struct {
 
 // ParticipantInfo - информация об участнике
 type ParticipantInfo struct {
-	ID       string `json:"id"`
-	Username string `json:"username"`
-	IsMuted  bool   `json:"is_muted"`
-	IsOnline bool   `json:"is_online"`
+	ID          string `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	AvatarURL   string `json:"avatar_url"`
+	IsMuted     bool   `json:"is_muted"`
+	IsOnline    bool   `json:"is_online"`
+	// Role - speaker или listener в stage канале
+	Role       string `json:"role,omitempty"`
+	HandRaised bool   `json:"hand_raised"`
 }
 
 // ParticipantListDetailedEvent - событие с детальной информацией об участниках
@@ -45,3 +53,212 @@ type UserActionEvent struct {
 	UserName string `json:"user_name"`
 	IsMuted  bool   `json:"is_muted"`
 }
+
+// PingEvent - heartbeat клиента. IdleSeconds - сколько секунд пользователь ничего не делал
+type PingEvent struct {
+	IdleSeconds int `json:"idle_seconds"`
+}
+
+// SetStatusEvent - ручная смена статуса присутствия
+type SetStatusEvent struct {
+	Status string `json:"status"`
+}
+
+// SetCustomStatusEvent - пользовательский текст статуса, ExpiresIn в секундах (0 - бессрочно)
+type SetCustomStatusEvent struct {
+	Text      string `json:"text"`
+	ExpiresIn int    `json:"expires_in"`
+}
+
+// PresenceUpdatedEvent - изменение присутствия пользователя
+type PresenceUpdatedEvent struct {
+	UserID       string     `json:"user_id"`
+	Status       string     `json:"status"`
+	CustomStatus string     `json:"custom_status"`
+	ExpiresAt    *time.Time `json:"custom_status_expires_at,omitempty"`
+}
+
+// TrackMetaEvent - клиент сообщает, что показывает его видео трек (camera или screen)
+type TrackMetaEvent struct {
+	TrackID string `json:"track_id"`
+	Source  string `json:"source"`
+}
+
+// TrackPublishedEvent - в канале появился видео трек. Клиент находит его по StreamID
+// из ontrack и раскладывает по OwnerID и Source
+type TrackPublishedEvent struct {
+	TrackID  string `json:"track_id"`
+	StreamID string `json:"stream_id"`
+	OwnerID  string `json:"owner_id"`
+	Source   string `json:"source"`
+	MimeType string `json:"mime_type"`
+}
+
+// VideoConstraintsEvent - оценка полосы клиента и размеры плиток, по ним выбирается слой симулкаста
+type VideoConstraintsEvent struct {
+	BandwidthKbps int             `json:"bandwidth_kbps"`
+	Viewports     []TrackViewport `json:"viewports"`
+}
+
+// TrackViewport - размер плитки, в которой клиент показывает видео трек
+type TrackViewport struct {
+	TrackID string `json:"track_id"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+}
+
+// ConnectionQualityEvent - периодический замер качества соединений участников канала
+type ConnectionQualityEvent struct {
+	ChannelID    string               `json:"channel_id"`
+	Participants []ParticipantQuality `json:"participants"`
+}
+
+// ParticipantQuality - качество соединения участника. Level: excellent, good, poor, bad или unknown
+type ParticipantQuality struct {
+	UserID   string  `json:"user_id"`
+	Level    string  `json:"level"`
+	Loss     float64 `json:"loss"`
+	RTTMs    float64 `json:"rtt_ms"`
+	JitterMs float64 `json:"jitter_ms"`
+}
+
+// TrackUnpublishedEvent - видео трек участника закончился
+type TrackUnpublishedEvent struct {
+	TrackID string `json:"track_id"`
+	OwnerID string `json:"owner_id"`
+}
+
+// StageUserEvent - действие над участником stage канала. Пустой UserID - над собой
+type StageUserEvent struct {
+	UserID string `json:"user_id"`
+}
+
+// StageRoleEvent - участник stage канала стал спикером или слушателем
+type StageRoleEvent struct {
+	UserID string `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+// HandQueueEvent - очередь поднятых рук stage канала в порядке поднятия
+type HandQueueEvent struct {
+	ChannelID string           `json:"channel_id"`
+	Queue     []HandQueueEntry `json:"queue"`
+}
+
+// HandQueueEntry - слушатель, ожидающий слова
+type HandQueueEntry struct {
+	UserID   string    `json:"user_id"`
+	RaisedAt time.Time `json:"raised_at"`
+}
+
+// FloorStateEvent - кто держит слово в ptt канале и кто ждет в очереди
+type FloorStateEvent struct {
+	ChannelID string            `json:"channel_id"`
+	Holders   []FloorHolderInfo `json:"holders"`
+	Queue     []FloorQueueEntry `json:"queue"`
+}
+
+// FloorHolderInfo - говорящий ptt канала, после ExpiresAt слово забирается
+type FloorHolderInfo struct {
+	UserID    string    `json:"user_id"`
+	Priority  bool      `json:"priority"`
+	GrantedAt time.Time `json:"granted_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// FloorQueueEntry - ожидающий слова в ptt канале
+type FloorQueueEntry struct {
+	UserID   string `json:"user_id"`
+	Priority bool   `json:"priority"`
+}
+
+// FloorGrantedEvent - пользователю дали слово, микрофон можно включать
+type FloorGrantedEvent struct {
+	ChannelID string    `json:"channel_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// FloorRevokedEvent - слово забрали. Reason: timeout или preempted
+type FloorRevokedEvent struct {
+	ChannelID string `json:"channel_id"`
+	Reason    string `json:"reason"`
+}
+
+// WhisperStartEvent - направить голос выбранным участникам своего канала (UserIDs)
+// или всем участникам другого канала (ChannelID)
+type WhisperStartEvent struct {
+	UserIDs   []string `json:"user_ids"`
+	ChannelID string   `json:"channel_id"`
+}
+
+// WhisperEvent - получателю начали или перестали шептать. ChannelID - канал шепчущего
+type WhisperEvent struct {
+	UserID    string `json:"user_id"`
+	ChannelID string `json:"channel_id"`
+}
+
+// BreakoutStartEvent - модератор делит участников канала на комнаты. Assignments - списки
+// пользователей по комнатам, без них участники распределяются случайно по Rooms комнатам
+type BreakoutStartEvent struct {
+	Rooms           int        `json:"rooms"`
+	Assignments     [][]string `json:"assignments"`
+	DurationSeconds int        `json:"duration_seconds"`
+}
+
+// BreakoutStartedEvent - сессия breakout началась, в EndsAt все вернутся в канал
+type BreakoutStartedEvent struct {
+	ChannelID string         `json:"channel_id"`
+	EndsAt    time.Time      `json:"ends_at"`
+	Rooms     []BreakoutRoom `json:"rooms"`
+}
+
+// BreakoutRoom - временная комната и ее участники
+type BreakoutRoom struct {
+	ChannelID string   `json:"channel_id"`
+	Name      string   `json:"name"`
+	UserIDs   []string `json:"user_ids"`
+}
+
+// BreakoutCountdownEvent - сколько секунд осталось до возвращения в канал
+type BreakoutCountdownEvent struct {
+	ChannelID   string `json:"channel_id"`
+	SecondsLeft int    `json:"seconds_left"`
+}
+
+// BreakoutEndedEvent - сессия breakout закончилась, комнаты удалены
+type BreakoutEndedEvent struct {
+	ChannelID string `json:"channel_id"`
+}
+
+// RoomChangedEvent - пользователя перевели в комнату breakout или вернули в канал без переподключения.
+// ParentChannelID пустой, когда пользователь вернулся в канал
+type RoomChangedEvent struct {
+	ChannelID       string `json:"channel_id"`
+	ParentChannelID string `json:"parent_channel_id,omitempty"`
+}
+
+// WaitingUserEvent - модератор впускает или отклоняет пользователя из зала ожидания канала
+type WaitingUserEvent struct {
+	ChannelID string `json:"channel_id"`
+	UserID    string `json:"user_id"`
+}
+
+// WaitingRoomEvent - очередь зала ожидания для модератора в порядке запросов
+type WaitingRoomEvent struct {
+	ChannelID string         `json:"channel_id"`
+	Queue     []WaitingEntry `json:"queue"`
+}
+
+// WaitingEntry - пользователь, ожидающий входа
+type WaitingEntry struct {
+	UserID      string    `json:"user_id"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"display_name"`
+	RequestedAt time.Time `json:"requested_at"`
+}
+
+// JoinStatusEvent - ответ на вход через зал ожидания: join_waiting, join_admitted или join_rejected.
+// После join_admitted клиент заново отправляет offer
+type JoinStatusEvent struct {
+	ChannelID string `json:"channel_id"`
+}
@@ -0,0 +1,113 @@
+package domain
+
+import (
+	"sync"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4"
+)
+
+const (
+	// layerSwitchFrameRate - частота кадров, по которой считается шаг RTP timestamp при смене слоя:
+	// новый слой начинается через один кадр после последнего отправленного
+	layerSwitchFrameRate = 30
+
+	// defaultVideoClockRate - частота RTP часов видео, если кодек ее не указал
+	defaultVideoClockRate = 90000
+)
+
+// VideoForwarder отправляет одному получателю один слой видео трека. Слой меняется
+// только на ключевом кадре, sequence number и timestamp переписываются так, чтобы
+// получатель видел непрерывный поток
+type VideoForwarder struct {
+	Local *webrtc.TrackLocalStaticRTP
+
+	// tsStep - длительность одного кадра в тиках RTP часов кодека
+	tsStep uint32
+
+	mu sync.Mutex
+
+	current    string
+	hasCurrent bool
+	target     string
+
+	started   bool
+	lastSeq   uint16
+	lastTS    uint32
+	seqOffset uint16
+	tsOffset  uint32
+}
+
+func NewVideoForwarder(local *webrtc.TrackLocalStaticRTP) *VideoForwarder {
+	clockRate := local.Codec().ClockRate
+	if clockRate == 0 {
+		clockRate = defaultVideoClockRate
+	}
+
+	return &VideoForwarder{Local: local, tsStep: clockRate / layerSwitchFrameRate}
+}
+
+// SetTarget задает слой для получателя. true - нужен ключевой кадр этого слоя
+func (f *VideoForwarder) SetTarget(rid string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.target = rid
+
+	return !f.hasCurrent || f.current != rid
+}
+
+// Target возвращает слой, который должен получать получатель
+func (f *VideoForwarder) Target() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.target
+}
+
+// Forward отправляет пакет, если он с текущего слоя или начинает ключевой кадр целевого
+func (f *VideoForwarder) Forward(rid string, pkt *rtp.Packet, keyframe bool) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	out, ok := f.rewrite(rid, pkt, keyframe)
+	if !ok {
+		return false, nil
+	}
+
+	return true, f.Local.WriteRTP(&out)
+}
+
+// rewrite выбирает пакет для получателя и переписывает его sequence number и timestamp
+func (f *VideoForwarder) rewrite(rid string, pkt *rtp.Packet, keyframe bool) (rtp.Packet, bool) {
+	switch {
+	case rid == f.target && keyframe && (!f.hasCurrent || f.current != rid):
+		f.switchTo(rid, pkt)
+	case f.hasCurrent && rid == f.current:
+	default:
+		return rtp.Packet{}, false
+	}
+
+	out := *pkt
+	out.SequenceNumber = pkt.SequenceNumber - f.seqOffset
+	out.Timestamp = pkt.Timestamp - f.tsOffset
+
+	// Пакеты могут приходить не по порядку, запоминаем только самый новый
+	if !f.started || int16(out.SequenceNumber-f.lastSeq) > 0 {
+		f.lastSeq = out.SequenceNumber
+		f.lastTS = out.Timestamp
+	}
+	f.started = true
+
+	return out, true
+}
+
+func (f *VideoForwarder) switchTo(rid string, pkt *rtp.Packet) {
+	if f.started {
+		f.seqOffset = pkt.SequenceNumber - (f.lastSeq + 1)
+		f.tsOffset = pkt.Timestamp - (f.lastTS + f.tsStep)
+	}
+
+	f.current = rid
+	f.hasCurrent = true
+}
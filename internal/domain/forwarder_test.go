@@ -0,0 +1,155 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v4"
+)
+
+func newTestForwarder(t *testing.T, clockRate uint32) *VideoForwarder {
+	t.Helper()
+
+	local, err := webrtc.NewTrackLocalStaticRTP(
+		webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: clockRate},
+		"video",
+		"test",
+	)
+	if err != nil {
+		t.Fatalf("create local track: %v", err)
+	}
+
+	return NewVideoForwarder(local)
+}
+
+func TestVideoForwarderTimestampStep(t *testing.T) {
+	tests := []struct {
+		clockRate uint32
+		want      uint32
+	}{
+		{clockRate: 90000, want: 3000},
+		{clockRate: 48000, want: 1600},
+		{clockRate: 0, want: 3000}, // кодек без частоты - 90 кГц
+	}
+
+	for _, tt := range tests {
+		if got := newTestForwarder(t, tt.clockRate).tsStep; got != tt.want {
+			t.Errorf("clock rate %d: tsStep = %d, want %d", tt.clockRate, got, tt.want)
+		}
+	}
+}
+
+func TestVideoForwarderRewrite(t *testing.T) {
+	type step struct {
+		name     string
+		target   string // непустой - перед пакетом вызывается SetTarget
+		rid      string
+		seq      uint16
+		ts       uint32
+		keyframe bool
+
+		wantOK  bool
+		wantSeq uint16
+		wantTS  uint32
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "first keyframe passes unchanged",
+			steps: []step{
+				{name: "delta before keyframe", target: "h", rid: "h", seq: 100, ts: 9000},
+				{name: "keyframe", rid: "h", seq: 101, ts: 12000, keyframe: true, wantOK: true, wantSeq: 101, wantTS: 12000},
+				{name: "delta", rid: "h", seq: 102, ts: 15000, wantOK: true, wantSeq: 102, wantTS: 15000},
+				{name: "other layer", rid: "l", seq: 7, ts: 500, keyframe: true},
+			},
+		},
+		{
+			name: "layer switch keeps seq and ts continuous",
+			steps: []step{
+				{name: "high keyframe", target: "h", rid: "h", seq: 1000, ts: 90000, keyframe: true, wantOK: true, wantSeq: 1000, wantTS: 90000},
+				{name: "high delta", rid: "h", seq: 1001, ts: 93000, wantOK: true, wantSeq: 1001, wantTS: 93000},
+				{name: "old layer until keyframe", target: "l", rid: "h", seq: 1002, ts: 96000, wantOK: true, wantSeq: 1002, wantTS: 96000},
+				{name: "low delta waits", rid: "l", seq: 50, ts: 400000},
+				{name: "low keyframe", rid: "l", seq: 51, ts: 403000, keyframe: true, wantOK: true, wantSeq: 1003, wantTS: 99000},
+				{name: "low delta", rid: "l", seq: 52, ts: 406000, wantOK: true, wantSeq: 1004, wantTS: 102000},
+				{name: "high dropped", rid: "h", seq: 1003, ts: 99000},
+			},
+		},
+		{
+			name: "late packet does not move the switch point",
+			steps: []step{
+				{name: "keyframe", target: "h", rid: "h", seq: 10, ts: 3000, keyframe: true, wantOK: true, wantSeq: 10, wantTS: 3000},
+				{name: "newest", rid: "h", seq: 12, ts: 9000, wantOK: true, wantSeq: 12, wantTS: 9000},
+				{name: "late", rid: "h", seq: 11, ts: 6000, wantOK: true, wantSeq: 11, wantTS: 6000},
+				{name: "switch", target: "l", rid: "l", seq: 500, ts: 1000, keyframe: true, wantOK: true, wantSeq: 13, wantTS: 12000},
+			},
+		},
+		{
+			name: "switch across sequence wraparound",
+			steps: []step{
+				{name: "keyframe", target: "h", rid: "h", seq: 65534, ts: 4294964296, keyframe: true, wantOK: true, wantSeq: 65534, wantTS: 4294964296},
+				{name: "wrap", rid: "h", seq: 65535, ts: 4294967295, wantOK: true, wantSeq: 65535, wantTS: 4294967295},
+				{name: "switch", target: "l", rid: "l", seq: 20, ts: 100, keyframe: true, wantOK: true, wantSeq: 0, wantTS: 2999},
+				{name: "after switch", rid: "l", seq: 21, ts: 3100, wantOK: true, wantSeq: 1, wantTS: 5999},
+			},
+		},
+		{
+			name: "switch back needs a keyframe again",
+			steps: []step{
+				{name: "high keyframe", target: "h", rid: "h", seq: 1, ts: 0, keyframe: true, wantOK: true, wantSeq: 1, wantTS: 0},
+				{name: "low keyframe", target: "l", rid: "l", seq: 300, ts: 50000, keyframe: true, wantOK: true, wantSeq: 2, wantTS: 3000},
+				{name: "high delta", target: "h", rid: "h", seq: 2, ts: 3000},
+				{name: "high keyframe", rid: "h", seq: 3, ts: 6000, keyframe: true, wantOK: true, wantSeq: 3, wantTS: 6000},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTestForwarder(t, 90000)
+
+			for _, s := range tt.steps {
+				if s.target != "" {
+					f.SetTarget(s.target)
+				}
+
+				out, ok := f.rewrite(s.rid, &rtp.Packet{
+					Header: rtp.Header{SequenceNumber: s.seq, Timestamp: s.ts},
+				}, s.keyframe)
+
+				if ok != s.wantOK {
+					t.Fatalf("%s: forwarded = %v, want %v", s.name, ok, s.wantOK)
+				}
+
+				if ok && (out.SequenceNumber != s.wantSeq || out.Timestamp != s.wantTS) {
+					t.Fatalf("%s: seq %d ts %d, want seq %d ts %d", s.name, out.SequenceNumber, out.Timestamp, s.wantSeq, s.wantTS)
+				}
+			}
+		})
+	}
+}
+
+func TestVideoForwarderSetTarget(t *testing.T) {
+	f := newTestForwarder(t, 90000)
+
+	if !f.SetTarget("h") {
+		t.Fatalf("first target must request a keyframe")
+	}
+
+	f.rewrite("h", &rtp.Packet{}, true)
+
+	if f.SetTarget("h") {
+		t.Fatalf("current layer must not request a keyframe")
+	}
+
+	if !f.SetTarget("l") {
+		t.Fatalf("new layer must request a keyframe")
+	}
+
+	if got := f.Target(); got != "l" {
+		t.Fatalf("Target() = %q, want l", got)
+	}
+}
@@ -6,6 +6,8 @@ type CreateChannelInput struct {
 	CreatorID uuid.UUID `json:"creator_id"`
 	Name      string    `json:"name"`
 	IsPublic  bool      `json:"is_public"`
+	// Type - voice, stage или ptt, пусто - voice
+	Type string `json:"type"`
 }
 
 type UpdateChannelInput struct {
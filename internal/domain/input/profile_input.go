@@ -0,0 +1,11 @@
+package input
+
+import "github.com/google/uuid"
+
+// UpdateProfileInput - частичное обновление профиля, nil поля не меняются
+type UpdateProfileInput struct {
+	UserID      uuid.UUID `json:"user_id"`
+	DisplayName *string   `json:"display_name"`
+	Bio         *string   `json:"bio"`
+	AccentColor *string   `json:"accent_color"`
+}
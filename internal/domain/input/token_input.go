@@ -0,0 +1,15 @@
+package input
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type CreateTokenInput struct {
+	// UserID - владелец токена: сам пользователь или его бот
+	UserID uuid.UUID     `json:"user_id"`
+	Name   string        `json:"name"`
+	Scopes []string      `json:"scopes"`
+	TTL    time.Duration `json:"ttl"`
+}
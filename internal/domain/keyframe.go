@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"strings"
+
+	"github.com/pion/rtp/codecs"
+	"github.com/pion/webrtc/v4"
+)
+
+const (
+	h264NALUTypeIDR  = 5
+	h264NALUTypeSPS  = 7
+	h264NALUTypeSTAP = 24
+	h264NALUTypeFUA  = 28
+)
+
+// IsKeyframeStart - начинается ли в RTP пакете ключевой кадр. На таком пакете
+// получатель может переключиться на другой слой симулкаста
+func IsKeyframeStart(mimeType string, payload []byte) bool {
+	switch strings.ToLower(mimeType) {
+	case strings.ToLower(webrtc.MimeTypeVP8):
+		var vp8 codecs.VP8Packet
+		if _, err := vp8.Unmarshal(payload); err != nil || len(vp8.Payload) == 0 {
+			return false
+		}
+
+		// Начало первой партиции и P бит заголовка кадра VP8 равен 0
+		return vp8.S == 1 && vp8.PID == 0 && vp8.Payload[0]&0x01 == 0
+
+	case strings.ToLower(webrtc.MimeTypeVP9):
+		var vp9 codecs.VP9Packet
+		if _, err := vp9.Unmarshal(payload); err != nil {
+			return false
+		}
+
+		return vp9.B && !vp9.P
+
+	case strings.ToLower(webrtc.MimeTypeH264):
+		return isH264KeyframeStart(payload)
+	}
+
+	return false
+}
+
+func isH264KeyframeStart(payload []byte) bool {
+	if len(payload) == 0 {
+		return false
+	}
+
+	switch naluType := payload[0] & 0x1F; naluType {
+	case h264NALUTypeIDR, h264NALUTypeSPS:
+		return true
+
+	case h264NALUTypeSTAP:
+		// STAP-A: 2 байта длины перед каждым NALU
+		for offset := 1; offset+2 < len(payload); {
+			size := int(payload[offset])<<8 | int(payload[offset+1])
+			offset += 2
+
+			if offset >= len(payload) {
+				return false
+			}
+
+			if t := payload[offset] & 0x1F; t == h264NALUTypeIDR || t == h264NALUTypeSPS {
+				return true
+			}
+
+			offset += size
+		}
+
+	case h264NALUTypeFUA:
+		if len(payload) < 2 {
+			return false
+		}
+
+		// Первый фрагмент IDR
+		return payload[1]&0x80 != 0 && payload[1]&0x1F == h264NALUTypeIDR
+	}
+
+	return false
+}
@@ -0,0 +1,51 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/pion/webrtc/v4"
+)
+
+func TestIsKeyframeStart(t *testing.T) {
+	tests := []struct {
+		name     string
+		mimeType string
+		payload  []byte
+		want     bool
+	}{
+		// VP8: дескриптор S=1 PID=0, затем P бит заголовка кадра
+		{name: "vp8 keyframe", mimeType: webrtc.MimeTypeVP8, payload: []byte{0x10, 0x00, 0x9d}, want: true},
+		{name: "vp8 mime case", mimeType: "VIDEO/vp8", payload: []byte{0x10, 0x00, 0x9d}, want: true},
+		{name: "vp8 interframe", mimeType: webrtc.MimeTypeVP8, payload: []byte{0x10, 0x01, 0x9d}},
+		{name: "vp8 not partition start", mimeType: webrtc.MimeTypeVP8, payload: []byte{0x00, 0x00, 0x9d}},
+		{name: "vp8 empty", mimeType: webrtc.MimeTypeVP8, payload: []byte{}},
+
+		// VP9: B - начало кадра, P - кадр ссылается на предыдущие
+		{name: "vp9 keyframe", mimeType: webrtc.MimeTypeVP9, payload: []byte{0x08, 0xaa}, want: true},
+		{name: "vp9 inter frame", mimeType: webrtc.MimeTypeVP9, payload: []byte{0x48, 0xaa}},
+		{name: "vp9 not frame start", mimeType: webrtc.MimeTypeVP9, payload: []byte{0x04, 0xaa}},
+
+		{name: "h264 idr", mimeType: webrtc.MimeTypeH264, payload: []byte{0x65, 0x88}, want: true},
+		{name: "h264 sps", mimeType: webrtc.MimeTypeH264, payload: []byte{0x67, 0x42}, want: true},
+		{name: "h264 non idr", mimeType: webrtc.MimeTypeH264, payload: []byte{0x41, 0x9a}},
+		{name: "h264 stap-a with sps", mimeType: webrtc.MimeTypeH264, payload: []byte{0x78, 0x00, 0x02, 0x67, 0x42, 0x00, 0x02, 0x68, 0xce}, want: true},
+		{name: "h264 stap-a idr second", mimeType: webrtc.MimeTypeH264, payload: []byte{0x78, 0x00, 0x01, 0x06, 0x00, 0x02, 0x65, 0x88}, want: true},
+		{name: "h264 stap-a without idr", mimeType: webrtc.MimeTypeH264, payload: []byte{0x78, 0x00, 0x02, 0x41, 0x9a}},
+		{name: "h264 stap-a truncated", mimeType: webrtc.MimeTypeH264, payload: []byte{0x78, 0x00, 0x02}},
+		{name: "h264 fu-a idr start", mimeType: webrtc.MimeTypeH264, payload: []byte{0x7c, 0x85, 0x88}, want: true},
+		{name: "h264 fu-a idr middle", mimeType: webrtc.MimeTypeH264, payload: []byte{0x7c, 0x05, 0x88}},
+		{name: "h264 fu-a non idr start", mimeType: webrtc.MimeTypeH264, payload: []byte{0x7c, 0x81, 0x9a}},
+		{name: "h264 fu-a short", mimeType: webrtc.MimeTypeH264, payload: []byte{0x7c}},
+		{name: "h264 empty", mimeType: webrtc.MimeTypeH264, payload: nil},
+
+		{name: "audio", mimeType: webrtc.MimeTypeOpus, payload: []byte{0x65, 0x88}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsKeyframeStart(tt.mimeType, tt.payload); got != tt.want {
+				t.Fatalf("IsKeyframeStart(%s, %x) = %v, want %v", tt.mimeType, tt.payload, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package models
+
+import "fmt"
+
+const maxChannelUsers = 1000
+
+// Admission - кто и сколько может войти в звонок канала. Модератор входит всегда
+type Admission struct {
+	// MaxUsers - предел участников звонка, 0 - без ограничения
+	MaxUsers int `json:"max_users" db:"max_users"`
+	// WaitingRoom - входящие ждут, пока модератор их впустит
+	WaitingRoom bool `json:"waiting_room" db:"waiting_room"`
+}
+
+func (a Admission) Validate() error {
+	if a.MaxUsers < 0 || a.MaxUsers > maxChannelUsers {
+		return fmt.Errorf("max_users must be between 0 and %d", maxChannelUsers)
+	}
+
+	return nil
+}
+
+// IsFull - в звонке с participants участниками больше нет мест
+func (a Admission) IsFull(participants int) bool {
+	return a.MaxUsers > 0 && participants >= a.MaxUsers
+}
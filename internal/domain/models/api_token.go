@@ -0,0 +1,74 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APITokenPrefix - префикс персональных токенов, по нему они отличаются от JWT
+const APITokenPrefix = "rs_"
+
+// Скоупы персональных API токенов
+const (
+	ScopeRead  = "read"  // GET запросы к /api/v1/*
+	ScopeWrite = "write" // изменяющие запросы к /api/v1/*
+	ScopeWS    = "ws"    // подключение к /api/v1/ws
+)
+
+// AllScopes - все известные скоупы
+var AllScopes = Scopes{ScopeRead, ScopeWrite, ScopeWS}
+
+// Scopes - список скоупов, в БД хранится строкой через пробел (как в OAuth)
+type Scopes []string
+
+func (s Scopes) Value() (driver.Value, error) {
+	return strings.Join(s, " "), nil
+}
+
+func (s *Scopes) Scan(src any) error {
+	switch v := src.(type) {
+	case string:
+		*s = strings.Fields(v)
+	case []byte:
+		*s = strings.Fields(string(v))
+	case nil:
+		*s = nil
+	default:
+		return fmt.Errorf("unsupported scopes type %T", src)
+	}
+
+	return nil
+}
+
+// APIToken - долгоживущий персональный токен доступа.
+// Сам токен не хранится, только его sha256 хеш.
+type APIToken struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	UserID     uuid.UUID  `json:"user_id" db:"user_id"`
+	Name       string     `json:"name" db:"name"`
+	TokenHash  string     `json:"-" db:"token_hash"`
+	Scopes     Scopes     `json:"scopes" db:"scopes"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at" db:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at" db:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at" db:"revoked_at"`
+}
+
+// HasScope проверяет, выдан ли токену скоуп
+func (t *APIToken) HasScope(scope string) bool {
+	return slices.Contains(t.Scopes, scope)
+}
+
+// IsActive возвращает false для отозванных и истекших токенов
+func (t *APIToken) IsActive(now time.Time) bool {
+	if t.RevokedAt != nil {
+		return false
+	}
+
+	return t.ExpiresAt == nil || now.Before(*t.ExpiresAt)
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	opusMinBitrate = 6000
+	opusMaxBitrate = 510000
+)
+
+// opusPtimes - длительности пакета Opus в мс, которые понимают браузеры
+var opusPtimes = []int{10, 20, 40, 60}
+
+// AudioProfile - настройки звука канала, передаются клиентам через fmtp Opus в SDP
+type AudioProfile struct {
+	// OpusMaxBitrate - предел битрейта в бит/с, 0 - выбирает клиент
+	OpusMaxBitrate int  `json:"opus_max_bitrate" db:"opus_max_bitrate"`
+	OpusStereo     bool `json:"opus_stereo" db:"opus_stereo"`
+	OpusDTX        bool `json:"opus_dtx" db:"opus_dtx"`
+	OpusFEC        bool `json:"opus_fec" db:"opus_fec"`
+	// OpusPtime - длительность пакета в мс
+	OpusPtime int `json:"opus_ptime" db:"opus_ptime"`
+
+	// LegacyCodecs - разрешить G.722 и PCMU клиентам без Opus. Звук не перекодируется,
+	// такие клиенты слышат только участников с тем же кодеком
+	LegacyCodecs bool `json:"legacy_codecs" db:"legacy_codecs"`
+}
+
+// DefaultAudioProfile - голосовой профиль: моно, FEC, пакеты по 20 мс
+func DefaultAudioProfile() AudioProfile {
+	return AudioProfile{OpusFEC: true, OpusPtime: 20}
+}
+
+func (p AudioProfile) Validate() error {
+	if p.OpusMaxBitrate != 0 && (p.OpusMaxBitrate < opusMinBitrate || p.OpusMaxBitrate > opusMaxBitrate) {
+		return fmt.Errorf("opus_max_bitrate must be between %d and %d", opusMinBitrate, opusMaxBitrate)
+	}
+
+	if !slices.Contains(opusPtimes, p.OpusPtime) {
+		return errors.New("opus_ptime must be one of 10, 20, 40, 60")
+	}
+
+	return nil
+}
+
+// OpusFmtp собирает строку fmtp Opus для SDP
+func (p AudioProfile) OpusFmtp() string {
+	params := []string{
+		"minptime=10",
+		fmt.Sprintf("ptime=%d", p.OpusPtime),
+		"useinbandfec=" + flag(p.OpusFEC),
+		"usedtx=" + flag(p.OpusDTX),
+		"stereo=" + flag(p.OpusStereo),
+		"sprop-stereo=" + flag(p.OpusStereo),
+	}
+
+	if p.OpusMaxBitrate > 0 {
+		params = append(params, fmt.Sprintf("maxaveragebitrate=%d", p.OpusMaxBitrate))
+	}
+
+	return strings.Join(params, ";")
+}
+
+func flag(v bool) string {
+	if v {
+		return "1"
+	}
+
+	return "0"
+}
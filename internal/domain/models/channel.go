@@ -1,6 +1,7 @@
 package models
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -8,22 +9,93 @@ import (
 	"github.com/qrave1/RoomSpeak/internal/domain/input"
 )
 
+// ChannelType - режим канала. В stage говорят только спикеры, остальные слушают,
+// в ptt звук идет только от получивших слово через ptt_start
+type ChannelType string
+
+const (
+	ChannelTypeVoice ChannelType = "voice"
+	ChannelTypeStage ChannelType = "stage"
+	ChannelTypePTT   ChannelType = "ptt"
+)
+
+func (t ChannelType) IsValid() bool {
+	return t == ChannelTypeVoice || t == ChannelTypeStage || t == ChannelTypePTT
+}
+
 type Channel struct {
-	ID        uuid.UUID `json:"id" db:"id"`
-	CreatorID uuid.UUID `json:"creator_id" db:"creator_id"`
-	Name      string    `json:"name" db:"name"`
-	IsPublic  bool      `json:"is_public" db:"is_public"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID        uuid.UUID   `json:"id" db:"id"`
+	CreatorID uuid.UUID   `json:"creator_id" db:"creator_id"`
+	Name      string      `json:"name" db:"name"`
+	IsPublic  bool        `json:"is_public" db:"is_public"`
+	Type      ChannelType `json:"type" db:"type"`
+	CreatedAt time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at" db:"updated_at"`
+
+	// ParentID - канал, из которого создана временная комната breakout
+	ParentID *uuid.UUID `json:"parent_id,omitempty" db:"parent_id"`
+	// ExpiresAt - когда комната breakout закрывается, после этого ее можно удалять
+	ExpiresAt *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+
+	// PasswordHash - bcrypt хеш пароля на вход в публичный канал, пусто - без пароля
+	PasswordHash string `json:"-" db:"password_hash"`
+
+	AudioProfile
+	FloorControl
+	Admission
 }
 
 func NewChannel(input *input.CreateChannelInput) *Channel {
+	channelType := ChannelType(input.Type)
+	if channelType == "" {
+		channelType = ChannelTypeVoice
+	}
+
 	return &Channel{
 		ID:        uuid.New(),
 		CreatorID: input.CreatorID,
 		Name:      input.Name,
 		IsPublic:  input.IsPublic,
+		Type:      channelType,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
+
+		AudioProfile: DefaultAudioProfile(),
+		FloorControl: DefaultFloorControl(),
+	}
+}
+
+// NewBreakoutChannel создает временную комнату breakout. Комната закрытая, видна и
+// управляется как родительский канал, звук берется из него
+func NewBreakoutChannel(parent *Channel, number int, expiresAt time.Time) *Channel {
+	now := time.Now()
+
+	return &Channel{
+		ID:        uuid.New(),
+		CreatorID: parent.CreatorID,
+		Name:      fmt.Sprintf("%s #%d", parent.Name, number),
+		Type:      ChannelTypeVoice,
+		CreatedAt: now,
+		UpdatedAt: now,
+		ParentID:  &parent.ID,
+		ExpiresAt: &expiresAt,
+
+		AudioProfile: parent.AudioProfile,
+		FloorControl: DefaultFloorControl(),
 	}
 }
+
+// IsBreakout - временная комната, в нее попадают только через сессию breakout
+func (c *Channel) IsBreakout() bool {
+	return c.ParentID != nil
+}
+
+// HasPassword - для входа в публичный канал нужен пароль
+func (c *Channel) HasPassword() bool {
+	return c.IsPublic && c.PasswordHash != ""
+}
+
+// IsModerator - управлять участниками звонка (сцена, комнаты, допуск) может создатель канала
+func (c *Channel) IsModerator(userID uuid.UUID) bool {
+	return c.CreatorID == userID
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	floorMaxTalkSeconds = 600
+	floorMaxHolders     = 8
+)
+
+// FloorControl - настройки ptt канала: сколько можно держать слово и сколько человек говорят одновременно
+type FloorControl struct {
+	MaxTalkSeconds int `json:"floor_max_talk_seconds" db:"floor_max_talk_seconds"`
+	MaxHolders     int `json:"floor_max_holders" db:"floor_max_holders"`
+}
+
+// DefaultFloorControl - рация: один говорящий, до 30 секунд
+func DefaultFloorControl() FloorControl {
+	return FloorControl{MaxTalkSeconds: 30, MaxHolders: 1}
+}
+
+func (f FloorControl) Validate() error {
+	if f.MaxTalkSeconds < 1 || f.MaxTalkSeconds > floorMaxTalkSeconds {
+		return fmt.Errorf("floor_max_talk_seconds must be between 1 and %d", floorMaxTalkSeconds)
+	}
+
+	if f.MaxHolders < 1 || f.MaxHolders > floorMaxHolders {
+		return fmt.Errorf("floor_max_holders must be between 1 and %d", floorMaxHolders)
+	}
+
+	return nil
+}
+
+func (f FloorControl) MaxTalkTime() time.Duration {
+	return time.Duration(f.MaxTalkSeconds) * time.Second
+}
@@ -7,9 +7,18 @@ import (
 )
 
 type User struct {
-	ID        uuid.UUID `json:"id" db:"id"`
-	Username  string    `json:"username" db:"username"`
-	Password  string    `json:"-" db:"password"`
+	ID       uuid.UUID  `json:"id" db:"id"`
+	Username string     `json:"username" db:"username"`
+	Password string     `json:"-" db:"password"`
+	IsBot    bool       `json:"is_bot" db:"is_bot"`
+	OwnerID  *uuid.UUID `json:"owner_id,omitempty" db:"owner_id"`
+
+	// Профиль
+	DisplayName string `json:"display_name" db:"display_name"`
+	AvatarKey   string `json:"-" db:"avatar_key"`
+	Bio         string `json:"bio" db:"bio"`
+	AccentColor string `json:"accent_color" db:"accent_color"`
+
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 }
@@ -19,3 +28,23 @@ func NewUser() *User {
 		ID: uuid.New(),
 	}
 }
+
+// VisibleName возвращает отображаемое имя, а если оно не задано - username
+func (u *User) VisibleName() string {
+	if u.DisplayName != "" {
+		return u.DisplayName
+	}
+
+	return u.Username
+}
+
+// NewBot создает бот-аккаунт, принадлежащий пользователю ownerID.
+// У бота нет пароля, авторизуется он только по API токенам.
+func NewBot(ownerID uuid.UUID, username string) *User {
+	return &User{
+		ID:       uuid.New(),
+		Username: username,
+		IsBot:    true,
+		OwnerID:  &ownerID,
+	}
+}
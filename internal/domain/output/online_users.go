@@ -2,6 +2,11 @@ package output
 
 // OnlineUserInfo содержит информацию об онлайн пользователе
 type OnlineUserInfo struct {
-	ID       string `json:"id"`
-	Username string `json:"username"`
+	ID          string `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	AvatarURL   string `json:"avatar_url"`
+
+	Status       string `json:"status"`
+	CustomStatus string `json:"custom_status"`
 }
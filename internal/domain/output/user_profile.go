@@ -0,0 +1,12 @@
+package output
+
+// UserProfile - публичный профиль пользователя
+type UserProfile struct {
+	ID          string `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	AvatarURL   string `json:"avatar_url"`
+	Bio         string `json:"bio"`
+	AccentColor string `json:"accent_color"`
+	IsBot       bool   `json:"is_bot"`
+}
@@ -2,50 +2,389 @@ package domain
 
 import (
 	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/pion/interceptor/pkg/cc"
+	"github.com/pion/interceptor/pkg/stats"
 	"github.com/pion/webrtc/v4"
-	"github.com/qrave1/RoomSpeak/internal/application/config"
+
+	"github.com/qrave1/RoomSpeak/internal/domain/models"
 )
 
+// bandwidthChangePercent - изменение оценки полосы меньше этого порога не пересчитывает слои
+const bandwidthChangePercent = 15
+
+// PeerConnectionFactory создает PeerConnection с кодеками профиля звука канала вместе
+// с оценщиком полосы до клиента и статистикой RTP потоков
+type PeerConnectionFactory interface {
+	NewPeerConnection(webrtc.Configuration, models.AudioProfile) (*webrtc.PeerConnection, cc.BandwidthEstimator, stats.Getter, error)
+}
+
 type Peer struct {
-	UserID     uuid.UUID
-	ChannelID  uuid.UUID
-	Conn       *webrtc.PeerConnection
-	AudioTrack *webrtc.TrackLocalStaticRTP
+	UserID      uuid.UUID
+	ChannelID   uuid.UUID
+	Conn        *webrtc.PeerConnection
+	AudioSender *webrtc.RTPSender
+	JoinedAt    time.Time
+
+	// AudioProfile - профиль звука канала на момент входа
+	AudioProfile models.AudioProfile
+	// audioTrack - звук канала для клиента, заменяется на legacy кодек по первому offer
+	audioTrack atomic.Pointer[webrtc.TrackLocalStaticRTP]
+
+	// Bandwidth - оценка полосы до клиента по TWCC, может быть nil
+	Bandwidth cc.BandwidthEstimator
+	// estimatedKbps - последняя оценка полосы, учтенная при выборе слоев
+	estimatedKbps atomic.Int64
+
+	// Stats - статистика RTP потоков соединения по SSRC, может быть nil
+	Stats stats.Getter
+	// InboundAudioSSRC - SSRC микрофона клиента, 0 - звук еще не пришел
+	InboundAudioSSRC atomic.Uint32
+
+	// Negotiated - первый offer клиента обработан, серверу можно самому инициировать
+	// пересогласование при добавлении видео треков
+	Negotiated atomic.Bool
+
+	// ListenOnly - слушатель stage канала или участник ptt канала без слова, его звук не пересылается
+	ListenOnly atomic.Bool
+	// whisper - куда направлен голос вместо канала, nil - всему каналу
+	whisper atomic.Pointer[WhisperRoute]
+	// room - комната сессии breakout, в которой сейчас пользователь, nil - сам канал
+	room atomic.Pointer[uuid.UUID]
+
+	mu sync.Mutex
+	// trackSources - метаданные треков от клиента, могут прийти раньше самого трека
+	trackSources map[string]TrackSource
+	// published - видео треки пользователя по ID
+	published map[string]*PublishedTrack
+	// subscriptions - чужие видео треки, отправляемые пользователю, по ID трека
+	subscriptions map[string]*Subscription
+	// constraints - полоса и размеры плиток получателя для выбора слоя симулкаста
+	constraints VideoConstraints
+}
+
+// VideoConstraints - ограничения получателя видео. Viewports - высота плитки по ID трека,
+// трек без плитки получает худший слой
+type VideoConstraints struct {
+	BandwidthKbps int
+	Viewports     map[string]int
 }
 
-func NewPeer(userID, channelID uuid.UUID, cfg *config.Config) (*Peer, error) {
-	pc, err := webrtc.NewPeerConnection(
+func NewPeer(
+	userID, channelID uuid.UUID,
+	factory PeerConnectionFactory,
+	iceServers []webrtc.ICEServer,
+	profile models.AudioProfile,
+) (*Peer, error) {
+	pc, estimator, rtpStats, err := factory.NewPeerConnection(
 		webrtc.Configuration{
-			ICEServers: []webrtc.ICEServer{
-				{
-					URLs: []string{"stun:stun.l.google.com:19302"},
-				},
-				cfg.TurnUDPServer,
-				cfg.TurnTCPServer,
-			},
+			ICEServers: iceServers,
 		},
+		profile,
 	)
 	if err != nil {
 		return nil, err
 	}
 
-	audioTrack, err := webrtc.NewTrackLocalStaticRTP(
-		webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeOpus}, "audio", "RoomSpeak",
-	)
+	audioTrack, err := newAudioTrack(OpusCodec(profile))
 	if err != nil {
-		return nil, fmt.Errorf("create audio track: %w", err)
+		return nil, err
 	}
 
-	if _, err = pc.AddTrack(audioTrack); err != nil {
+	audioSender, err := pc.AddTrack(audioTrack)
+	if err != nil {
 		return nil, fmt.Errorf("add audio track: %w", err)
 	}
 
-	return &Peer{
-		UserID:     userID,
-		ChannelID:  channelID,
-		Conn:       pc,
-		AudioTrack: audioTrack,
-	}, nil
+	peer := &Peer{
+		UserID:        userID,
+		ChannelID:     channelID,
+		Conn:          pc,
+		AudioSender:   audioSender,
+		JoinedAt:      time.Now(),
+		AudioProfile:  profile,
+		Bandwidth:     estimator,
+		Stats:         rtpStats,
+		trackSources:  make(map[string]TrackSource),
+		published:     make(map[string]*PublishedTrack),
+		subscriptions: make(map[string]*Subscription),
+	}
+
+	peer.audioTrack.Store(audioTrack)
+
+	return peer, nil
+}
+
+func newAudioTrack(codec webrtc.RTPCodecCapability) (*webrtc.TrackLocalStaticRTP, error) {
+	track, err := webrtc.NewTrackLocalStaticRTP(codec, "audio", "RoomSpeak")
+	if err != nil {
+		return nil, fmt.Errorf("create audio track: %w", err)
+	}
+
+	return track, nil
+}
+
+// AudioTrack возвращает трек, в который пишется звук канала для клиента
+func (p *Peer) AudioTrack() *webrtc.TrackLocalStaticRTP {
+	return p.audioTrack.Load()
+}
+
+// Room возвращает канал, участников которого пользователь сейчас слышит и видит:
+// комнату breakout или канал, в который он вошел
+func (p *Peer) Room() uuid.UUID {
+	if room := p.room.Load(); room != nil {
+		return *room
+	}
+
+	return p.ChannelID
+}
+
+// SetRoom переводит пользователя в комнату без переподключения, ChannelID возвращает в канал
+func (p *Peer) SetRoom(roomID uuid.UUID) {
+	if roomID == p.ChannelID {
+		p.room.Store(nil)
+		return
+	}
+
+	p.room.Store(&roomID)
+}
+
+// Whisper возвращает текущий шепот пользователя, nil - говорит всему каналу
+func (p *Peer) Whisper() *WhisperRoute {
+	return p.whisper.Load()
+}
+
+// SetWhisper направляет голос по маршруту, nil возвращает его в канал. Возвращает прошлый маршрут
+func (p *Peer) SetWhisper(route *WhisperRoute) *WhisperRoute {
+	return p.whisper.Swap(route)
+}
+
+// ReplaceWhisper меняет маршрут, только если пользователь все еще шепчет по old
+func (p *Peer) ReplaceWhisper(old, route *WhisperRoute) bool {
+	return p.whisper.CompareAndSwap(old, route)
+}
+
+// UseAudioCodec меняет кодек звука для клиента до первого согласования
+func (p *Peer) UseAudioCodec(codec webrtc.RTPCodecCapability) error {
+	if strings.EqualFold(p.AudioTrack().Codec().MimeType, codec.MimeType) {
+		return nil
+	}
+
+	track, err := newAudioTrack(codec)
+	if err != nil {
+		return err
+	}
+
+	if err = p.AudioSender.ReplaceTrack(track); err != nil {
+		return fmt.Errorf("replace audio track: %w", err)
+	}
+
+	p.audioTrack.Store(track)
+
+	return nil
+}
+
+// SetTrackSource запоминает, камера или экран публикуется в треке.
+// Возвращает опубликованный трек, если он уже пришел
+func (p *Peer) SetTrackSource(trackID string, source TrackSource) (*PublishedTrack, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.trackSources[trackID] = source
+
+	track, ok := p.published[trackID]
+	return track, ok
+}
+
+// TrackSource возвращает источник трека, по умолчанию камера
+func (p *Peer) TrackSource(trackID string) TrackSource {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if source, ok := p.trackSources[trackID]; ok {
+		return source
+	}
+
+	return TrackSourceCamera
+}
+
+// Published возвращает опубликованные видео треки пользователя
+func (p *Peer) Published() []*PublishedTrack {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	tracks := make([]*PublishedTrack, 0, len(p.published))
+	for _, track := range p.published {
+		tracks = append(tracks, track)
+	}
+
+	return tracks
+}
+
+// PublishLayer добавляет слой видео трека пользователя. created - трек только что
+// опубликован, его нужно раздать участникам канала
+func (p *Peer) PublishLayer(trackID, rid string, ssrc webrtc.SSRC, codec webrtc.RTPCodecCapability) (*PublishedTrack, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	track, ok := p.published[trackID]
+	if !ok {
+		track = NewPublishedTrack(trackID, p.UserID, codec)
+		p.published[trackID] = track
+	}
+
+	track.addLayer(rid, ssrc)
+
+	return track, !ok
+}
+
+// UnpublishLayer убирает слой трека. true - слоев не осталось и трек снят с публикации
+func (p *Peer) UnpublishLayer(track *PublishedTrack, rid string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if track.removeLayer(rid) > 0 {
+		return false
+	}
+
+	delete(p.published, track.ID)
+	delete(p.trackSources, track.ID)
+
+	return true
+}
+
+// Subscribe добавляет чужой трек в PeerConnection. false - трек уже отправляется.
+// У каждого получателя свой локальный трек, чтобы слой выбирался отдельно
+func (p *Peer) Subscribe(track *PublishedTrack) (*Subscription, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.subscriptions[track.ID]; ok {
+		return nil, false, nil
+	}
+
+	// StreamID совпадает с ID трека, клиент сопоставляет по нему ontrack и track_published
+	local, err := webrtc.NewTrackLocalStaticRTP(track.Codec, track.ID, track.ID)
+	if err != nil {
+		return nil, false, fmt.Errorf("create forwarded track %s: %w", track.ID, err)
+	}
+
+	sender, err := p.Conn.AddTrack(local)
+	if err != nil {
+		return nil, false, fmt.Errorf("add track %s: %w", track.ID, err)
+	}
+
+	sub := &Subscription{
+		SubscriberID: p.UserID,
+		Track:        track,
+		Sender:       sender,
+		Forwarder:    NewVideoForwarder(local),
+	}
+
+	p.subscriptions[track.ID] = sub
+	track.addSubscriber(sub)
+
+	return sub, true, nil
+}
+
+// Unsubscribe убирает чужой трек из PeerConnection
+func (p *Peer) Unsubscribe(trackID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	sub, ok := p.subscriptions[trackID]
+	if !ok {
+		return nil
+	}
+
+	delete(p.subscriptions, trackID)
+	sub.Track.RemoveSubscriber(p.UserID)
+
+	return p.Conn.RemoveTrack(sub.Sender)
+}
+
+// UnsubscribeAll отписывает пользователя от всех треков при выходе из канала
+func (p *Peer) UnsubscribeAll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for trackID, sub := range p.subscriptions {
+		sub.Track.RemoveSubscriber(p.UserID)
+		delete(p.subscriptions, trackID)
+	}
+}
+
+// Subscriptions возвращает чужие треки, которые получает пользователь
+func (p *Peer) Subscriptions() []*Subscription {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	subs := make([]*Subscription, 0, len(p.subscriptions))
+	for _, sub := range p.subscriptions {
+		subs = append(subs, sub)
+	}
+
+	return subs
+}
+
+func (p *Peer) SetVideoConstraints(constraints VideoConstraints) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.constraints = constraints
+}
+
+// SetEstimatedBandwidth сохраняет оценку полосы до клиента. true - оценка изменилась
+// заметно и слои стоит пересчитать
+func (p *Peer) SetEstimatedBandwidth(kbps int) bool {
+	prev := p.estimatedKbps.Load()
+	if prev > 0 && abs(int64(kbps)-prev)*100 < prev*bandwidthChangePercent {
+		return false
+	}
+
+	p.estimatedKbps.Store(int64(kbps))
+
+	return true
+}
+
+// LayerConstraints возвращает полосу на один трек и высоту его плитки. Полоса - меньшая
+// из оценки клиента и оценки сервера, делится поровну между всеми получаемыми треками,
+// 0 - ограничений нет
+func (p *Peer) LayerConstraints(trackID string) (bandwidthKbps, height int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	bandwidthKbps = p.constraints.BandwidthKbps
+	if estimated := int(p.estimatedKbps.Load()); estimated > 0 && (bandwidthKbps == 0 || estimated < bandwidthKbps) {
+		bandwidthKbps = estimated
+	}
+
+	if bandwidthKbps > 0 {
+		bandwidthKbps /= max(len(p.subscriptions), 1)
+	}
+
+	if p.constraints.Viewports == nil {
+		return bandwidthKbps, 0
+	}
+
+	height, ok := p.constraints.Viewports[trackID]
+	if !ok {
+		// Клиент прислал размеры, но трек не показывает
+		height = 1
+	}
+
+	return bandwidthKbps, height
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+
+	return n
 }
@@ -1,8 +1,33 @@
 package runtime
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StageRole - роль участника в stage канале
+type StageRole string
+
+const (
+	StageRoleSpeaker  StageRole = "speaker"
+	StageRoleListener StageRole = "listener"
+)
 
 type ActiveUser struct {
 	ID        uuid.UUID `json:"id"`
 	ChannelID uuid.UUID `json:"channel_id"`
+
+	// Role - роль в stage канале, в обычном канале пустая
+	Role StageRole `json:"role,omitempty"`
+	// HandRaisedAt - когда слушатель поднял руку, nil - рука опущена
+	HandRaisedAt *time.Time `json:"hand_raised_at,omitempty"`
+
+	// ParentChannelID - канал, в который пользователь вернется после breakout. ChannelID при этом - комната
+	ParentChannelID *uuid.UUID `json:"parent_channel_id,omitempty"`
+}
+
+// IsListener - участник stage канала без права говорить
+func (u ActiveUser) IsListener() bool {
+	return u.Role == StageRoleListener
 }
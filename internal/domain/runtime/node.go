@@ -0,0 +1,10 @@
+package runtime
+
+// Node - нода RoomSpeak, обслуживающая медиа каналов
+type Node struct {
+	ID        string `json:"id"`
+	PublicURL string `json:"public_url"`
+
+	// RelayAddr - UDP адрес для каскадной пересылки RTP, пустой если каскад выключен
+	RelayAddr string `json:"relay_addr"`
+}
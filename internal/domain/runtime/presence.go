@@ -0,0 +1,82 @@
+package runtime
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type PresenceStatus string
+
+const (
+	PresenceOnline    PresenceStatus = "online"
+	PresenceAway      PresenceStatus = "away"
+	PresenceDND       PresenceStatus = "dnd"
+	PresenceInvisible PresenceStatus = "invisible"
+	PresenceOffline   PresenceStatus = "offline"
+)
+
+// IsManual - статусы, которые пользователь может выставить сам
+func (s PresenceStatus) IsManual() bool {
+	switch s {
+	case PresenceOnline, PresenceAway, PresenceDND, PresenceInvisible:
+		return true
+	default:
+		return false
+	}
+}
+
+// Presence - присутствие пользователя. Хранится между переподключениями,
+// чтобы выставленный вручную статус не сбрасывался
+type Presence struct {
+	UserID uuid.UUID `json:"user_id"`
+
+	// Status - статус, выбранный пользователем
+	Status PresenceStatus `json:"status"`
+
+	Connected    bool      `json:"connected"`
+	LastActivity time.Time `json:"last_activity"`
+	// DisconnectedAt - когда отключился, по нему забываются давно ушедшие пользователи
+	DisconnectedAt *time.Time `json:"disconnected_at,omitempty"`
+
+	CustomText      string     `json:"custom_text"`
+	CustomExpiresAt *time.Time `json:"custom_expires_at"`
+}
+
+// Effective вычисляет статус, который видят остальные: отключенные - offline,
+// неактивные дольше idleTimeout - away
+func (p Presence) Effective(now time.Time, idleTimeout time.Duration) PresenceStatus {
+	if !p.Connected {
+		return PresenceOffline
+	}
+
+	if p.Status == PresenceOnline && idleTimeout > 0 && now.Sub(p.LastActivity) >= idleTimeout {
+		return PresenceAway
+	}
+
+	return p.Status
+}
+
+// Visible возвращает статус для других пользователей: invisible выглядит как offline
+func (p Presence) Visible(now time.Time, idleTimeout time.Duration) PresenceStatus {
+	status := p.Effective(now, idleTimeout)
+	if status == PresenceInvisible {
+		return PresenceOffline
+	}
+
+	return status
+}
+
+// CustomStatus возвращает текст статуса, если он не истек
+func (p Presence) CustomStatus(now time.Time) string {
+	if p.CustomExpiresAt != nil && !now.Before(*p.CustomExpiresAt) {
+		return ""
+	}
+
+	return p.CustomText
+}
+
+// DisconnectedBefore - пользователь отключен и отключился раньше before
+func (p Presence) DisconnectedBefore(before time.Time) bool {
+	return !p.Connected && p.DisconnectedAt != nil && p.DisconnectedAt.Before(before)
+}
@@ -0,0 +1,63 @@
+package runtime
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// QualityLevel - оценка соединения для индикатора сигнала в клиенте
+type QualityLevel string
+
+const (
+	QualityExcellent QualityLevel = "excellent"
+	QualityGood      QualityLevel = "good"
+	QualityPoor      QualityLevel = "poor"
+	QualityBad       QualityLevel = "bad"
+	// QualityUnknown - данных еще нет, например звук не пошел
+	QualityUnknown QualityLevel = "unknown"
+)
+
+// ConnectionQuality - качество соединения участника с сервером за последний интервал.
+// Upstream - от клиента к серверу (микрофон), downstream - от сервера к клиенту
+type ConnectionQuality struct {
+	UserID    uuid.UUID `json:"user_id"`
+	ChannelID uuid.UUID `json:"channel_id"`
+
+	Level QualityLevel `json:"level"`
+
+	// Потери в долях от 0 до 1
+	UpstreamLoss   float64 `json:"upstream_loss"`
+	DownstreamLoss float64 `json:"downstream_loss"`
+
+	JitterMs float64 `json:"jitter_ms"`
+	RTTMs    float64 `json:"rtt_ms"`
+
+	UpstreamKbps   int `json:"upstream_kbps"`
+	DownstreamKbps int `json:"downstream_kbps"`
+
+	// CandidateType - тип выбранного ICE кандидата клиента: host, srflx, prflx или relay
+	CandidateType string `json:"candidate_type"`
+
+	MeasuredAt time.Time `json:"measured_at"`
+}
+
+// Loss - худшие потери из двух направлений
+func (q ConnectionQuality) Loss() float64 {
+	return max(q.UpstreamLoss, q.DownstreamLoss)
+}
+
+// QualityLevelFor оценивает соединение по потерям, задержке и джиттеру. Для голоса
+// заметны уже единицы процентов потерь и RTT больше 300 мс
+func QualityLevelFor(loss, rttMs, jitterMs float64) QualityLevel {
+	switch {
+	case loss < 0.01 && rttMs < 150 && jitterMs < 30:
+		return QualityExcellent
+	case loss < 0.03 && rttMs < 300 && jitterMs < 50:
+		return QualityGood
+	case loss < 0.1 && rttMs < 500 && jitterMs < 100:
+		return QualityPoor
+	default:
+		return QualityBad
+	}
+}
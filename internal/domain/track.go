@@ -0,0 +1,201 @@
+package domain
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pion/webrtc/v4"
+)
+
+// TrackSource - что показывает видео трек, по нему клиенты раскладывают плитки
+type TrackSource string
+
+const (
+	TrackSourceCamera TrackSource = "camera"
+	TrackSourceScreen TrackSource = "screen"
+)
+
+func (s TrackSource) IsValid() bool {
+	return s == TrackSourceCamera || s == TrackSourceScreen
+}
+
+// keyframeRequestInterval - не чаще одного PLI к публикующему на слой
+const keyframeRequestInterval = 500 * time.Millisecond
+
+// Layer - слой симулкаста. Без симулкаста у трека один слой с пустым RID
+type Layer struct {
+	RID  string
+	SSRC webrtc.SSRC
+
+	lastKeyframeRequest atomic.Int64
+}
+
+// AllowKeyframeRequest ограничивает частоту запросов ключевого кадра,
+// когда PLI присылают сразу несколько получателей
+func (l *Layer) AllowKeyframeRequest(now time.Time) bool {
+	last := l.lastKeyframeRequest.Load()
+	if now.UnixNano()-last < int64(keyframeRequestInterval) {
+		return false
+	}
+
+	return l.lastKeyframeRequest.CompareAndSwap(last, now.UnixNano())
+}
+
+// Subscription - отправка чужого видео трека одному получателю
+type Subscription struct {
+	SubscriberID uuid.UUID
+	Track        *PublishedTrack
+	Sender       *webrtc.RTPSender
+	Forwarder    *VideoForwarder
+}
+
+// PublishedTrack - видео трек участника, который пересылается остальным участникам канала
+type PublishedTrack struct {
+	ID       string
+	OwnerID  uuid.UUID
+	MimeType string
+	Codec    webrtc.RTPCodecCapability
+
+	mu sync.RWMutex
+	// layers отсортированы от худшего качества к лучшему
+	layers      []*Layer
+	subscribers map[uuid.UUID]*Subscription
+}
+
+func NewPublishedTrack(id string, ownerID uuid.UUID, codec webrtc.RTPCodecCapability) *PublishedTrack {
+	return &PublishedTrack{
+		ID:          id,
+		OwnerID:     ownerID,
+		MimeType:    codec.MimeType,
+		Codec:       codec,
+		subscribers: make(map[uuid.UUID]*Subscription),
+	}
+}
+
+func (t *PublishedTrack) addLayer(rid string, ssrc webrtc.SSRC) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.layers = append(t.layers, &Layer{RID: rid, SSRC: ssrc})
+
+	slices.SortStableFunc(t.layers, func(a, b *Layer) int {
+		return cmp.Compare(layerRank(a.RID), layerRank(b.RID))
+	})
+}
+
+// removeLayer возвращает, сколько слоев осталось
+func (t *PublishedTrack) removeLayer(rid string) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.layers = slices.DeleteFunc(t.layers, func(l *Layer) bool { return l.RID == rid })
+
+	return len(t.layers)
+}
+
+func (t *PublishedTrack) Layer(rid string) (*Layer, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	for _, layer := range t.layers {
+		if layer.RID == rid {
+			return layer, true
+		}
+	}
+
+	return nil, false
+}
+
+// SelectLayer выбирает слой под полосу получателя (кбит/с) и высоту плитки в пикселях, 0 - без ограничения
+func (t *PublishedTrack) SelectLayer(bandwidthKbps, height int) string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if len(t.layers) == 0 {
+		return ""
+	}
+
+	quality := len(t.layers) - 1
+
+	if height > 0 {
+		quality = min(quality, qualityForHeight(height))
+	}
+
+	if bandwidthKbps > 0 {
+		quality = min(quality, qualityForBandwidth(bandwidthKbps))
+	}
+
+	return t.layers[quality].RID
+}
+
+func (t *PublishedTrack) addSubscriber(sub *Subscription) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.subscribers[sub.SubscriberID] = sub
+}
+
+func (t *PublishedTrack) RemoveSubscriber(userID uuid.UUID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.subscribers, userID)
+}
+
+// Subscribers возвращает текущих получателей трека
+func (t *PublishedTrack) Subscribers() []*Subscription {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	subs := make([]*Subscription, 0, len(t.subscribers))
+	for _, sub := range t.subscribers {
+		subs = append(subs, sub)
+	}
+
+	return subs
+}
+
+// layerRank упорядочивает RID: q/h/f (четверть, половина, полное) и числовые 0, 1, 2
+func layerRank(rid string) int {
+	switch rid {
+	case "q":
+		return 0
+	case "h":
+		return 1
+	case "f":
+		return 2
+	}
+
+	if n, err := strconv.Atoi(rid); err == nil {
+		return n
+	}
+
+	return 0
+}
+
+func qualityForHeight(height int) int {
+	switch {
+	case height <= 180:
+		return 0
+	case height <= 360:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func qualityForBandwidth(kbps int) int {
+	switch {
+	case kbps < 300:
+		return 0
+	case kbps < 1000:
+		return 1
+	default:
+		return 2
+	}
+}
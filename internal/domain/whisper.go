@@ -0,0 +1,41 @@
+package domain
+
+import (
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// WhisperRoute - кому пользователь временно направляет свой голос вместо всего канала
+type WhisperRoute struct {
+	// ChannelID - шепот участникам другого канала, uuid.Nil - выбранным участникам своего
+	ChannelID uuid.UUID
+	// TargetIDs - получатели шепота, по ним отправляются уведомления. Для другого канала -
+	// его участники, вошедшие позже добавляются в копию маршрута
+	TargetIDs []uuid.UUID
+}
+
+// IsChannel - шепот в другой канал целиком
+func (r *WhisperRoute) IsChannel() bool {
+	return r.ChannelID != uuid.Nil
+}
+
+// WithTarget возвращает копию маршрута с еще одним получателем
+func (r *WhisperRoute) WithTarget(userID uuid.UUID) *WhisperRoute {
+	return &WhisperRoute{
+		ChannelID: r.ChannelID,
+		TargetIDs: append(slices.Clone(r.TargetIDs), userID),
+	}
+}
+
+// WithoutTarget возвращает копию маршрута без получателя
+func (r *WhisperRoute) WithoutTarget(userID uuid.UUID) *WhisperRoute {
+	return &WhisperRoute{
+		ChannelID: r.ChannelID,
+		TargetIDs: slices.DeleteFunc(slices.Clone(r.TargetIDs), func(id uuid.UUID) bool { return id == userID }),
+	}
+}
+
+func (r *WhisperRoute) Includes(userID uuid.UUID) bool {
+	return slices.Contains(r.TargetIDs, userID)
+}
@@ -20,6 +20,12 @@ type ActiveUserRepository interface {
 
 	// Get active user by ID
 	GetByID(ctx context.Context, userID uuid.UUID) (runtime.ActiveUser, bool)
+
+	// NotifyChanged сообщает нодам, что участника изменили с другой ноды, например сменили роль на сцене
+	NotifyChanged(ctx context.Context, activeUser runtime.ActiveUser)
+
+	// WatchChanged вызывает handle на каждый NotifyChanged до отмены ctx
+	WatchChanged(ctx context.Context, handle func(userID, channelID uuid.UUID))
 }
 
 type activeUserRepository struct {
@@ -70,3 +76,10 @@ func (r *activeUserRepository) GetByID(ctx context.Context, userID uuid.UUID) (r
 
 	return activeUser, exists
 }
+
+// NotifyChanged на одной ноде не нужен: участник всегда локальный и меняется на месте
+func (r *activeUserRepository) NotifyChanged(ctx context.Context, activeUser runtime.ActiveUser) {}
+
+func (r *activeUserRepository) WatchChanged(ctx context.Context, handle func(userID, channelID uuid.UUID)) {
+	<-ctx.Done()
+}
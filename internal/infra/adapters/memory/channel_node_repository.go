@@ -0,0 +1,40 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/qrave1/RoomSpeak/internal/domain/runtime"
+)
+
+// ChannelNodeRepository закрепляет канал за нодой, чтобы медиа канала шло через один SFU
+type ChannelNodeRepository interface {
+	// Acquire возвращает ноду канала, закрепляя канал за текущей нодой, если он свободен
+	Acquire(ctx context.Context, channelID uuid.UUID) (runtime.Node, error)
+
+	// Release освобождает канал, когда на текущей ноде в нем никого не осталось
+	Release(ctx context.Context, channelID uuid.UUID)
+
+	// Self возвращает текущую ноду
+	Self() runtime.Node
+}
+
+// channelNodeRepository - вариант для одной ноды: все каналы всегда локальные
+type channelNodeRepository struct {
+	self runtime.Node
+}
+
+func NewChannelNodeRepository(self runtime.Node) ChannelNodeRepository {
+	return &channelNodeRepository{self: self}
+}
+
+func (r *channelNodeRepository) Acquire(ctx context.Context, channelID uuid.UUID) (runtime.Node, error) {
+	return r.self, nil
+}
+
+func (r *channelNodeRepository) Release(ctx context.Context, channelID uuid.UUID) {}
+
+func (r *channelNodeRepository) Self() runtime.Node {
+	return r.self
+}
@@ -0,0 +1,36 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/qrave1/RoomSpeak/internal/domain/runtime"
+)
+
+// ChannelRelayRepository знает, какие еще ноды обслуживают участников канала
+type ChannelRelayRepository interface {
+	// Join отмечает, что на текущей ноде есть участники канала
+	Join(ctx context.Context, channelID uuid.UUID)
+
+	// Leave снимает отметку, когда на текущей ноде в канале никого не осталось
+	Leave(ctx context.Context, channelID uuid.UUID)
+
+	// RemoteNodes возвращает другие ноды канала. Вызывается на каждый RTP пакет, поэтому без сетевых запросов
+	RemoteNodes(channelID uuid.UUID) []runtime.Node
+}
+
+// channelRelayRepository - вариант для одной ноды: удаленных нод нет
+type channelRelayRepository struct{}
+
+func NewChannelRelayRepository() ChannelRelayRepository {
+	return &channelRelayRepository{}
+}
+
+func (r *channelRelayRepository) Join(ctx context.Context, channelID uuid.UUID) {}
+
+func (r *channelRelayRepository) Leave(ctx context.Context, channelID uuid.UUID) {}
+
+func (r *channelRelayRepository) RemoteNodes(channelID uuid.UUID) []runtime.Node {
+	return nil
+}
@@ -0,0 +1,63 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// PasswordAttemptRepository считает попытки ввода пароля в окне, которое начинается с первой попытки
+type PasswordAttemptRepository interface {
+	// Reserve учитывает попытку по ключу. Возвращает число попыток в текущем окне и время до его конца
+	Reserve(ctx context.Context, key string, window time.Duration) (int, time.Duration)
+
+	// Reset сбрасывает счетчик ключа
+	Reset(ctx context.Context, key string)
+}
+
+type passwordAttempts struct {
+	count   int
+	resetAt time.Time
+}
+
+type passwordAttemptRepository struct {
+	attempts map[string]*passwordAttempts
+	mu       sync.Mutex
+}
+
+func NewPasswordAttemptRepository() PasswordAttemptRepository {
+	return &passwordAttemptRepository{
+		attempts: make(map[string]*passwordAttempts),
+	}
+}
+
+func (r *passwordAttemptRepository) Reserve(ctx context.Context, key string, window time.Duration) (int, time.Duration) {
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Заодно забываем окна, которые уже закончились, чтобы карта не росла
+	for attemptKey, attempts := range r.attempts {
+		if !now.Before(attempts.resetAt) {
+			delete(r.attempts, attemptKey)
+		}
+	}
+
+	attempts, ok := r.attempts[key]
+	if !ok {
+		attempts = &passwordAttempts{resetAt: now.Add(window)}
+		r.attempts[key] = attempts
+	}
+
+	attempts.count++
+
+	return attempts.count, attempts.resetAt.Sub(now)
+}
+
+func (r *passwordAttemptRepository) Reset(ctx context.Context, key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.attempts, key)
+}
@@ -10,6 +10,15 @@ type PeerConnectionRepository interface {
 	Add(uuid.UUID, *domain.Peer)
 	Get(uuid.UUID) (*domain.Peer, bool)
 	Remove(uuid.UUID)
+
+	// GetInChannel возвращает пиры канала на этой ноде
+	GetInChannel(channelID uuid.UUID) []*domain.Peer
+	// GetInRoom возвращает пиры, которые сейчас слышат друг друга: участников канала
+	// без ушедших в комнаты breakout или участников комнаты
+	GetInRoom(roomID uuid.UUID) []*domain.Peer
+
+	// GetAll возвращает все пиры этой ноды
+	GetAll() []*domain.Peer
 }
 
 type peerConnectionRepository struct {
@@ -44,5 +53,47 @@ func (r *peerConnectionRepository) Remove(userID uuid.UUID) {
 	defer r.mu.Unlock()
 
 	delete(r.peers, userID)
+}
+
+func (r *peerConnectionRepository) GetInChannel(channelID uuid.UUID) []*domain.Peer {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var peers []*domain.Peer
+
+	for _, peer := range r.peers {
+		if peer.ChannelID == channelID {
+			peers = append(peers, peer)
+		}
+	}
+
+	return peers
+}
+
+func (r *peerConnectionRepository) GetInRoom(roomID uuid.UUID) []*domain.Peer {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var peers []*domain.Peer
+
+	for _, peer := range r.peers {
+		if peer.Room() == roomID {
+			peers = append(peers, peer)
+		}
+	}
+
+	return peers
+}
+
+func (r *peerConnectionRepository) GetAll() []*domain.Peer {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	peers := make([]*domain.Peer, 0, len(r.peers))
+
+	for _, peer := range r.peers {
+		peers = append(peers, peer)
+	}
 
+	return peers
 }
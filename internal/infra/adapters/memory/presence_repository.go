@@ -0,0 +1,78 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/qrave1/RoomSpeak/internal/domain/runtime"
+)
+
+type PresenceRepository interface {
+	// Get presence of a user
+	Get(ctx context.Context, userID uuid.UUID) (runtime.Presence, bool)
+
+	// Set replaces presence of a user
+	Set(ctx context.Context, presence runtime.Presence)
+
+	// GetConnected returns presence of all connected users
+	GetConnected(ctx context.Context) []runtime.Presence
+
+	// DeleteDisconnected removes presence of users disconnected before the given time
+	DeleteDisconnected(ctx context.Context, before time.Time)
+}
+
+type presenceRepository struct {
+	presences map[uuid.UUID]runtime.Presence
+	mu        sync.RWMutex
+}
+
+func NewPresenceRepository() PresenceRepository {
+	return &presenceRepository{
+		presences: make(map[uuid.UUID]runtime.Presence),
+	}
+}
+
+func (r *presenceRepository) Get(ctx context.Context, userID uuid.UUID) (runtime.Presence, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	presence, ok := r.presences[userID]
+
+	return presence, ok
+}
+
+func (r *presenceRepository) Set(ctx context.Context, presence runtime.Presence) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.presences[presence.UserID] = presence
+}
+
+func (r *presenceRepository) GetConnected(ctx context.Context) []runtime.Presence {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	presences := make([]runtime.Presence, 0, len(r.presences))
+
+	for _, presence := range r.presences {
+		if presence.Connected {
+			presences = append(presences, presence)
+		}
+	}
+
+	return presences
+}
+
+func (r *presenceRepository) DeleteDisconnected(ctx context.Context, before time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for userID, presence := range r.presences {
+		if presence.DisconnectedBefore(before) {
+			delete(r.presences, userID)
+		}
+	}
+}
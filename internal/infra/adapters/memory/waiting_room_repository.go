@@ -0,0 +1,111 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WaitingRoomRepository - залы ожидания каналов: кто и с какого времени ждет входа
+type WaitingRoomRepository interface {
+	// Add ставит пользователя в очередь канала, повторный запрос не сдвигает его место
+	Add(ctx context.Context, channelID, userID uuid.UUID, requestedAt time.Time)
+
+	// Remove убирает пользователя из очереди канала. false - он там не ждал
+	Remove(ctx context.Context, channelID, userID uuid.UUID) bool
+
+	// Get возвращает очередь канала: время запроса по пользователю
+	Get(ctx context.Context, channelID uuid.UUID) map[uuid.UUID]time.Time
+
+	// ChannelsOf возвращает каналы, в которые пользователь ждет входа
+	ChannelsOf(ctx context.Context, userID uuid.UUID) []uuid.UUID
+
+	// Admit сообщает нодам, что модератор впустил пользователя, подключенного к другой ноде
+	Admit(ctx context.Context, channelID, userID uuid.UUID)
+
+	// WatchAdmitted вызывает handle на каждый Admit до отмены ctx
+	WatchAdmitted(ctx context.Context, handle func(channelID, userID uuid.UUID))
+}
+
+type waitingRoomRepository struct {
+	rooms map[uuid.UUID]map[uuid.UUID]time.Time
+	mu    sync.Mutex
+}
+
+func NewWaitingRoomRepository() WaitingRoomRepository {
+	return &waitingRoomRepository{
+		rooms: make(map[uuid.UUID]map[uuid.UUID]time.Time),
+	}
+}
+
+func (r *waitingRoomRepository) Add(ctx context.Context, channelID, userID uuid.UUID, requestedAt time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	room, ok := r.rooms[channelID]
+	if !ok {
+		room = make(map[uuid.UUID]time.Time)
+		r.rooms[channelID] = room
+	}
+
+	if _, waiting := room[userID]; !waiting {
+		room[userID] = requestedAt
+	}
+}
+
+func (r *waitingRoomRepository) Remove(ctx context.Context, channelID, userID uuid.UUID) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	room, ok := r.rooms[channelID]
+	if !ok {
+		return false
+	}
+
+	if _, ok = room[userID]; !ok {
+		return false
+	}
+
+	delete(room, userID)
+
+	if len(room) == 0 {
+		delete(r.rooms, channelID)
+	}
+
+	return true
+}
+
+func (r *waitingRoomRepository) Get(ctx context.Context, channelID uuid.UUID) map[uuid.UUID]time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	queue := make(map[uuid.UUID]time.Time, len(r.rooms[channelID]))
+	for userID, requestedAt := range r.rooms[channelID] {
+		queue[userID] = requestedAt
+	}
+
+	return queue
+}
+
+func (r *waitingRoomRepository) ChannelsOf(ctx context.Context, userID uuid.UUID) []uuid.UUID {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var channelIDs []uuid.UUID
+	for channelID, room := range r.rooms {
+		if _, ok := room[userID]; ok {
+			channelIDs = append(channelIDs, channelID)
+		}
+	}
+
+	return channelIDs
+}
+
+// Admit на одной ноде не нужен: ожидающий всегда подключен к ней и входит сразу
+func (r *waitingRoomRepository) Admit(ctx context.Context, channelID, userID uuid.UUID) {}
+
+func (r *waitingRoomRepository) WatchAdmitted(ctx context.Context, handle func(channelID, userID uuid.UUID)) {
+	<-ctx.Done()
+}
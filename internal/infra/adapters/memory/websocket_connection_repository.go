@@ -3,11 +3,13 @@ package memory
 import (
 	"log/slog"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 
 	"github.com/qrave1/RoomSpeak/internal/application/constant"
+	"github.com/qrave1/RoomSpeak/internal/application/metrics"
 )
 
 // WebsocketConnectionRepository интерфейс для работы с активными сессиями в памяти
@@ -16,7 +18,21 @@ type WebsocketConnectionRepository interface {
 	Remove(uuid uuid.UUID)
 
 	Write(uuid.UUID, any)
+
+	// IsConnected - подключен ли пользователь к текущей ноде
+	IsConnected(uuid.UUID) bool
+
+	// GetAllConnected возвращает всех подключенных пользователей
 	GetAllConnected() []uuid.UUID
+
+	// Close отправляет close frame и закрывает соединение пользователя
+	Close(userID uuid.UUID, reason string)
+
+	// CloseAll отправляет close frame всем соединениям этой ноды
+	CloseAll()
+
+	// ForceCloseAll закрывает соединения этой ноды, не дожидаясь ответа клиентов на close frame
+	ForceCloseAll()
 }
 
 type safeWS struct {
@@ -62,6 +78,8 @@ func (w *wsConnectionRepository) Write(userID uuid.UUID, payload any) {
 
 	err := safews.conn.WriteJSON(payload)
 	if err != nil {
+		metrics.WebsocketWriteErrors.Inc()
+
 		slog.Error(
 			"write to websocket",
 			slog.Any(constant.Error, err),
@@ -79,6 +97,12 @@ func (w *wsConnectionRepository) getSafeWS(userID uuid.UUID) (*safeWS, bool) {
 	return conn, ok
 }
 
+func (w *wsConnectionRepository) IsConnected(userID uuid.UUID) bool {
+	_, ok := w.getSafeWS(userID)
+
+	return ok
+}
+
 func (w *wsConnectionRepository) GetAllConnected() []uuid.UUID {
 	w.mu.RLock()
 	defer w.mu.RUnlock()
@@ -91,3 +115,65 @@ func (w *wsConnectionRepository) GetAllConnected() []uuid.UUID {
 
 	return userIDs
 }
+
+func (w *wsConnectionRepository) Close(userID uuid.UUID, reason string) {
+	safews, ok := w.getSafeWS(userID)
+	if !ok {
+		return
+	}
+
+	closeMsg := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, reason)
+
+	safews.mu.Lock()
+	defer safews.mu.Unlock()
+
+	if err := safews.conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second)); err != nil {
+		slog.Error("write websocket close", slog.Any(constant.Error, err), slog.Any(constant.UserID, userID))
+	}
+
+	// Закрытие прерывает чтение в обработчике, он снимает соединение и выходит из звонка
+	if err := safews.conn.Close(); err != nil {
+		slog.Error("close websocket", slog.Any(constant.Error, err), slog.Any(constant.UserID, userID))
+	}
+}
+
+func (w *wsConnectionRepository) CloseAll() {
+	w.mu.RLock()
+	conns := make(map[uuid.UUID]*safeWS, len(w.wsConns))
+	for userID, safews := range w.wsConns {
+		conns[userID] = safews
+	}
+	w.mu.RUnlock()
+
+	closeMsg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutdown")
+
+	for userID, safews := range conns {
+		safews.mu.Lock()
+		err := safews.conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second))
+		safews.mu.Unlock()
+
+		if err != nil {
+			slog.Error(
+				"close websocket",
+				slog.Any(constant.Error, err),
+				slog.Any(constant.UserID, userID),
+			)
+		}
+	}
+}
+
+func (w *wsConnectionRepository) ForceCloseAll() {
+	w.mu.RLock()
+	conns := make(map[uuid.UUID]*safeWS, len(w.wsConns))
+	for userID, safews := range w.wsConns {
+		conns[userID] = safews
+	}
+	w.mu.RUnlock()
+
+	// Закрытие прерывает чтение в обработчиках, они снимают соединения сами
+	for userID, safews := range conns {
+		if err := safews.conn.Close(); err != nil {
+			slog.Error("force close websocket", slog.Any(constant.Error, err), slog.Any(constant.UserID, userID))
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/pressly/goose/v3"
+
+	"github.com/qrave1/RoomSpeak/internal/infra/adapters/postgres/migrations"
+)
+
+// MigrationVersions возвращает примененную и последнюю встроенную версии миграций
+func MigrationVersions(ctx context.Context, db *sql.DB) (current, latest int64, err error) {
+	provider, err := goose.NewProvider(goose.DialectPostgres, db, migrations.MigrationsFS)
+	if err != nil {
+		return 0, 0, fmt.Errorf("create goose provider: %w", err)
+	}
+
+	current, latest, err = provider.GetVersions(ctx)
+	if err != nil {
+		return 0, 0, fmt.Errorf("get migration versions: %w", err)
+	}
+
+	return current, latest, nil
+}
@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS display_name VARCHAR(64) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS avatar_key VARCHAR(512) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS bio VARCHAR(500) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS accent_color VARCHAR(7) NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE users
+    DROP COLUMN IF EXISTS accent_color,
+    DROP COLUMN IF EXISTS bio,
+    DROP COLUMN IF EXISTS avatar_key,
+    DROP COLUMN IF EXISTS display_name;
@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE channels
+    ADD COLUMN IF NOT EXISTS opus_max_bitrate INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS opus_stereo BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS opus_dtx BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS opus_fec BOOLEAN NOT NULL DEFAULT TRUE,
+    ADD COLUMN IF NOT EXISTS opus_ptime INTEGER NOT NULL DEFAULT 20,
+    ADD COLUMN IF NOT EXISTS legacy_codecs BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+ALTER TABLE channels
+    DROP COLUMN IF EXISTS legacy_codecs,
+    DROP COLUMN IF EXISTS opus_ptime,
+    DROP COLUMN IF EXISTS opus_fec,
+    DROP COLUMN IF EXISTS opus_dtx,
+    DROP COLUMN IF EXISTS opus_stereo,
+    DROP COLUMN IF EXISTS opus_max_bitrate;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE channels
+    ADD COLUMN IF NOT EXISTS type VARCHAR(16) NOT NULL DEFAULT 'voice';
+
+-- +goose Down
+ALTER TABLE channels
+    DROP COLUMN IF EXISTS type;
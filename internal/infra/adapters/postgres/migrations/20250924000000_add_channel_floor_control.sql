@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE channels
+    ADD COLUMN IF NOT EXISTS floor_max_talk_seconds INTEGER NOT NULL DEFAULT 30,
+    ADD COLUMN IF NOT EXISTS floor_max_holders INTEGER NOT NULL DEFAULT 1;
+
+ALTER TABLE channel_users
+    ADD COLUMN IF NOT EXISTS priority_speaker BOOLEAN NOT NULL DEFAULT false;
+
+-- +goose Down
+ALTER TABLE channel_users
+    DROP COLUMN IF EXISTS priority_speaker;
+
+ALTER TABLE channels
+    DROP COLUMN IF EXISTS floor_max_talk_seconds,
+    DROP COLUMN IF EXISTS floor_max_holders;
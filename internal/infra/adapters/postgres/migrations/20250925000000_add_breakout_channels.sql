@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE channels
+    ADD COLUMN IF NOT EXISTS parent_id UUID REFERENCES channels(id) ON DELETE CASCADE,
+    ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_channels_parent_id ON channels(parent_id) WHERE parent_id IS NOT NULL;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_channels_parent_id;
+
+ALTER TABLE channels
+    DROP COLUMN IF EXISTS parent_id,
+    DROP COLUMN IF EXISTS expires_at;
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE channels
+    ADD COLUMN IF NOT EXISTS max_users INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS waiting_room BOOLEAN NOT NULL DEFAULT false;
+
+-- +goose Down
+ALTER TABLE channels
+    DROP COLUMN IF EXISTS max_users,
+    DROP COLUMN IF EXISTS waiting_room;
@@ -0,0 +1,7 @@
+-- +goose Up
+ALTER TABLE channels
+    ADD COLUMN IF NOT EXISTS password_hash TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE channels
+    DROP COLUMN IF EXISTS password_hash;
@@ -6,19 +6,31 @@ import (
 	"log/slog"
 	"time"
 
+	"github.com/XSAM/otelsql"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/jmoiron/sqlx"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
 )
 
 func NewPostgres(ctx context.Context, url string) (*sqlx.DB, error) {
 	dbCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	db, err := sqlx.ConnectContext(dbCtx, "pgx", url)
+	// Каждый запрос репозиториев попадает в трейс дочерним спаном из ctx
+	sqlDB, err := otelsql.Open("pgx", url,
+		otelsql.WithAttributes(semconv.DBSystemNamePostgreSQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			DisableErrSkip:       true,
+			OmitConnResetSession: true,
+			OmitRows:             true,
+		}),
+	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to postgres: %w", err)
 	}
 
+	db := sqlx.NewDb(sqlDB, "pgx")
+
 	if err = db.PingContext(dbCtx); err != nil {
 		return nil, fmt.Errorf("failed to ping postgres: %w", err)
 	}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/qrave1/RoomSpeak/internal/domain/models"
+)
+
+type APITokenRepository interface {
+	Create(ctx context.Context, token *models.APIToken) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.APIToken, error)
+	GetByHash(ctx context.Context, hash string) (*models.APIToken, error)
+	ListByUser(ctx context.Context, userID uuid.UUID) ([]*models.APIToken, error)
+	Revoke(ctx context.Context, id uuid.UUID) error
+	// RevokeAllByUser отзывает все активные токены пользователя
+	RevokeAllByUser(ctx context.Context, userID uuid.UUID) error
+	TouchLastUsed(ctx context.Context, id uuid.UUID, usedAt time.Time) error
+}
+
+type apiTokenRepo struct {
+	db *sqlx.DB
+}
+
+func NewAPITokenRepo(db *sqlx.DB) APITokenRepository {
+	return &apiTokenRepo{db: db}
+}
+
+func (r *apiTokenRepo) Create(ctx context.Context, token *models.APIToken) error {
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		"INSERT INTO api_tokens (id, user_id, name, token_hash, scopes, created_at, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7)",
+		token.ID,
+		token.UserID,
+		token.Name,
+		token.TokenHash,
+		token.Scopes,
+		token.CreatedAt,
+		token.ExpiresAt,
+	)
+
+	return err
+}
+
+func (r *apiTokenRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.APIToken, error) {
+	var token models.APIToken
+
+	err := conn(ctx, r.db).GetContext(ctx, &token, "SELECT * FROM api_tokens WHERE id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (r *apiTokenRepo) GetByHash(ctx context.Context, hash string) (*models.APIToken, error) {
+	var token models.APIToken
+
+	err := conn(ctx, r.db).GetContext(ctx, &token, "SELECT * FROM api_tokens WHERE token_hash = $1", hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (r *apiTokenRepo) ListByUser(ctx context.Context, userID uuid.UUID) ([]*models.APIToken, error) {
+	var tokens []*models.APIToken
+
+	err := conn(ctx, r.db).SelectContext(ctx, &tokens, "SELECT * FROM api_tokens WHERE user_id = $1 ORDER BY created_at DESC", userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+func (r *apiTokenRepo) Revoke(ctx context.Context, id uuid.UUID) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, "UPDATE api_tokens SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL", time.Now(), id)
+
+	return err
+}
+
+func (r *apiTokenRepo) RevokeAllByUser(ctx context.Context, userID uuid.UUID) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, "UPDATE api_tokens SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL", time.Now(), userID)
+
+	return err
+}
+
+func (r *apiTokenRepo) TouchLastUsed(ctx context.Context, id uuid.UUID, usedAt time.Time) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, "UPDATE api_tokens SET last_used_at = $1 WHERE id = $2", usedAt, id)
+
+	return err
+}
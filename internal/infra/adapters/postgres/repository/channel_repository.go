@@ -2,6 +2,8 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,12 +15,28 @@ type ChannelRepository interface {
 	Create(ctx context.Context, channel *models.Channel) error
 	GetByID(ctx context.Context, id uuid.UUID) (*models.Channel, error)
 	Update(ctx context.Context, channel *models.Channel) error
+	UpdateAudioProfile(ctx context.Context, channelID uuid.UUID, profile models.AudioProfile) error
+	UpdateFloorControl(ctx context.Context, channelID uuid.UUID, floor models.FloorControl) error
+	UpdateAdmission(ctx context.Context, channelID uuid.UUID, admission models.Admission) error
+	// UpdatePassword меняет хеш пароля канала, пустой хеш снимает пароль
+	UpdatePassword(ctx context.Context, channelID uuid.UUID, passwordHash string) error
 	Delete(ctx context.Context, id uuid.UUID) error
+	// DeleteExpiredBreakouts удаляет комнаты breakout, закрывшиеся раньше before
+	DeleteExpiredBreakouts(ctx context.Context, before time.Time) error
 
 	AddUserToChannel(ctx context.Context, userID, channelID uuid.UUID) error
 	RemoveUserFromChannel(ctx context.Context, userID, channelID uuid.UUID) error
+	// SetPrioritySpeaker возвращает sql.ErrNoRows, если пользователь не участник канала
+	SetPrioritySpeaker(ctx context.Context, userID, channelID uuid.UUID, priority bool) error
+	IsPrioritySpeaker(ctx context.Context, userID, channelID uuid.UUID) (bool, error)
 
 	GetAvailableChannelsForUser(ctx context.Context, userID uuid.UUID) ([]*models.Channel, error)
+
+	// Для удаления и экспорта аккаунта
+	GetByCreator(ctx context.Context, creatorID uuid.UUID) ([]*models.Channel, error)
+	GetMemberChannels(ctx context.Context, userID uuid.UUID) ([]*models.Channel, error)
+	GetMemberIDs(ctx context.Context, channelID uuid.UUID) ([]uuid.UUID, error)
+	TransferOwnership(ctx context.Context, channelID, newCreatorID uuid.UUID) error
 }
 
 type channelRepo struct {
@@ -30,13 +48,29 @@ func NewChannelRepo(db *sqlx.DB) ChannelRepository {
 }
 
 func (r *channelRepo) Create(ctx context.Context, channel *models.Channel) error {
-	_, err := r.db.ExecContext(
+	_, err := conn(ctx, r.db).ExecContext(
 		ctx,
-		"INSERT INTO channels (id, creator_id, name, is_public) VALUES ($1, $2, $3, $4)",
+		`INSERT INTO channels (id, creator_id, name, is_public, type,
+			opus_max_bitrate, opus_stereo, opus_dtx, opus_fec, opus_ptime, legacy_codecs,
+			floor_max_talk_seconds, floor_max_holders, parent_id, expires_at, max_users, waiting_room)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)`,
 		channel.ID,
 		channel.CreatorID,
 		channel.Name,
 		channel.IsPublic,
+		channel.Type,
+		channel.OpusMaxBitrate,
+		channel.OpusStereo,
+		channel.OpusDTX,
+		channel.OpusFEC,
+		channel.OpusPtime,
+		channel.LegacyCodecs,
+		channel.MaxTalkSeconds,
+		channel.MaxHolders,
+		channel.ParentID,
+		channel.ExpiresAt,
+		channel.MaxUsers,
+		channel.WaitingRoom,
 	)
 
 	return err
@@ -45,7 +79,7 @@ func (r *channelRepo) Create(ctx context.Context, channel *models.Channel) error
 func (r *channelRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.Channel, error) {
 	var channel models.Channel
 
-	err := r.db.GetContext(ctx, &channel, "SELECT * FROM channels WHERE id = $1", id)
+	err := conn(ctx, r.db).GetContext(ctx, &channel, "SELECT * FROM channels WHERE id = $1", id)
 	if err != nil {
 		return nil, err
 	}
@@ -54,7 +88,7 @@ func (r *channelRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.Channe
 }
 
 func (r *channelRepo) Update(ctx context.Context, channel *models.Channel) error {
-	_, err := r.db.ExecContext(
+	_, err := conn(ctx, r.db).ExecContext(
 		ctx,
 		"UPDATE channels SET name = $1, updated_at = $2 WHERE id = $3",
 		channel.Name,
@@ -65,22 +99,128 @@ func (r *channelRepo) Update(ctx context.Context, channel *models.Channel) error
 	return err
 }
 
+func (r *channelRepo) UpdateAudioProfile(ctx context.Context, channelID uuid.UUID, profile models.AudioProfile) error {
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		`UPDATE channels SET opus_max_bitrate = $1, opus_stereo = $2, opus_dtx = $3, opus_fec = $4,
+			opus_ptime = $5, legacy_codecs = $6, updated_at = $7
+		WHERE id = $8`,
+		profile.OpusMaxBitrate,
+		profile.OpusStereo,
+		profile.OpusDTX,
+		profile.OpusFEC,
+		profile.OpusPtime,
+		profile.LegacyCodecs,
+		time.Now(),
+		channelID,
+	)
+
+	return err
+}
+
+func (r *channelRepo) UpdateFloorControl(ctx context.Context, channelID uuid.UUID, floor models.FloorControl) error {
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		`UPDATE channels SET floor_max_talk_seconds = $1, floor_max_holders = $2, updated_at = $3
+		WHERE id = $4`,
+		floor.MaxTalkSeconds,
+		floor.MaxHolders,
+		time.Now(),
+		channelID,
+	)
+
+	return err
+}
+
+func (r *channelRepo) UpdateAdmission(ctx context.Context, channelID uuid.UUID, admission models.Admission) error {
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		"UPDATE channels SET max_users = $1, waiting_room = $2, updated_at = $3 WHERE id = $4",
+		admission.MaxUsers,
+		admission.WaitingRoom,
+		time.Now(),
+		channelID,
+	)
+
+	return err
+}
+
+func (r *channelRepo) UpdatePassword(ctx context.Context, channelID uuid.UUID, passwordHash string) error {
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		"UPDATE channels SET password_hash = $1, updated_at = $2 WHERE id = $3",
+		passwordHash,
+		time.Now(),
+		channelID,
+	)
+
+	return err
+}
+
 func (r *channelRepo) Delete(ctx context.Context, id uuid.UUID) error {
-	_, err := r.db.ExecContext(ctx, "DELETE FROM channels WHERE id = $1", id)
+	_, err := conn(ctx, r.db).ExecContext(ctx, "DELETE FROM channels WHERE id = $1", id)
+
+	return err
+}
+
+func (r *channelRepo) DeleteExpiredBreakouts(ctx context.Context, before time.Time) error {
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		"DELETE FROM channels WHERE parent_id IS NOT NULL AND expires_at < $1",
+		before,
+	)
 
 	return err
 }
 
 func (r *channelRepo) AddUserToChannel(ctx context.Context, userID, channelID uuid.UUID) error {
-	_, err := r.db.ExecContext(ctx, "INSERT INTO channel_users (user_id, channel_id) VALUES ($1, $2)", userID, channelID)
+	_, err := conn(ctx, r.db).ExecContext(ctx, "INSERT INTO channel_users (user_id, channel_id) VALUES ($1, $2)", userID, channelID)
 	return err
 }
 
 func (r *channelRepo) RemoveUserFromChannel(ctx context.Context, userID, channelID uuid.UUID) error {
-	_, err := r.db.ExecContext(ctx, "DELETE FROM channel_users WHERE user_id = $1 AND channel_id = $2", userID, channelID)
+	_, err := conn(ctx, r.db).ExecContext(ctx, "DELETE FROM channel_users WHERE user_id = $1 AND channel_id = $2", userID, channelID)
 	return err
 }
 
+func (r *channelRepo) SetPrioritySpeaker(ctx context.Context, userID, channelID uuid.UUID, priority bool) error {
+	res, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		"UPDATE channel_users SET priority_speaker = $1 WHERE user_id = $2 AND channel_id = $3",
+		priority,
+		userID,
+		channelID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if aff, err := res.RowsAffected(); err != nil {
+		return err
+	} else if aff == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *channelRepo) IsPrioritySpeaker(ctx context.Context, userID, channelID uuid.UUID) (bool, error) {
+	var priority bool
+
+	err := conn(ctx, r.db).GetContext(
+		ctx,
+		&priority,
+		"SELECT priority_speaker FROM channel_users WHERE user_id = $1 AND channel_id = $2",
+		userID,
+		channelID,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+
+	return priority, err
+}
+
 func (r *channelRepo) GetAvailableChannelsForUser(ctx context.Context, userID uuid.UUID) ([]*models.Channel, error) {
 	var channels []*models.Channel
 
@@ -88,14 +228,69 @@ func (r *channelRepo) GetAvailableChannelsForUser(ctx context.Context, userID uu
 		SELECT DISTINCT c.*
 		FROM channels c
 		LEFT JOIN channel_users cu ON c.id = cu.channel_id AND cu.user_id = $1
-		WHERE c.is_public = true OR cu.user_id = $1
+		WHERE c.parent_id IS NULL AND (c.is_public = true OR cu.user_id = $1)
 		ORDER BY c.created_at DESC
 	`
 
-	err := r.db.SelectContext(ctx, &channels, query, userID)
+	err := conn(ctx, r.db).SelectContext(ctx, &channels, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return channels, nil
+}
+
+func (r *channelRepo) GetByCreator(ctx context.Context, creatorID uuid.UUID) ([]*models.Channel, error) {
+	var channels []*models.Channel
+
+	err := conn(ctx, r.db).SelectContext(ctx, &channels, "SELECT * FROM channels WHERE creator_id = $1 AND parent_id IS NULL ORDER BY created_at", creatorID)
 	if err != nil {
 		return nil, err
 	}
 
 	return channels, nil
 }
+
+func (r *channelRepo) GetMemberChannels(ctx context.Context, userID uuid.UUID) ([]*models.Channel, error) {
+	var channels []*models.Channel
+
+	query := `
+		SELECT c.*
+		FROM channels c
+		JOIN channel_users cu ON c.id = cu.channel_id
+		WHERE cu.user_id = $1
+		ORDER BY cu.created_at
+	`
+
+	err := conn(ctx, r.db).SelectContext(ctx, &channels, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return channels, nil
+}
+
+// GetMemberIDs возвращает участников канала в порядке вступления
+func (r *channelRepo) GetMemberIDs(ctx context.Context, channelID uuid.UUID) ([]uuid.UUID, error) {
+	var userIDs []uuid.UUID
+
+	err := conn(ctx, r.db).SelectContext(ctx, &userIDs, "SELECT user_id FROM channel_users WHERE channel_id = $1 ORDER BY created_at", channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	return userIDs, nil
+}
+
+func (r *channelRepo) TransferOwnership(ctx context.Context, channelID, newCreatorID uuid.UUID) error {
+	_, err := conn(ctx, r.db).ExecContext(
+		ctx,
+		// Комнаты breakout канала переходят вместе с ним, иначе держат creator_id удаляемого пользователя
+		"UPDATE channels SET creator_id = $1, updated_at = $2 WHERE id = $3 OR parent_id = $3",
+		newCreatorID,
+		time.Now(),
+		channelID,
+	)
+
+	return err
+}
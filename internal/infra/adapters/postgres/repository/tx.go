@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type txKey struct{}
+
+// querier - общие методы *sqlx.DB и *sqlx.Tx, которыми пользуются репозитории
+type querier interface {
+	sqlx.ExecerContext
+	GetContext(ctx context.Context, dest any, query string, args ...any) error
+	SelectContext(ctx context.Context, dest any, query string, args ...any) error
+}
+
+// conn возвращает транзакцию из ctx, если запрос идет внутри Transactor.InTx, иначе пул соединений
+func conn(ctx context.Context, db *sqlx.DB) querier {
+	if tx, ok := ctx.Value(txKey{}).(*sqlx.Tx); ok {
+		return tx
+	}
+
+	return db
+}
+
+// Transactor выполняет несколько вызовов репозиториев в одной транзакции
+type Transactor interface {
+	// InTx вызывает fn с ctx, в котором все репозитории работают в одной транзакции.
+	// Ошибка fn откатывает транзакцию
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type transactor struct {
+	db *sqlx.DB
+}
+
+func NewTransactor(db *sqlx.DB) Transactor {
+	return &transactor{db: db}
+}
+
+func (t *transactor) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	tx, err := t.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+
+	if err = fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return fmt.Errorf("%w (rollback: %w)", err, rollbackErr)
+		}
+
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return nil
+}
@@ -1,20 +1,42 @@
 package repository
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jmoiron/sqlx"
 
 	"github.com/qrave1/RoomSpeak/internal/domain/models"
 )
 
+// uniqueViolation - код ошибки Postgres при нарушении уникального индекса
+const uniqueViolation = "23505"
+
+// ErrUsernameTaken - пользователь с таким username уже есть
+var ErrUsernameTaken = errors.New("username is already taken")
+
 type UserRepository interface {
-	CreateUser(user *models.User) error
-	GetUserByID(id uuid.UUID) (*models.User, error)
-	GetUserByUsername(username string) (*models.User, error)
+	CreateUser(ctx context.Context, user *models.User) error
+	GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error)
+	GetUserByUsername(ctx context.Context, username string) (*models.User, error)
+
+	// Боты
+	GetBotsByOwner(ctx context.Context, ownerID uuid.UUID) ([]*models.User, error)
+
+	// Профиль
+	UpdateProfile(ctx context.Context, user *models.User) error
+	SetAvatarKey(ctx context.Context, id uuid.UUID, key string) error
+
+	// DeleteUser удаляет пользователя, членства в каналах и токены удаляются каскадно
+	DeleteUser(ctx context.Context, id uuid.UUID) error
 }
 
+const userColumns = "id, username, password, is_bot, owner_id, display_name, avatar_key, bio, accent_color, created_at, updated_at"
+
 type userRepo struct {
 	db *sqlx.DB
 }
@@ -23,11 +45,16 @@ func NewUserRepo(db *sqlx.DB) UserRepository {
 	return &userRepo{db: db}
 }
 
-func (r *userRepo) CreateUser(user *models.User) error {
-	query := "INSERT INTO users (username, password) VALUES ($1, $2)"
+func (r *userRepo) CreateUser(ctx context.Context, user *models.User) error {
+	query := "INSERT INTO users (id, username, password, is_bot, owner_id) VALUES ($1, $2, $3, $4, $5)"
 
-	res, err := r.db.Exec(query, user.Username, user.Password)
+	res, err := conn(ctx, r.db).ExecContext(ctx, query, user.ID, user.Username, user.Password, user.IsBot, user.OwnerID)
 	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return ErrUsernameTaken
+		}
+
 		return fmt.Errorf("create user: %w", err)
 	}
 
@@ -38,12 +65,12 @@ func (r *userRepo) CreateUser(user *models.User) error {
 	return nil
 }
 
-func (r *userRepo) GetUserByID(id uuid.UUID) (*models.User, error) {
+func (r *userRepo) GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
 	var user models.User
 
-	query := "SELECT id, username, password, created_at, updated_at FROM users WHERE id = $1"
+	query := "SELECT " + userColumns + " FROM users WHERE id = $1"
 
-	err := r.db.Get(&user, query, id)
+	err := conn(ctx, r.db).GetContext(ctx, &user, query, id)
 	if err != nil {
 		return nil, err
 	}
@@ -51,15 +78,59 @@ func (r *userRepo) GetUserByID(id uuid.UUID) (*models.User, error) {
 	return &user, nil
 }
 
-func (r *userRepo) GetUserByUsername(username string) (*models.User, error) {
+func (r *userRepo) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
 	var user models.User
 
-	query := "SELECT id, username, password, created_at, updated_at FROM users WHERE username = $1"
+	query := "SELECT " + userColumns + " FROM users WHERE username = $1"
 
-	err := r.db.Get(&user, query, username)
+	err := conn(ctx, r.db).GetContext(ctx, &user, query, username)
 	if err != nil {
 		return nil, err
 	}
 
 	return &user, nil
 }
+
+func (r *userRepo) GetBotsByOwner(ctx context.Context, ownerID uuid.UUID) ([]*models.User, error) {
+	var bots []*models.User
+
+	query := "SELECT " + userColumns + " FROM users WHERE is_bot = true AND owner_id = $1 ORDER BY created_at"
+
+	err := conn(ctx, r.db).SelectContext(ctx, &bots, query, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	return bots, nil
+}
+
+func (r *userRepo) UpdateProfile(ctx context.Context, user *models.User) error {
+	query := "UPDATE users SET display_name = $1, bio = $2, accent_color = $3, updated_at = $4 WHERE id = $5"
+
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, user.DisplayName, user.Bio, user.AccentColor, time.Now(), user.ID)
+	if err != nil {
+		return fmt.Errorf("update profile: %w", err)
+	}
+
+	return nil
+}
+
+func (r *userRepo) SetAvatarKey(ctx context.Context, id uuid.UUID, key string) error {
+	query := "UPDATE users SET avatar_key = $1, updated_at = $2 WHERE id = $3"
+
+	_, err := conn(ctx, r.db).ExecContext(ctx, query, key, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("set avatar key: %w", err)
+	}
+
+	return nil
+}
+
+func (r *userRepo) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	_, err := conn(ctx, r.db).ExecContext(ctx, "DELETE FROM users WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("delete user: %w", err)
+	}
+
+	return nil
+}
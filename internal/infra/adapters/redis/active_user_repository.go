@@ -0,0 +1,175 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+
+	"github.com/google/uuid"
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/qrave1/RoomSpeak/internal/application/constant"
+	"github.com/qrave1/RoomSpeak/internal/domain/runtime"
+	"github.com/qrave1/RoomSpeak/internal/infra/adapters/memory"
+)
+
+const (
+	// activeUsersKey - hash user_id -> runtime.ActiveUser
+	activeUsersKey = keyPrefix + "active_users"
+	// channelUsersKey - set user_id активных участников канала
+	channelUsersKey = keyPrefix + "channel_users:"
+	// activeUserChangedChannel - pub/sub канал изменений участников, которые надо применить на их ноде
+	activeUserChangedChannel = keyPrefix + "active_user_changed"
+)
+
+type activeUserChange struct {
+	UserID    uuid.UUID `json:"user_id"`
+	ChannelID uuid.UUID `json:"channel_id"`
+}
+
+type activeUserRepository struct {
+	client goredis.UniversalClient
+}
+
+// NewActiveUserRepository - общий для всех нод список участников звонков
+func NewActiveUserRepository(client goredis.UniversalClient) memory.ActiveUserRepository {
+	return &activeUserRepository{client: client}
+}
+
+func (r *activeUserRepository) Add(ctx context.Context, activeUser runtime.ActiveUser) {
+	data, err := json.Marshal(activeUser)
+	if err != nil {
+		slog.Error("marshal active user", slog.Any(constant.Error, err))
+		return
+	}
+
+	prev, hasPrev := r.GetByID(ctx, activeUser.ID)
+
+	_, err = r.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		if hasPrev && prev.ChannelID != activeUser.ChannelID {
+			pipe.SRem(ctx, channelUsersKey+prev.ChannelID.String(), activeUser.ID.String())
+		}
+
+		pipe.HSet(ctx, activeUsersKey, activeUser.ID.String(), data)
+		pipe.SAdd(ctx, channelUsersKey+activeUser.ChannelID.String(), activeUser.ID.String())
+
+		return nil
+	})
+	if err != nil {
+		slog.Error("add active user to redis", slog.Any(constant.Error, err), slog.Any(constant.UserID, activeUser.ID))
+	}
+}
+
+func (r *activeUserRepository) Remove(ctx context.Context, userID uuid.UUID) {
+	activeUser, ok := r.GetByID(ctx, userID)
+	if !ok {
+		return
+	}
+
+	_, err := r.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.HDel(ctx, activeUsersKey, userID.String())
+		pipe.SRem(ctx, channelUsersKey+activeUser.ChannelID.String(), userID.String())
+
+		return nil
+	})
+	if err != nil {
+		slog.Error("remove active user from redis", slog.Any(constant.Error, err), slog.Any(constant.UserID, userID))
+	}
+}
+
+func (r *activeUserRepository) GetInChannel(ctx context.Context, channelID uuid.UUID) []runtime.ActiveUser {
+	userIDs, err := r.client.SMembers(ctx, channelUsersKey+channelID.String()).Result()
+	if err != nil {
+		slog.Error("get channel users from redis", slog.Any(constant.Error, err), slog.Any(constant.ChannelID, channelID))
+		return nil
+	}
+
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	values, err := r.client.HMGet(ctx, activeUsersKey, userIDs...).Result()
+	if err != nil {
+		slog.Error("get active users from redis", slog.Any(constant.Error, err), slog.Any(constant.ChannelID, channelID))
+		return nil
+	}
+
+	activeUsers := make([]runtime.ActiveUser, 0, len(values))
+
+	for _, value := range values {
+		str, ok := value.(string)
+		if !ok {
+			continue
+		}
+
+		var activeUser runtime.ActiveUser
+		if err = json.Unmarshal([]byte(str), &activeUser); err != nil {
+			continue
+		}
+
+		// Множество канала может отставать от hash при гонке Add/Remove
+		if activeUser.ChannelID == channelID {
+			activeUsers = append(activeUsers, activeUser)
+		}
+	}
+
+	return activeUsers
+}
+
+func (r *activeUserRepository) GetByID(ctx context.Context, userID uuid.UUID) (runtime.ActiveUser, bool) {
+	data, err := r.client.HGet(ctx, activeUsersKey, userID.String()).Bytes()
+	if err != nil {
+		if !errors.Is(err, goredis.Nil) {
+			slog.Error("get active user from redis", slog.Any(constant.Error, err), slog.Any(constant.UserID, userID))
+		}
+
+		return runtime.ActiveUser{}, false
+	}
+
+	var activeUser runtime.ActiveUser
+	if err = json.Unmarshal(data, &activeUser); err != nil {
+		slog.Error("unmarshal active user", slog.Any(constant.Error, err))
+		return runtime.ActiveUser{}, false
+	}
+
+	return activeUser, true
+}
+
+func (r *activeUserRepository) NotifyChanged(ctx context.Context, activeUser runtime.ActiveUser) {
+	data, err := json.Marshal(activeUserChange{UserID: activeUser.ID, ChannelID: activeUser.ChannelID})
+	if err != nil {
+		slog.Error("marshal active user change", slog.Any(constant.Error, err))
+		return
+	}
+
+	if err = r.client.Publish(ctx, activeUserChangedChannel, data).Err(); err != nil {
+		slog.Error("publish active user change", slog.Any(constant.Error, err), slog.Any(constant.UserID, activeUser.ID))
+	}
+}
+
+func (r *activeUserRepository) WatchChanged(ctx context.Context, handle func(userID, channelID uuid.UUID)) {
+	sub := r.client.Subscribe(ctx, activeUserChangedChannel)
+	defer sub.Close()
+
+	ch := sub.Channel()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+
+			var change activeUserChange
+			if err := json.Unmarshal([]byte(msg.Payload), &change); err != nil {
+				slog.Error("unmarshal active user change", slog.Any(constant.Error, err))
+				continue
+			}
+
+			handle(change.UserID, change.ChannelID)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/qrave1/RoomSpeak/internal/domain/runtime"
+)
+
+func TestActiveUserRepository(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestRedis(t)
+
+	repo := NewActiveUserRepository(client)
+
+	channelA, channelB := uuid.New(), uuid.New()
+	alice, bob := uuid.New(), uuid.New()
+
+	repo.Add(ctx, runtime.ActiveUser{ID: alice, ChannelID: channelA})
+	repo.Add(ctx, runtime.ActiveUser{ID: bob, ChannelID: channelA, Role: runtime.StageRoleListener})
+
+	if got := len(repo.GetInChannel(ctx, channelA)); got != 2 {
+		t.Fatalf("channel a has %d users, want 2", got)
+	}
+
+	got, ok := repo.GetByID(ctx, bob)
+	if !ok || got.Role != runtime.StageRoleListener {
+		t.Fatalf("GetByID(bob) = %+v, %v; want listener", got, ok)
+	}
+
+	// Переход в другой канал убирает пользователя из старого
+	repo.Add(ctx, runtime.ActiveUser{ID: alice, ChannelID: channelB})
+
+	inA := repo.GetInChannel(ctx, channelA)
+	if len(inA) != 1 || inA[0].ID != bob {
+		t.Fatalf("channel a after move = %+v, want only bob", inA)
+	}
+
+	inB := repo.GetInChannel(ctx, channelB)
+	if len(inB) != 1 || inB[0].ID != alice {
+		t.Fatalf("channel b after move = %+v, want only alice", inB)
+	}
+
+	repo.Remove(ctx, bob)
+
+	if _, ok = repo.GetByID(ctx, bob); ok {
+		t.Fatalf("bob is still active after Remove")
+	}
+	if got := repo.GetInChannel(ctx, channelA); len(got) != 0 {
+		t.Fatalf("channel a after remove = %+v, want empty", got)
+	}
+}
+
+func TestActiveUserRepositoryWatchChanged(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, client := newTestRedis(t)
+
+	repo := NewActiveUserRepository(client)
+
+	type change struct{ userID, channelID uuid.UUID }
+	changes := make(chan change, 1)
+
+	go repo.WatchChanged(ctx, func(userID, channelID uuid.UUID) {
+		select {
+		case changes <- change{userID: userID, channelID: channelID}:
+		default:
+		}
+	})
+
+	want := change{userID: uuid.New(), channelID: uuid.New()}
+
+	// Подписка поднимается асинхронно, уведомление повторяется, пока его не услышат
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	timeout := time.After(time.Second)
+
+	for {
+		repo.NotifyChanged(ctx, runtime.ActiveUser{ID: want.userID, ChannelID: want.channelID})
+
+		select {
+		case got := <-changes:
+			if got != want {
+				t.Fatalf("WatchChanged got %+v, want %+v", got, want)
+			}
+
+			return
+		case <-ticker.C:
+		case <-timeout:
+			t.Fatalf("change was not delivered")
+		}
+	}
+}
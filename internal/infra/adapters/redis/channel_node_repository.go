@@ -0,0 +1,166 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/qrave1/RoomSpeak/internal/application/constant"
+	"github.com/qrave1/RoomSpeak/internal/domain/runtime"
+)
+
+const (
+	// channelNodeKey - node_id, за которой закреплен канал
+	channelNodeKey = keyPrefix + "channel_node:"
+
+	channelNodeTTL     = 60 * time.Second
+	channelNodeRefresh = 20 * time.Second
+)
+
+// releaseScript удаляет закрепление, только если канал все еще за нашей нодой
+var releaseScript = goredis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// takeOverScript переписывает закрепление на новую ноду, только если канал все еще за упавшей.
+// Иначе две ноды, одновременно увидевшие падение, обе забрали бы канал себе
+var takeOverScript = goredis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	redis.call("SET", KEYS[1], ARGV[2], "PX", ARGV[3])
+	return 1
+end
+return 0
+`)
+
+// ChannelNodeRepository закрепляет каналы за нодами через Redis
+type ChannelNodeRepository struct {
+	client goredis.UniversalClient
+	self   runtime.Node
+
+	// owned - каналы, закрепленные за этой нодой, их TTL продлевается в Run
+	owned map[uuid.UUID]struct{}
+	mu    sync.Mutex
+}
+
+func NewChannelNodeRepository(client goredis.UniversalClient, self runtime.Node) *ChannelNodeRepository {
+	return &ChannelNodeRepository{
+		client: client,
+		self:   self,
+		owned:  make(map[uuid.UUID]struct{}),
+	}
+}
+
+// Run продлевает закрепление локальных каналов до отмены ctx
+func (r *ChannelNodeRepository) Run(ctx context.Context) {
+	ticker := time.NewTicker(channelNodeRefresh)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.mu.Lock()
+			for channelID := range r.owned {
+				if err := r.client.Expire(ctx, channelNodeKey+channelID.String(), channelNodeTTL).Err(); err != nil {
+					slog.Error("refresh channel node", slog.Any(constant.Error, err), slog.Any(constant.ChannelID, channelID))
+				}
+			}
+			r.mu.Unlock()
+		}
+	}
+}
+
+func (r *ChannelNodeRepository) Acquire(ctx context.Context, channelID uuid.UUID) (runtime.Node, error) {
+	key := channelNodeKey + channelID.String()
+
+	acquired, err := r.client.SetNX(ctx, key, r.self.ID, channelNodeTTL).Result()
+	if err != nil {
+		return runtime.Node{}, fmt.Errorf("acquire channel node: %w", err)
+	}
+
+	if acquired {
+		r.markOwned(channelID)
+		return r.self, nil
+	}
+
+	ownerID, err := r.client.Get(ctx, key).Result()
+	if err != nil {
+		if errors.Is(err, goredis.Nil) {
+			// Ключ успел истечь между SETNX и GET
+			return r.Acquire(ctx, channelID)
+		}
+
+		return runtime.Node{}, fmt.Errorf("get channel node: %w", err)
+	}
+
+	if ownerID == r.self.ID {
+		r.markOwned(channelID)
+		return r.self, nil
+	}
+
+	alive, err := aliveNodes(ctx, r.client, []string{ownerID})
+	if err != nil {
+		return runtime.Node{}, fmt.Errorf("check channel node alive: %w", err)
+	}
+
+	if !alive[ownerID] {
+		// Нода канала упала - забираем канал себе
+		taken, err := takeOverScript.Run(
+			ctx,
+			r.client,
+			[]string{key},
+			ownerID,
+			r.self.ID,
+			channelNodeTTL.Milliseconds(),
+		).Bool()
+		if err != nil {
+			return runtime.Node{}, fmt.Errorf("take over channel node: %w", err)
+		}
+
+		if !taken {
+			// Канал успела забрать другая нода или ключ истек - смотрим заново
+			return r.Acquire(ctx, channelID)
+		}
+
+		r.markOwned(channelID)
+		return r.self, nil
+	}
+
+	publicURL, err := r.client.HGet(ctx, nodesKey, ownerID).Result()
+	if err != nil && !errors.Is(err, goredis.Nil) {
+		return runtime.Node{}, fmt.Errorf("get node public url: %w", err)
+	}
+
+	return runtime.Node{ID: ownerID, PublicURL: publicURL}, nil
+}
+
+func (r *ChannelNodeRepository) Release(ctx context.Context, channelID uuid.UUID) {
+	r.mu.Lock()
+	delete(r.owned, channelID)
+	r.mu.Unlock()
+
+	if err := releaseScript.Run(ctx, r.client, []string{channelNodeKey + channelID.String()}, r.self.ID).Err(); err != nil {
+		slog.Error("release channel node", slog.Any(constant.Error, err), slog.Any(constant.ChannelID, channelID))
+	}
+}
+
+func (r *ChannelNodeRepository) Self() runtime.Node {
+	return r.self
+}
+
+func (r *ChannelNodeRepository) markOwned(channelID uuid.UUID) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.owned[channelID] = struct{}{}
+}
@@ -0,0 +1,131 @@
+package redis
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/qrave1/RoomSpeak/internal/domain/runtime"
+)
+
+func TestChannelNodeRepository_Acquire(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestRedis(t)
+
+	nodeA := NewChannelNodeRepository(client, runtime.Node{ID: "a", PublicURL: "https://a.example"})
+	nodeB := NewChannelNodeRepository(client, runtime.Node{ID: "b", PublicURL: "https://b.example"})
+	markAlive(t, client, "a", "https://a.example")
+	markAlive(t, client, "b", "https://b.example")
+
+	channelID := uuid.New()
+
+	owner, err := nodeA.Acquire(ctx, channelID)
+	if err != nil {
+		t.Fatalf("acquire on a: %v", err)
+	}
+	if owner.ID != "a" {
+		t.Fatalf("first acquire: owner = %q, want a", owner.ID)
+	}
+
+	owner, err = nodeB.Acquire(ctx, channelID)
+	if err != nil {
+		t.Fatalf("acquire on b: %v", err)
+	}
+	if owner.ID != "a" || owner.PublicURL != "https://a.example" {
+		t.Fatalf("acquire of owned channel: owner = %+v, want node a with its url", owner)
+	}
+
+	nodeA.Release(ctx, channelID)
+
+	owner, err = nodeB.Acquire(ctx, channelID)
+	if err != nil {
+		t.Fatalf("acquire after release: %v", err)
+	}
+	if owner.ID != "b" {
+		t.Fatalf("acquire after release: owner = %q, want b", owner.ID)
+	}
+}
+
+func TestChannelNodeRepository_ReleaseKeepsForeignOwner(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestRedis(t)
+
+	nodeA := NewChannelNodeRepository(client, runtime.Node{ID: "a"})
+	nodeB := NewChannelNodeRepository(client, runtime.Node{ID: "b"})
+	markAlive(t, client, "a", "")
+
+	channelID := uuid.New()
+
+	if _, err := nodeA.Acquire(ctx, channelID); err != nil {
+		t.Fatalf("acquire on a: %v", err)
+	}
+
+	nodeB.Release(ctx, channelID)
+
+	ownerID, err := client.Get(ctx, channelNodeKey+channelID.String()).Result()
+	if err != nil {
+		t.Fatalf("get channel node: %v", err)
+	}
+	if ownerID != "a" {
+		t.Fatalf("release by other node changed owner to %q", ownerID)
+	}
+}
+
+func TestChannelNodeRepository_TakeOver(t *testing.T) {
+	ctx := context.Background()
+	server, client := newTestRedis(t)
+
+	dead := NewChannelNodeRepository(client, runtime.Node{ID: "dead"})
+	markAlive(t, client, "dead", "")
+
+	channelID := uuid.New()
+
+	if _, err := dead.Acquire(ctx, channelID); err != nil {
+		t.Fatalf("acquire on dead node: %v", err)
+	}
+
+	server.Del(nodeAliveKey + "dead")
+
+	const contenders = 8
+
+	var (
+		wg     sync.WaitGroup
+		owners = make([]string, contenders)
+	)
+
+	for i := range contenders {
+		node := NewChannelNodeRepository(client, runtime.Node{ID: string(rune('a' + i))})
+		markAlive(t, client, node.Self().ID, "")
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			owner, err := node.Acquire(ctx, channelID)
+			if err != nil {
+				t.Errorf("acquire on %s: %v", node.Self().ID, err)
+				return
+			}
+
+			owners[i] = owner.ID
+		}()
+	}
+
+	wg.Wait()
+
+	ownerID, err := client.Get(ctx, channelNodeKey+channelID.String()).Result()
+	if err != nil {
+		t.Fatalf("get channel node: %v", err)
+	}
+	if ownerID == "dead" {
+		t.Fatalf("channel was not taken over from dead node")
+	}
+
+	for i, owner := range owners {
+		if owner != ownerID {
+			t.Errorf("contender %d sees owner %q, stored owner is %q", i, owner, ownerID)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package redis
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/qrave1/RoomSpeak/internal/application/constant"
+	"github.com/qrave1/RoomSpeak/internal/domain/runtime"
+)
+
+const (
+	// channelRelaysKey - set node_id, на которых есть участники канала
+	channelRelaysKey = keyPrefix + "channel_relays:"
+	// nodeRelayAddrKey - hash node_id -> UDP адрес для RTP
+	nodeRelayAddrKey = keyPrefix + "node_relay_addr"
+
+	relayRefreshInterval = time.Second
+)
+
+// ChannelRelayRepository хранит ноды каналов в Redis и держит локальный кеш
+// удаленных нод для горячего пути пересылки RTP
+type ChannelRelayRepository struct {
+	client goredis.UniversalClient
+	self   runtime.Node
+
+	mu      sync.RWMutex
+	joined  map[uuid.UUID]struct{}
+	remotes map[uuid.UUID][]runtime.Node
+}
+
+func NewChannelRelayRepository(client goredis.UniversalClient, self runtime.Node) *ChannelRelayRepository {
+	return &ChannelRelayRepository{
+		client:  client,
+		self:    self,
+		joined:  make(map[uuid.UUID]struct{}),
+		remotes: make(map[uuid.UUID][]runtime.Node),
+	}
+}
+
+// Run публикует адрес ноды и обновляет кеш удаленных нод до отмены ctx
+func (r *ChannelRelayRepository) Run(ctx context.Context) {
+	if err := r.client.HSet(ctx, nodeRelayAddrKey, r.self.ID, r.self.RelayAddr).Err(); err != nil {
+		slog.Error("register relay addr", slog.Any(constant.Error, err))
+	}
+
+	ticker := time.NewTicker(relayRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.leaveAll(context.WithoutCancel(ctx))
+			return
+		case <-ticker.C:
+			r.refresh(ctx)
+		}
+	}
+}
+
+func (r *ChannelRelayRepository) Join(ctx context.Context, channelID uuid.UUID) {
+	r.mu.Lock()
+	r.joined[channelID] = struct{}{}
+	r.mu.Unlock()
+
+	if err := r.client.SAdd(ctx, channelRelaysKey+channelID.String(), r.self.ID).Err(); err != nil {
+		slog.Error("join channel relay", slog.Any(constant.Error, err), slog.Any(constant.ChannelID, channelID))
+	}
+
+	r.refreshChannel(ctx, channelID)
+}
+
+func (r *ChannelRelayRepository) Leave(ctx context.Context, channelID uuid.UUID) {
+	r.mu.Lock()
+	delete(r.joined, channelID)
+	delete(r.remotes, channelID)
+	r.mu.Unlock()
+
+	if err := r.client.SRem(ctx, channelRelaysKey+channelID.String(), r.self.ID).Err(); err != nil {
+		slog.Error("leave channel relay", slog.Any(constant.Error, err), slog.Any(constant.ChannelID, channelID))
+	}
+}
+
+func (r *ChannelRelayRepository) RemoteNodes(channelID uuid.UUID) []runtime.Node {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.remotes[channelID]
+}
+
+func (r *ChannelRelayRepository) refresh(ctx context.Context) {
+	r.mu.RLock()
+	channelIDs := make([]uuid.UUID, 0, len(r.joined))
+	for channelID := range r.joined {
+		channelIDs = append(channelIDs, channelID)
+	}
+	r.mu.RUnlock()
+
+	for _, channelID := range channelIDs {
+		r.refreshChannel(ctx, channelID)
+	}
+}
+
+func (r *ChannelRelayRepository) refreshChannel(ctx context.Context, channelID uuid.UUID) {
+	nodeIDs, err := r.client.SMembers(ctx, channelRelaysKey+channelID.String()).Result()
+	if err != nil {
+		slog.Error("get channel relays", slog.Any(constant.Error, err), slog.Any(constant.ChannelID, channelID))
+		return
+	}
+
+	otherIDs := make([]string, 0, len(nodeIDs))
+	for _, nodeID := range nodeIDs {
+		if nodeID != r.self.ID {
+			otherIDs = append(otherIDs, nodeID)
+		}
+	}
+
+	alive, err := aliveNodes(ctx, r.client, otherIDs)
+	if err != nil {
+		slog.Error("get alive relay nodes", slog.Any(constant.Error, err))
+		return
+	}
+
+	remotes := make([]runtime.Node, 0, len(otherIDs))
+
+	for _, nodeID := range otherIDs {
+		if !alive[nodeID] {
+			continue
+		}
+
+		addr, err := r.client.HGet(ctx, nodeRelayAddrKey, nodeID).Result()
+		if err != nil || addr == "" {
+			continue
+		}
+
+		remotes = append(remotes, runtime.Node{ID: nodeID, RelayAddr: addr})
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Канал могли покинуть, пока шел запрос
+	if _, ok := r.joined[channelID]; ok {
+		r.remotes[channelID] = remotes
+	}
+}
+
+func (r *ChannelRelayRepository) leaveAll(ctx context.Context) {
+	r.mu.Lock()
+	channelIDs := make([]uuid.UUID, 0, len(r.joined))
+	for channelID := range r.joined {
+		channelIDs = append(channelIDs, channelID)
+	}
+	r.mu.Unlock()
+
+	for _, channelID := range channelIDs {
+		r.Leave(ctx, channelID)
+	}
+}
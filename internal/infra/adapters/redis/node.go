@@ -0,0 +1,73 @@
+package redis
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/qrave1/RoomSpeak/internal/application/constant"
+	"github.com/qrave1/RoomSpeak/internal/domain/runtime"
+)
+
+const (
+	// nodesKey - hash node_id -> public url
+	nodesKey = keyPrefix + "nodes"
+	// nodeAliveKey - ключ с TTL, пока он есть, нода считается живой
+	nodeAliveKey = keyPrefix + "node_alive:"
+
+	nodeAliveTTL      = 30 * time.Second
+	nodeHeartbeatTick = 10 * time.Second
+)
+
+// RunNodeHeartbeat регистрирует ноду и продлевает ее liveness ключ до отмены ctx
+func RunNodeHeartbeat(ctx context.Context, client goredis.UniversalClient, self runtime.Node) {
+	ticker := time.NewTicker(nodeHeartbeatTick)
+	defer ticker.Stop()
+
+	for {
+		_, err := client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+			pipe.HSet(ctx, nodesKey, self.ID, self.PublicURL)
+			pipe.Set(ctx, nodeAliveKey+self.ID, 1, nodeAliveTTL)
+
+			return nil
+		})
+		if err != nil && ctx.Err() == nil {
+			slog.Error("node heartbeat", slog.Any(constant.Error, err))
+		}
+
+		select {
+		case <-ctx.Done():
+			// Нода останавливается штатно - сразу помечаем ее мертвой
+			client.Del(context.WithoutCancel(ctx), nodeAliveKey+self.ID)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// aliveNodes проверяет, какие из нод живы
+func aliveNodes(ctx context.Context, client goredis.UniversalClient, nodeIDs []string) (map[string]bool, error) {
+	alive := make(map[string]bool, len(nodeIDs))
+
+	if len(nodeIDs) == 0 {
+		return alive, nil
+	}
+
+	keys := make([]string, len(nodeIDs))
+	for i, nodeID := range nodeIDs {
+		keys[i] = nodeAliveKey + nodeID
+	}
+
+	values, err := client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, value := range values {
+		alive[nodeIDs[i]] = value != nil
+	}
+
+	return alive, nil
+}
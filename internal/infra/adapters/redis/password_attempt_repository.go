@@ -0,0 +1,51 @@
+package redis
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/qrave1/RoomSpeak/internal/application/constant"
+	"github.com/qrave1/RoomSpeak/internal/infra/adapters/memory"
+)
+
+// passwordAttemptsKey - счетчик попыток, живет до конца окна
+const passwordAttemptsKey = keyPrefix + "password_attempts:"
+
+// reserveAttemptScript увеличивает счетчик и начинает окно на первой попытке
+var reserveAttemptScript = goredis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if count == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return {count, redis.call("PTTL", KEYS[1])}
+`)
+
+type passwordAttemptRepository struct {
+	client goredis.UniversalClient
+}
+
+// NewPasswordAttemptRepository - общие для всех нод счетчики, чтобы перебор пароля
+// не получал свой лимит на каждой ноде
+func NewPasswordAttemptRepository(client goredis.UniversalClient) memory.PasswordAttemptRepository {
+	return &passwordAttemptRepository{client: client}
+}
+
+func (r *passwordAttemptRepository) Reserve(ctx context.Context, key string, window time.Duration) (int, time.Duration) {
+	result, err := reserveAttemptScript.Run(ctx, r.client, []string{passwordAttemptsKey + key}, window.Milliseconds()).Int64Slice()
+	if err != nil || len(result) != 2 {
+		// Без Redis попытка не учитывается, вход не блокируется из-за недоступного счетчика
+		slog.Error("reserve password attempt in redis", slog.Any(constant.Error, err))
+		return 1, window
+	}
+
+	return int(result[0]), time.Duration(result[1]) * time.Millisecond
+}
+
+func (r *passwordAttemptRepository) Reset(ctx context.Context, key string) {
+	if err := r.client.Del(ctx, passwordAttemptsKey+key).Err(); err != nil {
+		slog.Error("reset password attempts in redis", slog.Any(constant.Error, err))
+	}
+}
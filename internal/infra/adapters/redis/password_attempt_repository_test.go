@@ -0,0 +1,38 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPasswordAttemptRepository(t *testing.T) {
+	ctx := context.Background()
+	server, client := newTestRedis(t)
+
+	repo := NewPasswordAttemptRepository(client)
+
+	for want := 1; want <= 3; want++ {
+		count, resetIn := repo.Reserve(ctx, "user", time.Minute)
+		if count != want || resetIn <= 0 || resetIn > time.Minute {
+			t.Fatalf("attempt %d: Reserve = %d, %v", want, count, resetIn)
+		}
+	}
+
+	if count, _ := repo.Reserve(ctx, "other", time.Minute); count != 1 {
+		t.Fatalf("other key count = %d, want 1", count)
+	}
+
+	// Окно не продлевается следующими попытками и заканчивается через window от первой
+	server.FastForward(time.Minute)
+
+	if count, _ := repo.Reserve(ctx, "user", time.Minute); count != 1 {
+		t.Fatalf("count after window = %d, want 1", count)
+	}
+
+	repo.Reset(ctx, "user")
+
+	if count, _ := repo.Reserve(ctx, "user", time.Minute); count != 1 {
+		t.Fatalf("count after reset = %d, want 1", count)
+	}
+}
@@ -0,0 +1,110 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/qrave1/RoomSpeak/internal/application/constant"
+	"github.com/qrave1/RoomSpeak/internal/domain/runtime"
+	"github.com/qrave1/RoomSpeak/internal/infra/adapters/memory"
+)
+
+// presenceKey - hash user_id -> runtime.Presence
+const presenceKey = keyPrefix + "presence"
+
+type presenceRepository struct {
+	client goredis.UniversalClient
+}
+
+func NewPresenceRepository(client goredis.UniversalClient) memory.PresenceRepository {
+	return &presenceRepository{client: client}
+}
+
+func (r *presenceRepository) Get(ctx context.Context, userID uuid.UUID) (runtime.Presence, bool) {
+	data, err := r.client.HGet(ctx, presenceKey, userID.String()).Bytes()
+	if err != nil {
+		if !errors.Is(err, goredis.Nil) {
+			slog.Error("get presence from redis", slog.Any(constant.Error, err), slog.Any(constant.UserID, userID))
+		}
+
+		return runtime.Presence{}, false
+	}
+
+	var presence runtime.Presence
+	if err = json.Unmarshal(data, &presence); err != nil {
+		slog.Error("unmarshal presence", slog.Any(constant.Error, err))
+		return runtime.Presence{}, false
+	}
+
+	return presence, true
+}
+
+func (r *presenceRepository) Set(ctx context.Context, presence runtime.Presence) {
+	data, err := json.Marshal(presence)
+	if err != nil {
+		slog.Error("marshal presence", slog.Any(constant.Error, err))
+		return
+	}
+
+	if err = r.client.HSet(ctx, presenceKey, presence.UserID.String(), data).Err(); err != nil {
+		slog.Error("set presence in redis", slog.Any(constant.Error, err), slog.Any(constant.UserID, presence.UserID))
+	}
+}
+
+func (r *presenceRepository) GetConnected(ctx context.Context) []runtime.Presence {
+	values, err := r.client.HGetAll(ctx, presenceKey).Result()
+	if err != nil {
+		slog.Error("get presences from redis", slog.Any(constant.Error, err))
+		return nil
+	}
+
+	presences := make([]runtime.Presence, 0, len(values))
+
+	for _, value := range values {
+		var presence runtime.Presence
+		if err = json.Unmarshal([]byte(value), &presence); err != nil {
+			continue
+		}
+
+		if presence.Connected {
+			presences = append(presences, presence)
+		}
+	}
+
+	return presences
+}
+
+func (r *presenceRepository) DeleteDisconnected(ctx context.Context, before time.Time) {
+	values, err := r.client.HGetAll(ctx, presenceKey).Result()
+	if err != nil {
+		slog.Error("get presences from redis", slog.Any(constant.Error, err))
+		return
+	}
+
+	var userIDs []string
+
+	for userID, value := range values {
+		var presence runtime.Presence
+		if err = json.Unmarshal([]byte(value), &presence); err != nil {
+			continue
+		}
+
+		if presence.DisconnectedBefore(before) {
+			userIDs = append(userIDs, userID)
+		}
+	}
+
+	if len(userIDs) == 0 {
+		return
+	}
+
+	if err = r.client.HDel(ctx, presenceKey, userIDs...).Err(); err != nil {
+		slog.Error("delete disconnected presences from redis", slog.Any(constant.Error, err))
+	}
+}
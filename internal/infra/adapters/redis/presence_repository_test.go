@@ -0,0 +1,60 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/qrave1/RoomSpeak/internal/domain/runtime"
+)
+
+func TestPresenceRepository(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestRedis(t)
+
+	repo := NewPresenceRepository(client)
+
+	online := runtime.Presence{UserID: uuid.New(), Status: runtime.PresenceDND, Connected: true, CustomText: "busy"}
+	offline := runtime.Presence{UserID: uuid.New(), Status: runtime.PresenceOnline}
+
+	repo.Set(ctx, online)
+	repo.Set(ctx, offline)
+
+	got, ok := repo.Get(ctx, online.UserID)
+	if !ok || got.Status != runtime.PresenceDND || got.CustomText != "busy" {
+		t.Fatalf("Get(online) = %+v, %v; want stored presence", got, ok)
+	}
+
+	if _, ok = repo.Get(ctx, uuid.New()); ok {
+		t.Fatalf("Get of unknown user returned presence")
+	}
+
+	connected := repo.GetConnected(ctx)
+	if len(connected) != 1 || connected[0].UserID != online.UserID {
+		t.Fatalf("GetConnected = %+v, want only connected user", connected)
+	}
+
+	// Set заменяет присутствие целиком
+	online.Connected = false
+	repo.Set(ctx, online)
+
+	if connected = repo.GetConnected(ctx); len(connected) != 0 {
+		t.Fatalf("GetConnected after disconnect = %+v, want empty", connected)
+	}
+
+	// Забываются только отключившиеся раньше границы
+	longAgo := time.Now().Add(-time.Hour)
+	online.DisconnectedAt = &longAgo
+	repo.Set(ctx, online)
+
+	repo.DeleteDisconnected(ctx, time.Now().Add(-time.Minute))
+
+	if _, ok = repo.Get(ctx, online.UserID); ok {
+		t.Fatalf("presence disconnected long ago was not deleted")
+	}
+	if _, ok = repo.Get(ctx, offline.UserID); !ok {
+		t.Fatalf("presence without disconnect time was deleted")
+	}
+}
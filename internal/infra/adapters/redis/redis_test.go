@@ -0,0 +1,36 @@
+package redis
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// newTestRedis поднимает Redis в процессе теста
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, goredis.UniversalClient) {
+	t.Helper()
+
+	server := miniredis.RunT(t)
+
+	client := goredis.NewClient(&goredis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	return server, client
+}
+
+// markAlive делает ноду живой так же, как это делает RunNodeHeartbeat
+func markAlive(t *testing.T, client goredis.UniversalClient, nodeID, publicURL string) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	if err := client.HSet(ctx, nodesKey, nodeID, publicURL).Err(); err != nil {
+		t.Fatalf("register node: %v", err)
+	}
+
+	if err := client.Set(ctx, nodeAliveKey+nodeID, 1, nodeAliveTTL).Err(); err != nil {
+		t.Fatalf("mark node alive: %v", err)
+	}
+}
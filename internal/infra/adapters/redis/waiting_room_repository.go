@@ -0,0 +1,159 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/qrave1/RoomSpeak/internal/application/constant"
+	"github.com/qrave1/RoomSpeak/internal/infra/adapters/memory"
+)
+
+const (
+	// waitingRoomKey - hash user_id -> время запроса в unix nano, очередь канала
+	waitingRoomKey = keyPrefix + "waiting_room:"
+	// waitingUserKey - set channel_id, в которые ждет входа пользователь
+	waitingUserKey = keyPrefix + "waiting_user:"
+
+	// waitingAdmittedChannel - pub/sub канал впущенных пользователей, их подключает нода их WebSocket
+	waitingAdmittedChannel = keyPrefix + "waiting_admitted"
+
+	// waitingRoomTTL - запросы упавшей ноды не висят в очереди вечно
+	waitingRoomTTL = 24 * time.Hour
+)
+
+type waitingAdmission struct {
+	ChannelID uuid.UUID `json:"channel_id"`
+	UserID    uuid.UUID `json:"user_id"`
+}
+
+type waitingRoomRepository struct {
+	client goredis.UniversalClient
+}
+
+// NewWaitingRoomRepository - общие для всех нод залы ожидания. Модератор может
+// впустить пользователя, подключенного к другой ноде
+func NewWaitingRoomRepository(client goredis.UniversalClient) memory.WaitingRoomRepository {
+	return &waitingRoomRepository{client: client}
+}
+
+func (r *waitingRoomRepository) Add(ctx context.Context, channelID, userID uuid.UUID, requestedAt time.Time) {
+	roomKey := waitingRoomKey + channelID.String()
+	userKey := waitingUserKey + userID.String()
+
+	_, err := r.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.HSetNX(ctx, roomKey, userID.String(), requestedAt.UnixNano())
+		pipe.Expire(ctx, roomKey, waitingRoomTTL)
+		pipe.SAdd(ctx, userKey, channelID.String())
+		pipe.Expire(ctx, userKey, waitingRoomTTL)
+
+		return nil
+	})
+	if err != nil {
+		slog.Error("add waiting user to redis", slog.Any(constant.Error, err), slog.Any(constant.UserID, userID))
+	}
+}
+
+func (r *waitingRoomRepository) Remove(ctx context.Context, channelID, userID uuid.UUID) bool {
+	var removed *goredis.IntCmd
+
+	_, err := r.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		removed = pipe.HDel(ctx, waitingRoomKey+channelID.String(), userID.String())
+		pipe.SRem(ctx, waitingUserKey+userID.String(), channelID.String())
+
+		return nil
+	})
+	if err != nil {
+		slog.Error("remove waiting user from redis", slog.Any(constant.Error, err), slog.Any(constant.UserID, userID))
+		return false
+	}
+
+	return removed.Val() > 0
+}
+
+func (r *waitingRoomRepository) Get(ctx context.Context, channelID uuid.UUID) map[uuid.UUID]time.Time {
+	values, err := r.client.HGetAll(ctx, waitingRoomKey+channelID.String()).Result()
+	if err != nil {
+		slog.Error("get waiting room from redis", slog.Any(constant.Error, err), slog.Any(constant.ChannelID, channelID))
+		return nil
+	}
+
+	queue := make(map[uuid.UUID]time.Time, len(values))
+
+	for rawUserID, rawRequestedAt := range values {
+		userID, err := uuid.Parse(rawUserID)
+		if err != nil {
+			continue
+		}
+
+		requestedAt, err := strconv.ParseInt(rawRequestedAt, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		queue[userID] = time.Unix(0, requestedAt)
+	}
+
+	return queue
+}
+
+func (r *waitingRoomRepository) ChannelsOf(ctx context.Context, userID uuid.UUID) []uuid.UUID {
+	rawChannelIDs, err := r.client.SMembers(ctx, waitingUserKey+userID.String()).Result()
+	if err != nil {
+		slog.Error("get waiting channels from redis", slog.Any(constant.Error, err), slog.Any(constant.UserID, userID))
+		return nil
+	}
+
+	channelIDs := make([]uuid.UUID, 0, len(rawChannelIDs))
+
+	for _, rawChannelID := range rawChannelIDs {
+		if channelID, err := uuid.Parse(rawChannelID); err == nil {
+			channelIDs = append(channelIDs, channelID)
+		}
+	}
+
+	return channelIDs
+}
+
+func (r *waitingRoomRepository) Admit(ctx context.Context, channelID, userID uuid.UUID) {
+	data, err := json.Marshal(waitingAdmission{ChannelID: channelID, UserID: userID})
+	if err != nil {
+		slog.Error("marshal waiting admission", slog.Any(constant.Error, err))
+		return
+	}
+
+	if err = r.client.Publish(ctx, waitingAdmittedChannel, data).Err(); err != nil {
+		slog.Error("publish waiting admission", slog.Any(constant.Error, err), slog.Any(constant.UserID, userID))
+	}
+}
+
+func (r *waitingRoomRepository) WatchAdmitted(ctx context.Context, handle func(channelID, userID uuid.UUID)) {
+	sub := r.client.Subscribe(ctx, waitingAdmittedChannel)
+	defer sub.Close()
+
+	ch := sub.Channel()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+
+			var admission waitingAdmission
+			if err := json.Unmarshal([]byte(msg.Payload), &admission); err != nil {
+				slog.Error("unmarshal waiting admission", slog.Any(constant.Error, err))
+				continue
+			}
+
+			handle(admission.ChannelID, admission.UserID)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestWaitingRoomRepository(t *testing.T) {
+	ctx := context.Background()
+	_, client := newTestRedis(t)
+
+	repo := NewWaitingRoomRepository(client)
+
+	channelA, channelB := uuid.New(), uuid.New()
+	alice, bob := uuid.New(), uuid.New()
+
+	first := time.Now().Truncate(time.Millisecond)
+
+	repo.Add(ctx, channelA, alice, first)
+	repo.Add(ctx, channelA, bob, first.Add(time.Second))
+	repo.Add(ctx, channelB, alice, first)
+
+	// Повторный запрос не сдвигает место в очереди
+	repo.Add(ctx, channelA, alice, first.Add(time.Minute))
+
+	queue := repo.Get(ctx, channelA)
+	if len(queue) != 2 || !queue[alice].Equal(first) {
+		t.Fatalf("queue of channel a = %v, want alice at %v and bob", queue, first)
+	}
+
+	if got := repo.ChannelsOf(ctx, alice); len(got) != 2 {
+		t.Fatalf("alice waits in %v, want two channels", got)
+	}
+
+	if !repo.Remove(ctx, channelA, alice) {
+		t.Fatalf("Remove(alice) = false, want true")
+	}
+
+	if repo.Remove(ctx, channelA, alice) {
+		t.Fatalf("second Remove(alice) = true, want false")
+	}
+
+	if got := repo.ChannelsOf(ctx, alice); len(got) != 1 || got[0] != channelB {
+		t.Fatalf("alice waits in %v, want only channel b", got)
+	}
+
+	if queue = repo.Get(ctx, channelA); len(queue) != 1 {
+		t.Fatalf("queue of channel a after remove = %v, want only bob", queue)
+	}
+}
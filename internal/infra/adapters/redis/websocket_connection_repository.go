@@ -0,0 +1,223 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/qrave1/RoomSpeak/internal/application/constant"
+	"github.com/qrave1/RoomSpeak/internal/infra/adapters/memory"
+)
+
+const (
+	// wsConnectionsKey - hash user_id -> node_id, к которой подключен WebSocket пользователя
+	wsConnectionsKey = keyPrefix + "ws_connections"
+	// nodeWSChannel - pub/sub канал ноды для сообщений ее WebSocket клиентам
+	nodeWSChannel = keyPrefix + "node_ws:"
+)
+
+// routedMessage - сообщение для пользователя, подключенного к другой ноде
+type routedMessage struct {
+	UserID  uuid.UUID       `json:"user_id"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+	// CloseReason - вместо сообщения закрыть соединение пользователя с этой причиной
+	CloseReason string `json:"close_reason,omitempty"`
+}
+
+// WebsocketConnectionRepository хранит соединения локально, а запись пользователю
+// с другой ноды отправляет через Redis pub/sub
+type WebsocketConnectionRepository struct {
+	client goredis.UniversalClient
+	nodeID string
+
+	local memory.WebsocketConnectionRepository
+}
+
+func NewWebsocketConnectionRepository(
+	client goredis.UniversalClient,
+	nodeID string,
+	local memory.WebsocketConnectionRepository,
+) *WebsocketConnectionRepository {
+	return &WebsocketConnectionRepository{
+		client: client,
+		nodeID: nodeID,
+		local:  local,
+	}
+}
+
+// Run принимает сообщения, адресованные клиентам этой ноды, до отмены ctx
+func (w *WebsocketConnectionRepository) Run(ctx context.Context) {
+	sub := w.client.Subscribe(ctx, nodeWSChannel+w.nodeID)
+	defer sub.Close()
+
+	ch := sub.Channel()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+
+			var routed routedMessage
+			if err := json.Unmarshal([]byte(msg.Payload), &routed); err != nil {
+				slog.Error("unmarshal routed websocket message", slog.Any(constant.Error, err))
+				continue
+			}
+
+			if routed.CloseReason != "" {
+				w.local.Close(routed.UserID, routed.CloseReason)
+				continue
+			}
+
+			w.local.Write(routed.UserID, routed.Payload)
+		}
+	}
+}
+
+func (w *WebsocketConnectionRepository) Add(userID uuid.UUID, conn *websocket.Conn) {
+	w.local.Add(userID, conn)
+
+	if err := w.client.HSet(context.Background(), wsConnectionsKey, userID.String(), w.nodeID).Err(); err != nil {
+		slog.Error("register websocket in redis", slog.Any(constant.Error, err), slog.Any(constant.UserID, userID))
+	}
+}
+
+func (w *WebsocketConnectionRepository) Remove(userID uuid.UUID) {
+	w.local.Remove(userID)
+
+	ctx := context.Background()
+
+	// Пользователь мог уже переподключиться к другой ноде, удаляем только свою запись
+	nodeID, err := w.client.HGet(ctx, wsConnectionsKey, userID.String()).Result()
+	if err != nil || nodeID != w.nodeID {
+		return
+	}
+
+	if err = w.client.HDel(ctx, wsConnectionsKey, userID.String()).Err(); err != nil {
+		slog.Error("unregister websocket in redis", slog.Any(constant.Error, err), slog.Any(constant.UserID, userID))
+	}
+}
+
+func (w *WebsocketConnectionRepository) Write(userID uuid.UUID, payload any) {
+	if w.local.IsConnected(userID) {
+		w.local.Write(userID, payload)
+		return
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("marshal routed websocket payload", slog.Any(constant.Error, err))
+		return
+	}
+
+	w.route(routedMessage{UserID: userID, Payload: data})
+}
+
+func (w *WebsocketConnectionRepository) Close(userID uuid.UUID, reason string) {
+	if w.local.IsConnected(userID) {
+		w.local.Close(userID, reason)
+		return
+	}
+
+	w.route(routedMessage{UserID: userID, CloseReason: reason})
+}
+
+// route отправляет сообщение ноде, к которой подключен пользователь
+func (w *WebsocketConnectionRepository) route(routed routedMessage) {
+	ctx := context.Background()
+
+	nodeID, err := w.client.HGet(ctx, wsConnectionsKey, routed.UserID.String()).Result()
+	if err != nil {
+		if !errors.Is(err, goredis.Nil) {
+			slog.Error("get websocket node from redis", slog.Any(constant.Error, err), slog.Any(constant.UserID, routed.UserID))
+		}
+
+		return
+	}
+
+	if nodeID == w.nodeID {
+		// Запись осталась от прошлого запуска этой ноды
+		return
+	}
+
+	msg, err := json.Marshal(routed)
+	if err != nil {
+		slog.Error("marshal routed websocket message", slog.Any(constant.Error, err))
+		return
+	}
+
+	if err = w.client.Publish(ctx, nodeWSChannel+nodeID, msg).Err(); err != nil {
+		slog.Error("publish routed websocket message", slog.Any(constant.Error, err), slog.Any(constant.UserID, routed.UserID))
+	}
+}
+
+func (w *WebsocketConnectionRepository) IsConnected(userID uuid.UUID) bool {
+	return w.local.IsConnected(userID)
+}
+
+// GetAllConnected возвращает пользователей всех живых нод
+func (w *WebsocketConnectionRepository) GetAllConnected() []uuid.UUID {
+	ctx := context.Background()
+
+	connections, err := w.client.HGetAll(ctx, wsConnectionsKey).Result()
+	if err != nil {
+		slog.Error("get websocket connections from redis", slog.Any(constant.Error, err))
+		return w.local.GetAllConnected()
+	}
+
+	nodeIDs := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for _, nodeID := range connections {
+		if !seen[nodeID] {
+			seen[nodeID] = true
+			nodeIDs = append(nodeIDs, nodeID)
+		}
+	}
+
+	alive, err := aliveNodes(ctx, w.client, nodeIDs)
+	if err != nil {
+		slog.Error("get alive nodes from redis", slog.Any(constant.Error, err))
+		return w.local.GetAllConnected()
+	}
+
+	userIDs := make([]uuid.UUID, 0, len(connections))
+
+	for userIDStr, nodeID := range connections {
+		// Соединения упавших нод не считаются
+		if !alive[nodeID] && nodeID != w.nodeID {
+			continue
+		}
+
+		userID, err := uuid.Parse(userIDStr)
+		if err != nil {
+			continue
+		}
+
+		if nodeID == w.nodeID && !w.local.IsConnected(userID) {
+			continue
+		}
+
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs
+}
+
+// CloseAll закрывает только соединения этой ноды
+func (w *WebsocketConnectionRepository) CloseAll() {
+	w.local.CloseAll()
+}
+
+// ForceCloseAll закрывает только соединения этой ноды
+func (w *WebsocketConnectionRepository) ForceCloseAll() {
+	w.local.ForceCloseAll()
+}
@@ -0,0 +1,38 @@
+package relay
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/pion/rtp"
+)
+
+// PacketHandler получает RTP пакет, пришедший с другой ноды
+type PacketHandler func(channelID, senderID uuid.UUID, pkt *rtp.Packet)
+
+// Transport пересылает RTP пакеты каналов между нодами
+type Transport interface {
+	// Run принимает пакеты от других нод до отмены ctx
+	Run(ctx context.Context)
+
+	// Send отправляет пакет ноде по адресу addr
+	Send(addr string, channelID, senderID uuid.UUID, pkt *rtp.Packet) error
+
+	// OnPacket задает обработчик входящих пакетов
+	OnPacket(handler PacketHandler)
+}
+
+// disabledTransport используется, когда каскад выключен
+type disabledTransport struct{}
+
+func NewDisabledTransport() Transport {
+	return disabledTransport{}
+}
+
+func (disabledTransport) Run(ctx context.Context) {}
+
+func (disabledTransport) Send(addr string, channelID, senderID uuid.UUID, pkt *rtp.Packet) error {
+	return nil
+}
+
+func (disabledTransport) OnPacket(handler PacketHandler) {}
@@ -0,0 +1,39 @@
+package relay
+
+// replayWindowSize - на сколько номеров назад принимаются пакеты, пришедшие не по порядку
+const replayWindowSize = 1024
+
+// replayWindow отбрасывает повторы и слишком старые номера датаграмм одного отправителя.
+// Кольцевая битовая карта по номеру, как в RFC 6479
+type replayWindow struct {
+	highest uint64
+	seen    [replayWindowSize / 64]uint64
+}
+
+// accept отмечает номер и возвращает false, если он уже был или вышел из окна
+func (w *replayWindow) accept(seq uint64) bool {
+	if seq > w.highest {
+		// Биты номеров, которые выпадают из окна, переиспользуются для новых
+		from := w.highest + 1
+		if seq-w.highest > replayWindowSize {
+			from = seq - replayWindowSize + 1
+		}
+
+		for s := from; s <= seq; s++ {
+			w.seen[(s/64)%uint64(len(w.seen))] &^= 1 << (s % 64)
+		}
+
+		w.highest = seq
+	} else if w.highest-seq >= replayWindowSize {
+		return false
+	}
+
+	word, bit := (seq/64)%uint64(len(w.seen)), uint64(1)<<(seq%64)
+	if w.seen[word]&bit != 0 {
+		return false
+	}
+
+	w.seen[word] |= bit
+
+	return true
+}
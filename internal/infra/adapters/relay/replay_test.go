@@ -0,0 +1,28 @@
+package relay
+
+import "testing"
+
+func TestReplayWindow(t *testing.T) {
+	var w replayWindow
+
+	steps := []struct {
+		seq  uint64
+		want bool
+	}{
+		{seq: 1_000_000, want: true},
+		{seq: 1_000_001, want: true},
+		{seq: 1_000_001, want: false}, // повтор
+		{seq: 999_990, want: true},    // опоздал, но в окне
+		{seq: 999_990, want: false},
+		{seq: 1_000_000 - replayWindowSize, want: false}, // вышел из окна
+		{seq: 1_005_000, want: true},                     // скачок дальше окна
+		{seq: 1_000_001, want: false},
+		{seq: 1_004_000, want: true}, // бит после скачка очищен
+	}
+
+	for i, step := range steps {
+		if got := w.accept(step.seq); got != step.want {
+			t.Fatalf("step %d: accept(%d) = %v, want %v", i, step.seq, got, step.want)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package relay
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pion/rtp"
+
+	"github.com/qrave1/RoomSpeak/internal/application/constant"
+)
+
+// Формат датаграммы: magic | channel_id | sender_id | seq | hmac | rtp.
+// seq - номер датаграммы отправляющей ноды, подписывается вместе с остальным заголовком
+const (
+	magic     = "RSR2"
+	seqSize   = 8
+	macSize   = 16
+	headerLen = len(magic) + 16 + 16 + seqSize + macSize
+
+	maxDatagramSize = 1500
+)
+
+type udpTransport struct {
+	conn   *net.UDPConn
+	secret []byte
+
+	// seq начинается с текущего времени, чтобы после перезапуска ноды номера продолжали расти
+	seq atomic.Uint64
+	// windows - окна повторов по адресу отправителя, с ними работает только Run
+	windows map[netip.AddrPort]*replayWindow
+
+	// addrs кеширует разрезолвленные адреса нод
+	addrs   map[string]*net.UDPAddr
+	addrsMu sync.RWMutex
+
+	handler   PacketHandler
+	handlerMu sync.RWMutex
+}
+
+// NewUDPTransport открывает UDP сокет для обмена RTP с другими нодами.
+// Пакеты подписываются общим секретом, чужие пакеты отбрасываются
+func NewUDPTransport(listenAddr, secret string) (Transport, error) {
+	addr, err := net.ResolveUDPAddr("udp", listenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("resolve relay listen addr: %w", err)
+	}
+
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen relay udp: %w", err)
+	}
+
+	t := &udpTransport{
+		conn:    conn,
+		secret:  []byte(secret),
+		windows: make(map[netip.AddrPort]*replayWindow),
+		addrs:   make(map[string]*net.UDPAddr),
+	}
+	t.seq.Store(uint64(time.Now().UnixNano()))
+
+	return t, nil
+}
+
+func (t *udpTransport) OnPacket(handler PacketHandler) {
+	t.handlerMu.Lock()
+	defer t.handlerMu.Unlock()
+
+	t.handler = handler
+}
+
+func (t *udpTransport) Run(ctx context.Context) {
+	go func() {
+		<-ctx.Done()
+		t.conn.Close()
+	}()
+
+	buf := make([]byte, maxDatagramSize)
+
+	for {
+		n, from, err := t.conn.ReadFromUDPAddrPort(buf)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return
+			}
+
+			slog.Error("read relay datagram", slog.Any(constant.Error, err))
+			continue
+		}
+
+		channelID, senderID, seq, pkt, err := t.decode(buf[:n])
+		if err != nil {
+			slog.Warn("drop relay datagram", slog.Any(constant.Error, err))
+			continue
+		}
+
+		// Подпись не защищает от повторной отправки перехваченной датаграммы
+		if !t.acceptSeq(from, seq) {
+			slog.Warn("drop replayed relay datagram", slog.String("from", from.String()))
+			continue
+		}
+
+		t.handlerMu.RLock()
+		handler := t.handler
+		t.handlerMu.RUnlock()
+
+		if handler != nil {
+			handler(channelID, senderID, pkt)
+		}
+	}
+}
+
+func (t *udpTransport) Send(addr string, channelID, senderID uuid.UUID, pkt *rtp.Packet) error {
+	udpAddr, err := t.resolve(addr)
+	if err != nil {
+		return err
+	}
+
+	payload, err := pkt.Marshal()
+	if err != nil {
+		return fmt.Errorf("marshal rtp: %w", err)
+	}
+
+	seq := t.seq.Add(1)
+
+	datagram := make([]byte, 0, headerLen+len(payload))
+	datagram = append(datagram, magic...)
+	datagram = append(datagram, channelID[:]...)
+	datagram = append(datagram, senderID[:]...)
+	datagram = binary.BigEndian.AppendUint64(datagram, seq)
+	datagram = append(datagram, t.sign(channelID, senderID, seq, payload)...)
+	datagram = append(datagram, payload...)
+
+	_, err = t.conn.WriteToUDP(datagram, udpAddr)
+
+	return err
+}
+
+func (t *udpTransport) decode(datagram []byte) (uuid.UUID, uuid.UUID, uint64, *rtp.Packet, error) {
+	if len(datagram) <= headerLen || !bytes.HasPrefix(datagram, []byte(magic)) {
+		return uuid.Nil, uuid.Nil, 0, nil, errors.New("malformed datagram")
+	}
+
+	offset := len(magic)
+
+	channelID, _ := uuid.FromBytes(datagram[offset : offset+16])
+	offset += 16
+
+	senderID, _ := uuid.FromBytes(datagram[offset : offset+16])
+	offset += 16
+
+	seq := binary.BigEndian.Uint64(datagram[offset : offset+seqSize])
+	offset += seqSize
+
+	mac := datagram[offset : offset+macSize]
+	payload := datagram[headerLen:]
+
+	if !hmac.Equal(mac, t.sign(channelID, senderID, seq, payload)) {
+		return uuid.Nil, uuid.Nil, 0, nil, errors.New("invalid datagram signature")
+	}
+
+	// buf переиспользуется для следующих пакетов, поэтому копируем
+	pkt := &rtp.Packet{}
+	if err := pkt.Unmarshal(bytes.Clone(payload)); err != nil {
+		return uuid.Nil, uuid.Nil, 0, nil, fmt.Errorf("unmarshal rtp: %w", err)
+	}
+
+	return channelID, senderID, seq, pkt, nil
+}
+
+// acceptSeq пропускает номер датаграммы, если отправитель еще не присылал его
+func (t *udpTransport) acceptSeq(from netip.AddrPort, seq uint64) bool {
+	window, ok := t.windows[from]
+	if !ok {
+		window = &replayWindow{}
+		t.windows[from] = window
+	}
+
+	return window.accept(seq)
+}
+
+func (t *udpTransport) sign(channelID, senderID uuid.UUID, seq uint64, payload []byte) []byte {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write(channelID[:])
+	mac.Write(senderID[:])
+	mac.Write(binary.BigEndian.AppendUint64(nil, seq))
+	mac.Write(payload)
+
+	return mac.Sum(nil)[:macSize]
+}
+
+func (t *udpTransport) resolve(addr string) (*net.UDPAddr, error) {
+	t.addrsMu.RLock()
+	udpAddr, ok := t.addrs[addr]
+	t.addrsMu.RUnlock()
+
+	if ok {
+		return udpAddr, nil
+	}
+
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("resolve relay addr: %w", err)
+	}
+
+	t.addrsMu.Lock()
+	t.addrs[addr] = udpAddr
+	t.addrsMu.Unlock()
+
+	return udpAddr, nil
+}
@@ -0,0 +1,162 @@
+package rtc
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/interceptor/pkg/cc"
+	"github.com/pion/interceptor/pkg/stats"
+	"github.com/pion/webrtc/v4"
+
+	"github.com/qrave1/RoomSpeak/internal/application/config"
+	"github.com/qrave1/RoomSpeak/internal/domain/models"
+)
+
+// API создает PeerConnection всех пиров. Кодеки зависят от профиля звука канала,
+// поэтому на каждый профиль свой webrtc.API с общими сетевыми настройками и интерсепторами.
+// Оценщик полосы и статистика потоков создаются интерсепторами внутри NewPeerConnection
+// без привязки к соединению, поэтому создание соединений сериализовано и они
+// забираются сразу после него
+type API struct {
+	settings webrtc.SettingEngine
+	registry *interceptor.Registry
+
+	mu        sync.Mutex
+	apis      map[models.AudioProfile]*webrtc.API
+	estimator cc.BandwidthEstimator
+	stats     stats.Getter
+}
+
+// NewPeerConnection создает соединение с кодеками профиля и возвращает оценщик
+// полосы до клиента и статистику RTP потоков соединения
+func (a *API) NewPeerConnection(
+	configuration webrtc.Configuration,
+	profile models.AudioProfile,
+) (*webrtc.PeerConnection, cc.BandwidthEstimator, stats.Getter, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	api, ok := a.apis[profile]
+	if !ok {
+		mediaEngine, err := newMediaEngine(profile)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("create media engine: %w", err)
+		}
+
+		api = webrtc.NewAPI(
+			webrtc.WithSettingEngine(a.settings),
+			webrtc.WithMediaEngine(mediaEngine),
+			webrtc.WithInterceptorRegistry(a.registry),
+		)
+		a.apis[profile] = api
+	}
+
+	a.estimator, a.stats = nil, nil
+
+	pc, err := api.NewPeerConnection(configuration)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return pc, a.estimator, a.stats, nil
+}
+
+// NewAPI собирает общий для всех пиров API. Возвращаемая функция
+// закрывает UDP/TCP mux при остановке
+func NewAPI(cfg config.WebRTCConfig) (*API, func() error, error) {
+	var (
+		settings = webrtc.SettingEngine{}
+		closers  []func() error
+	)
+
+	closeAll := func() error {
+		var errs []error
+		for _, closeFn := range closers {
+			errs = append(errs, closeFn())
+		}
+
+		return errors.Join(errs...)
+	}
+
+	if cfg.UDPPortMin != 0 || cfg.UDPPortMax != 0 {
+		if err := settings.SetEphemeralUDPPortRange(cfg.UDPPortMin, cfg.UDPPortMax); err != nil {
+			return nil, nil, fmt.Errorf("set udp port range: %w", err)
+		}
+	}
+
+	if cfg.UDPMuxPort != 0 {
+		udpConn, err := net.ListenUDP("udp", &net.UDPAddr{Port: cfg.UDPMuxPort})
+		if err != nil {
+			return nil, nil, fmt.Errorf("listen ice udp mux: %w", err)
+		}
+
+		udpMux := webrtc.NewICEUDPMux(nil, udpConn)
+		closers = append(closers, udpMux.Close)
+
+		settings.SetICEUDPMux(udpMux)
+	}
+
+	if cfg.TCPMuxPort != 0 {
+		tcpListener, err := net.ListenTCP("tcp", &net.TCPAddr{Port: cfg.TCPMuxPort})
+		if err != nil {
+			return nil, nil, errors.Join(fmt.Errorf("listen ice tcp mux: %w", err), closeAll())
+		}
+
+		tcpMux := webrtc.NewICETCPMux(nil, tcpListener, 8)
+		closers = append(closers, tcpMux.Close)
+
+		settings.SetICETCPMux(tcpMux)
+		settings.SetNetworkTypes([]webrtc.NetworkType{
+			webrtc.NetworkTypeUDP4,
+			webrtc.NetworkTypeUDP6,
+			webrtc.NetworkTypeTCP4,
+			webrtc.NetworkTypeTCP6,
+		})
+	}
+
+	if len(cfg.NAT1To1IPs) > 0 {
+		settings.SetNAT1To1IPs(cfg.NAT1To1IPs, webrtc.ICECandidateTypeHost)
+	}
+
+	if len(cfg.Interfaces) > 0 || len(cfg.ExcludeInterfaces) > 0 {
+		settings.SetInterfaceFilter(func(name string) bool {
+			return interfaceAllowed(name, cfg.Interfaces, cfg.ExcludeInterfaces)
+		})
+	}
+
+	settings.SetICETimeouts(cfg.ICEDisconnectedTimeout, cfg.ICEFailedTimeout, cfg.ICEKeepaliveInterval)
+
+	// Профиль по умолчанию проверяется сразу, чтобы ошибка кодеков не всплыла на первом входе
+	if _, err := newMediaEngine(models.DefaultAudioProfile()); err != nil {
+		return nil, nil, errors.Join(fmt.Errorf("create media engine: %w", err), closeAll())
+	}
+
+	api := &API{
+		settings: settings,
+		registry: &interceptor.Registry{},
+		apis:     make(map[models.AudioProfile]*webrtc.API),
+	}
+
+	if err := registerInterceptors(cfg, api.registry, func(estimator cc.BandwidthEstimator, getter stats.Getter) {
+		api.estimator, api.stats = estimator, getter
+	}); err != nil {
+		return nil, nil, errors.Join(fmt.Errorf("register interceptors: %w", err), closeAll())
+	}
+
+	return api, closeAll, nil
+}
+
+func interfaceAllowed(name string, include, exclude []string) bool {
+	for _, prefix := range exclude {
+		if strings.HasPrefix(name, prefix) {
+			return false
+		}
+	}
+
+	return len(include) == 0 || slices.Contains(include, name)
+}
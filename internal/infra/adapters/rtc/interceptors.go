@@ -0,0 +1,100 @@
+package rtc
+
+import (
+	"fmt"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/interceptor/pkg/cc"
+	"github.com/pion/interceptor/pkg/gcc"
+	"github.com/pion/interceptor/pkg/nack"
+	"github.com/pion/interceptor/pkg/report"
+	"github.com/pion/interceptor/pkg/stats"
+	"github.com/pion/interceptor/pkg/twcc"
+
+	"github.com/qrave1/RoomSpeak/internal/application/config"
+)
+
+// registerInterceptors подключает обработку RTCP для каждого PeerConnection:
+//   - NACK: запросы повторов у публикующих и ответы получателям из буфера отправленных пакетов;
+//   - RTCP sender/receiver reports;
+//   - TWCC: обратная связь публикующим и оценка полосы до получателей (GCC);
+//   - статистика RTP потоков для оценки качества соединения.
+//
+// onNewPeerConnection вызывается синхронно внутри NewPeerConnection
+func registerInterceptors(
+	cfg config.WebRTCConfig,
+	registry *interceptor.Registry,
+	onNewPeerConnection func(cc.BandwidthEstimator, stats.Getter),
+) error {
+	var estimator cc.BandwidthEstimator
+
+	congestionController, err := cc.NewInterceptor(func() (cc.BandwidthEstimator, error) {
+		return gcc.NewSendSideBWE(
+			gcc.SendSideBWEInitialBitrate(cfg.BWEInitialBitrate),
+			gcc.SendSideBWEMinBitrate(cfg.BWEMinBitrate),
+			gcc.SendSideBWEMaxBitrate(cfg.BWEMaxBitrate),
+			// Пакеты не задерживаются, под полосу подстраивается выбор слоя симулкаста
+			gcc.SendSideBWEPacer(gcc.NewNoOpPacer()),
+		)
+	})
+	if err != nil {
+		return fmt.Errorf("create congestion controller: %w", err)
+	}
+
+	// Интерсепторы собираются в порядке добавления: оценщик создается раньше статистики
+	congestionController.OnNewPeerConnection(func(_ string, bwe cc.BandwidthEstimator) {
+		estimator = bwe
+	})
+
+	statsInterceptor, err := stats.NewInterceptor()
+	if err != nil {
+		return fmt.Errorf("create stats interceptor: %w", err)
+	}
+
+	statsInterceptor.OnNewPeerConnection(func(_ string, getter stats.Getter) {
+		onNewPeerConnection(estimator, getter)
+	})
+
+	registry.Add(congestionController)
+	registry.Add(statsInterceptor)
+
+	twccHeaders, err := twcc.NewHeaderExtensionInterceptor()
+	if err != nil {
+		return fmt.Errorf("create twcc header interceptor: %w", err)
+	}
+
+	twccFeedback, err := twcc.NewSenderInterceptor()
+	if err != nil {
+		return fmt.Errorf("create twcc feedback interceptor: %w", err)
+	}
+
+	nackGenerator, err := nack.NewGeneratorInterceptor()
+	if err != nil {
+		return fmt.Errorf("create nack generator: %w", err)
+	}
+
+	// Буфер повторов на каждый отправляемый поток
+	nackResponder, err := nack.NewResponderInterceptor(nack.ResponderSize(cfg.NACKBufferSize))
+	if err != nil {
+		return fmt.Errorf("create nack responder: %w", err)
+	}
+
+	receiverReports, err := report.NewReceiverInterceptor()
+	if err != nil {
+		return fmt.Errorf("create receiver reports: %w", err)
+	}
+
+	senderReports, err := report.NewSenderInterceptor()
+	if err != nil {
+		return fmt.Errorf("create sender reports: %w", err)
+	}
+
+	registry.Add(twccHeaders)
+	registry.Add(twccFeedback)
+	registry.Add(nackResponder)
+	registry.Add(nackGenerator)
+	registry.Add(receiverReports)
+	registry.Add(senderReports)
+
+	return nil
+}
@@ -0,0 +1,93 @@
+package rtc
+
+import (
+	"fmt"
+
+	"github.com/pion/sdp/v3"
+	"github.com/pion/webrtc/v4"
+
+	"github.com/qrave1/RoomSpeak/internal/domain"
+	"github.com/qrave1/RoomSpeak/internal/domain/models"
+)
+
+// videoCodecs - пересылаемые без перекодирования видео кодеки с RTX для повторов
+var videoCodecs = []struct {
+	capability  webrtc.RTPCodecCapability
+	payloadType webrtc.PayloadType
+	rtxType     webrtc.PayloadType
+}{
+	{webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8, ClockRate: 90000}, 96, 97},
+	{webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP9, ClockRate: 90000, SDPFmtpLine: "profile-id=0"}, 98, 99},
+	{webrtc.RTPCodecCapability{
+		MimeType:    webrtc.MimeTypeH264,
+		ClockRate:   90000,
+		SDPFmtpLine: "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f",
+	}, 102, 103},
+	{webrtc.RTPCodecCapability{
+		MimeType:    webrtc.MimeTypeH264,
+		ClockRate:   90000,
+		SDPFmtpLine: "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42001f",
+	}, 104, 105},
+}
+
+// newMediaEngine регистрирует кодеки под профиль звука канала: Opus с fmtp профиля,
+// legacy кодеки по желанию канала и видео кодеки, а также RTCP обратную связь
+// и расширения заголовков, нужные интерсепторам и симулкасту
+func newMediaEngine(profile models.AudioProfile) (*webrtc.MediaEngine, error) {
+	mediaEngine := &webrtc.MediaEngine{}
+
+	audioCodecs := []webrtc.RTPCodecParameters{
+		{RTPCodecCapability: domain.OpusCodec(profile), PayloadType: domain.OpusPayloadType},
+	}
+
+	if profile.LegacyCodecs {
+		audioCodecs = append(audioCodecs, domain.LegacyAudioCodecs()...)
+	}
+
+	for _, codec := range audioCodecs {
+		if err := mediaEngine.RegisterCodec(codec, webrtc.RTPCodecTypeAudio); err != nil {
+			return nil, fmt.Errorf("register %s: %w", codec.MimeType, err)
+		}
+	}
+
+	for _, codec := range videoCodecs {
+		if err := mediaEngine.RegisterCodec(webrtc.RTPCodecParameters{
+			RTPCodecCapability: codec.capability,
+			PayloadType:        codec.payloadType,
+		}, webrtc.RTPCodecTypeVideo); err != nil {
+			return nil, fmt.Errorf("register %s: %w", codec.capability.MimeType, err)
+		}
+
+		if err := mediaEngine.RegisterCodec(webrtc.RTPCodecParameters{
+			RTPCodecCapability: webrtc.RTPCodecCapability{
+				MimeType:    webrtc.MimeTypeRTX,
+				ClockRate:   90000,
+				SDPFmtpLine: fmt.Sprintf("apt=%d", codec.payloadType),
+			},
+			PayloadType: codec.rtxType,
+		}, webrtc.RTPCodecTypeVideo); err != nil {
+			return nil, fmt.Errorf("register rtx for %s: %w", codec.capability.MimeType, err)
+		}
+	}
+
+	for _, kind := range []webrtc.RTPCodecType{webrtc.RTPCodecTypeAudio, webrtc.RTPCodecTypeVideo} {
+		if err := mediaEngine.RegisterHeaderExtension(
+			webrtc.RTPHeaderExtensionCapability{URI: sdp.TransportCCURI}, kind,
+		); err != nil {
+			return nil, fmt.Errorf("register transport-cc extension: %w", err)
+		}
+
+		mediaEngine.RegisterFeedback(webrtc.RTCPFeedback{Type: webrtc.TypeRTCPFBTransportCC}, kind)
+		mediaEngine.RegisterFeedback(webrtc.RTCPFeedback{Type: webrtc.TypeRTCPFBNACK}, kind)
+	}
+
+	mediaEngine.RegisterFeedback(webrtc.RTCPFeedback{Type: webrtc.TypeRTCPFBNACK, Parameter: "pli"}, webrtc.RTPCodecTypeVideo)
+	mediaEngine.RegisterFeedback(webrtc.RTCPFeedback{Type: webrtc.TypeRTCPFBGoogREMB}, webrtc.RTPCodecTypeVideo)
+	mediaEngine.RegisterFeedback(webrtc.RTCPFeedback{Type: webrtc.TypeRTCPFBCCM, Parameter: "fir"}, webrtc.RTPCodecTypeVideo)
+
+	if err := webrtc.ConfigureSimulcastExtensionHeaders(mediaEngine); err != nil {
+		return nil, fmt.Errorf("register simulcast extensions: %w", err)
+	}
+
+	return mediaEngine, nil
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type localStorage struct {
+	dir       string
+	publicURL string
+}
+
+// NewLocalStorage создает хранилище в локальной директории dir,
+// файлы раздаются HTTP сервером по префиксу publicURL
+func NewLocalStorage(dir, publicURL string) (AvatarStorage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create storage dir: %w", err)
+	}
+
+	return &localStorage{
+		dir:       dir,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+	}, nil
+}
+
+func (s *localStorage) Put(ctx context.Context, key, contentType string, r io.Reader, size int64) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create object dir: %w", err)
+	}
+
+	// Пишем во временный файл и переименовываем, чтобы не отдавать недописанный объект
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write object: %w", err)
+	}
+
+	if err = tmp.Close(); err != nil {
+		return fmt.Errorf("close object: %w", err)
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *localStorage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Open(path)
+}
+
+func (s *localStorage) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err = os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *localStorage) URL(key string) string {
+	if key == "" {
+		return ""
+	}
+
+	return s.publicURL + "/" + key
+}
+
+// path защищает от выхода за пределы директории хранилища
+func (s *localStorage) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if clean == "/" {
+		return "", errors.New("empty object key")
+	}
+
+	return filepath.Join(s.dir, clean), nil
+}
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+
+	"github.com/qrave1/RoomSpeak/internal/application/config"
+)
+
+type s3Storage struct {
+	client    *minio.Client
+	bucket    string
+	publicURL string
+}
+
+// NewS3Storage создает хранилище в S3-совместимом бакете (AWS S3, MinIO, Yandex Object Storage и т.д.)
+func NewS3Storage(cfg config.StorageConfig) (AvatarStorage, error) {
+	client, err := minio.New(cfg.S3Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.S3AccessKey, cfg.S3SecretKey, ""),
+		Secure: cfg.S3UseSSL,
+		Region: cfg.S3Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create s3 client: %w", err)
+	}
+
+	publicURL := cfg.PublicURL
+	if publicURL == "" {
+		scheme := "http"
+		if cfg.S3UseSSL {
+			scheme = "https"
+		}
+
+		publicURL = fmt.Sprintf("%s://%s/%s", scheme, cfg.S3Endpoint, cfg.S3Bucket)
+	}
+
+	return &s3Storage{
+		client:    client,
+		bucket:    cfg.S3Bucket,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+	}, nil
+}
+
+func (s *s3Storage) Put(ctx context.Context, key, contentType string, r io.Reader, size int64) error {
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{ContentType: contentType})
+	if err != nil {
+		return fmt.Errorf("put object: %w", err)
+	}
+
+	return nil
+}
+
+func (s *s3Storage) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("get object: %w", err)
+	}
+
+	return obj, nil
+}
+
+func (s *s3Storage) Delete(ctx context.Context, key string) error {
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (s *s3Storage) URL(key string) string {
+	if key == "" {
+		return ""
+	}
+
+	return s.publicURL + "/" + key
+}
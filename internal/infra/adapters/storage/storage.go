@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/qrave1/RoomSpeak/internal/application/config"
+)
+
+// LocalPublicPath - путь, по которому HTTP сервер раздает файлы локального хранилища
+const LocalPublicPath = "/uploads"
+
+// AvatarStorage хранит загруженные пользователями изображения
+type AvatarStorage interface {
+	// Put сохраняет объект под ключом key
+	Put(ctx context.Context, key, contentType string, r io.Reader, size int64) error
+
+	// Open открывает объект на чтение
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// Delete удаляет объект, отсутствие объекта не считается ошибкой
+	Delete(ctx context.Context, key string) error
+
+	// URL возвращает публичную ссылку на объект, для пустого ключа - пустую строку
+	URL(key string) string
+}
+
+// New создает хранилище по STORAGE_BACKEND
+func New(cfg config.StorageConfig) (AvatarStorage, error) {
+	switch cfg.Backend {
+	case config.StorageBackendLocal:
+		publicURL := cfg.PublicURL
+		if publicURL == "" {
+			publicURL = LocalPublicPath
+		}
+
+		return NewLocalStorage(cfg.LocalDir, publicURL)
+	case config.StorageBackendS3:
+		return NewS3Storage(cfg)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
+	}
+}
@@ -0,0 +1,24 @@
+package turn
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"time"
+)
+
+// Credentials выдает временные креды TURN REST API (use-auth-secret в coturn):
+// username - "expiry:tag", пароль - base64(HMAC-SHA1(secret, username))
+func Credentials(secret, tag string, expiresAt time.Time) (username, credential string) {
+	username = fmt.Sprintf("%d:%s", expiresAt.Unix(), tag)
+
+	return username, sign(secret, username)
+}
+
+func sign(secret, username string) string {
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(username))
+
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,82 @@
+package turn
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	pionturn "github.com/pion/turn/v4"
+
+	"github.com/qrave1/RoomSpeak/internal/application/config"
+)
+
+// Server - встроенный TURN/STUN сервер на pion/turn
+type Server struct {
+	server *pionturn.Server
+}
+
+// NewServer запускает TURN на UDP и TCP. Принимаются те же временные креды,
+// что выдает IceHandler
+func NewServer(cfg config.TURNServerConfig) (*Server, error) {
+	publicIP := net.ParseIP(cfg.PublicIP)
+
+	udpConn, err := net.ListenPacket("udp", cfg.ListenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("listen turn udp: %w", err)
+	}
+
+	tcpListener, err := net.Listen("tcp", cfg.ListenAddr)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("listen turn tcp: %w", err), udpConn.Close())
+	}
+
+	relayGenerator := func() pionturn.RelayAddressGenerator {
+		return &pionturn.RelayAddressGeneratorPortRange{
+			RelayAddress: publicIP,
+			Address:      "0.0.0.0",
+			MinPort:      cfg.RelayPortMin,
+			MaxPort:      cfg.RelayPortMax,
+		}
+	}
+
+	server, err := pionturn.NewServer(pionturn.ServerConfig{
+		Realm:       cfg.Realm,
+		AuthHandler: authHandler(cfg.Secret),
+		PacketConnConfigs: []pionturn.PacketConnConfig{
+			{PacketConn: udpConn, RelayAddressGenerator: relayGenerator()},
+		},
+		ListenerConfigs: []pionturn.ListenerConfig{
+			{Listener: tcpListener, RelayAddressGenerator: relayGenerator()},
+		},
+	})
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("create turn server: %w", err), udpConn.Close(), tcpListener.Close())
+	}
+
+	slog.Info("embedded turn server started", slog.String("addr", cfg.ListenAddr), slog.String("public_ip", cfg.PublicIP))
+
+	return &Server{server: server}, nil
+}
+
+func (s *Server) Close() error {
+	return s.server.Close()
+}
+
+// authHandler проверяет username вида "expiry:tag" и отдает ключ для пароля из Credentials
+func authHandler(secret string) pionturn.AuthHandler {
+	return func(username, realm string, srcAddr net.Addr) ([]byte, bool) {
+		expiry, _, _ := strings.Cut(username, ":")
+
+		expiresAt, err := strconv.ParseInt(expiry, 10, 64)
+		if err != nil || time.Now().Unix() > expiresAt {
+			slog.Warn("turn auth rejected", slog.String("username", username), slog.String("addr", srcAddr.String()))
+			return nil, false
+		}
+
+		return pionturn.GenerateAuthKey(username, realm, sign(secret, username)), true
+	}
+}
@@ -0,0 +1,16 @@
+package appctx
+
+import "context"
+
+const scopesKey ctxKey = "scopes"
+
+// WithScopes добавляет в контекст скоупы, с которыми авторизован запрос
+func WithScopes(ctx context.Context, scopes []string) context.Context {
+	return context.WithValue(ctx, scopesKey, scopes)
+}
+
+// Scopes извлекает скоупы запроса из контекста
+func Scopes(ctx context.Context) ([]string, bool) {
+	scopes, ok := ctx.Value(scopesKey).([]string)
+	return scopes, ok
+}
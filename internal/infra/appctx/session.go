@@ -0,0 +1,16 @@
+package appctx
+
+import "context"
+
+const sessionKey ctxKey = "session"
+
+// WithSession отмечает запрос, авторизованный JWT сессии, а не API токеном
+func WithSession(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sessionKey, true)
+}
+
+// IsSession - запрос авторизован JWT сессии
+func IsSession(ctx context.Context) bool {
+	session, _ := ctx.Value(sessionKey).(bool)
+	return session
+}
@@ -16,3 +16,8 @@ type GetMeResponse struct {
 	ID       uuid.UUID `json:"id"`
 	Username string    `json:"username"`
 }
+
+type DeleteAccountRequest struct {
+	// Password - подтверждение удаления, для ботов не требуется
+	Password string `json:"password"`
+}
@@ -9,6 +9,40 @@ import (
 type CreateChannelRequest struct {
 	Name     string `json:"name"`
 	IsPublic bool   `json:"is_public"`
+	// Type - voice, stage или ptt, пусто - voice
+	Type string `json:"type"`
+}
+
+// UpdateAudioProfileRequest - полный профиль звука канала
+type UpdateAudioProfileRequest struct {
+	OpusMaxBitrate int  `json:"opus_max_bitrate"`
+	OpusStereo     bool `json:"opus_stereo"`
+	OpusDTX        bool `json:"opus_dtx"`
+	OpusFEC        bool `json:"opus_fec"`
+	OpusPtime      int  `json:"opus_ptime"`
+	LegacyCodecs   bool `json:"legacy_codecs"`
+}
+
+// UpdateFloorControlRequest - настройки ptt канала
+type UpdateFloorControlRequest struct {
+	MaxTalkSeconds int `json:"floor_max_talk_seconds"`
+	MaxHolders     int `json:"floor_max_holders"`
+}
+
+// SetPrioritySpeakerRequest - может ли участник перебивать говорящего в ptt канале
+type SetPrioritySpeakerRequest struct {
+	Priority bool `json:"priority"`
+}
+
+// UpdateAdmissionRequest - предел участников (0 - без ограничения) и зал ожидания
+type UpdateAdmissionRequest struct {
+	MaxUsers    int  `json:"max_users"`
+	WaitingRoom bool `json:"waiting_room"`
+}
+
+// UpdateChannelPasswordRequest - новый пароль канала, пустой - снять пароль
+type UpdateChannelPasswordRequest struct {
+	Password string `json:"password"`
 }
 
 type ActiveUserInfo struct {
@@ -21,9 +55,14 @@ type ChannelResponse struct {
 	CreatorID   uuid.UUID       `json:"creator_id"`
 	Name        string          `json:"name"`
 	IsPublic    bool            `json:"is_public"`
+	Type        models.ChannelType `json:"type"`
 	CreatedAt   time.Time       `json:"created_at"`
 	UpdatedAt   time.Time       `json:"updated_at"`
 	ActiveUsers []ActiveUserInfo `json:"active_users"`
+	Audio       models.AudioProfile `json:"audio"`
+	Floor       models.FloorControl `json:"floor"`
+	Admission   models.Admission    `json:"admission"`
+	HasPassword bool                `json:"has_password"`
 }
 
 func NewChannelResponseFromModel(ch *models.Channel, activeUsers []ActiveUserInfo) ChannelResponse {
@@ -35,9 +74,14 @@ func NewChannelResponseFromModel(ch *models.Channel, activeUsers []ActiveUserInf
 		CreatorID:   ch.CreatorID,
 		Name:        ch.Name,
 		IsPublic:    ch.IsPublic,
+		Type:        ch.Type,
 		CreatedAt:   ch.CreatedAt,
 		UpdatedAt:   ch.UpdatedAt,
 		ActiveUsers: activeUsers,
+		Audio:       ch.AudioProfile,
+		Floor:       ch.FloorControl,
+		Admission:   ch.Admission,
+		HasPassword: ch.HasPassword(),
 	}
 }
 
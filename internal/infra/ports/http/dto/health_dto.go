@@ -0,0 +1,32 @@
+package dto
+
+import "github.com/google/uuid"
+
+type ReadinessResponse struct {
+	Status string `json:"status"`
+	// Checks - результат каждой проверки: ok или текст ошибки
+	Checks map[string]string `json:"checks"`
+}
+
+type DebugPeer struct {
+	UserID          uuid.UUID `json:"user_id"`
+	ChannelID       uuid.UUID `json:"channel_id"`
+	ConnectionState string    `json:"connection_state"`
+	ICEState        string    `json:"ice_state"`
+	ICEGathering    string    `json:"ice_gathering_state"`
+	DTLSState       string    `json:"dtls_state"`
+	SignalingState  string    `json:"signaling_state"`
+}
+
+type DebugChannel struct {
+	ID          uuid.UUID   `json:"id"`
+	ActiveUsers []uuid.UUID `json:"active_users"`
+	Peers       int         `json:"peers"`
+}
+
+type DebugStateResponse struct {
+	NodeID      string         `json:"node_id"`
+	Channels    []DebugChannel `json:"channels"`
+	Peers       []DebugPeer    `json:"peers"`
+	Connections []uuid.UUID    `json:"websocket_connections"`
+}
@@ -0,0 +1,18 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+type IceServersResponse struct {
+	// URLs, Username, Credential - TURN сервера одним объектом, как раньше
+	URLs       []string `json:"urls"`
+	Username   string   `json:"username"`
+	Credential string   `json:"credential"`
+
+	// ICEServers - все STUN и TURN сервера в порядке приоритета
+	ICEServers []webrtc.ICEServer `json:"ice_servers"`
+	ExpiresAt  time.Time          `json:"expires_at"`
+}
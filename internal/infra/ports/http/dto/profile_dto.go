@@ -0,0 +1,7 @@
+package dto
+
+type UpdateProfileRequest struct {
+	DisplayName *string `json:"display_name"`
+	Bio         *string `json:"bio"`
+	AccentColor *string `json:"accent_color"`
+}
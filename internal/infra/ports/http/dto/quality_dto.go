@@ -0,0 +1,9 @@
+package dto
+
+import "github.com/qrave1/RoomSpeak/internal/domain/runtime"
+
+// ChannelStatsResponse - качество соединений участников канала, подключенных к этой ноде
+type ChannelStatsResponse struct {
+	ChannelID    string                      `json:"channel_id"`
+	Participants []runtime.ConnectionQuality `json:"participants"`
+}
@@ -0,0 +1,47 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/qrave1/RoomSpeak/internal/domain/models"
+)
+
+type CreateBotRequest struct {
+	Username string `json:"username"`
+}
+
+type CreateTokenRequest struct {
+	// BotID - если указан, токен выпускается для бота текущего пользователя
+	BotID *uuid.UUID `json:"bot_id"`
+	Name  string     `json:"name"`
+	// Scopes - read, write, ws. Пустой список означает все скоупы
+	Scopes []string `json:"scopes"`
+	// TTLHours - время жизни токена, 0 - бессрочный
+	TTLHours int `json:"ttl_hours"`
+}
+
+type CreateTokenResponse struct {
+	// Token показывается только один раз, в БД хранится лишь хеш
+	Token    string           `json:"token"`
+	APIToken *models.APIToken `json:"api_token"`
+}
+
+type ListTokensResponse struct {
+	Tokens []*models.APIToken `json:"tokens"`
+}
+
+type BotResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func NewBotResponseFromModel(bot *models.User) BotResponse {
+	return BotResponse{
+		ID:        bot.ID,
+		Username:  bot.Username,
+		CreatedAt: bot.CreatedAt,
+	}
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/qrave1/RoomSpeak/internal/application/constant"
+	"github.com/qrave1/RoomSpeak/internal/infra/appctx"
+	"github.com/qrave1/RoomSpeak/internal/infra/ports/http/dto"
+	"github.com/qrave1/RoomSpeak/internal/infra/ports/http/middleware"
+	"github.com/qrave1/RoomSpeak/internal/usecase"
+)
+
+type AccountHandler struct {
+	accountUsecase usecase.AccountUsecase
+
+	cookies *middleware.SessionCookies
+}
+
+func NewAccountHandler(accountUsecase usecase.AccountUsecase, cookies *middleware.SessionCookies) *AccountHandler {
+	return &AccountHandler{accountUsecase: accountUsecase, cookies: cookies}
+}
+
+func (h *AccountHandler) DeleteMe(c echo.Context) error {
+	var req dto.DeleteAccountRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+
+	userID, ok := appctx.UserID(c.Request().Context())
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid user"})
+	}
+
+	if err := h.accountUsecase.DeleteAccount(c.Request().Context(), userID, req.Password); err != nil {
+		if errors.Is(err, usecase.ErrInvalidPassword) {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": "invalid password"})
+		}
+
+		slog.Error("delete account", slog.Any(constant.Error, err), slog.Any(constant.UserID, userID))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "could not delete account"})
+	}
+
+	c.SetCookie(h.cookies.Expired())
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// ExportMe отдает ZIP архив с персональными данными пользователя
+func (h *AccountHandler) ExportMe(c echo.Context) error {
+	userID, ok := appctx.UserID(c.Request().Context())
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid user"})
+	}
+
+	// Архив собирается целиком до ответа, чтобы ошибка не превратилась в оборванный ZIP со статусом 200
+	var archive bytes.Buffer
+	if err := h.accountUsecase.Export(c.Request().Context(), userID, &archive); err != nil {
+		slog.Error("export account", slog.Any(constant.Error, err), slog.Any(constant.UserID, userID))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "could not export account"})
+	}
+
+	filename := fmt.Sprintf("roomspeak-export-%s.zip", time.Now().Format("20060102"))
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", filename))
+
+	return c.Blob(http.StatusOK, "application/zip", archive.Bytes())
+}
@@ -3,22 +3,25 @@ package handlers
 import (
 	"log/slog"
 	"net/http"
-	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/qrave1/RoomSpeak/internal/application/constant"
 	"github.com/qrave1/RoomSpeak/internal/infra/appctx"
 	"github.com/qrave1/RoomSpeak/internal/infra/ports/http/dto"
+	"github.com/qrave1/RoomSpeak/internal/infra/ports/http/middleware"
 	"github.com/qrave1/RoomSpeak/internal/usecase"
 )
 
 type AuthHandler struct {
 	userUsecase usecase.UserUsecase
+
+	cookies *middleware.SessionCookies
 }
 
-func NewAuthHandler(userUsecase usecase.UserUsecase) *AuthHandler {
+func NewAuthHandler(userUsecase usecase.UserUsecase, cookies *middleware.SessionCookies) *AuthHandler {
 	return &AuthHandler{
 		userUsecase: userUsecase,
+		cookies:     cookies,
 	}
 }
 
@@ -55,16 +58,45 @@ func (h *AuthHandler) Login(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "could not create token"})
 	}
 
-	c.SetCookie(&http.Cookie{
-		Name:     "jwt",
-		Value:    token,
-		Expires:  time.Now().Add(72 * time.Hour),
-		Domain:   ".xxsm.ru",
-		Path:     "/",
-		Secure:   true,
-		HttpOnly: true,
-		SameSite: http.SameSiteNoneMode,
-	})
+	c.SetCookie(h.cookies.New(token))
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *AuthHandler) Logout(c echo.Context) error {
+	c.SetCookie(h.cookies.Expired())
+
+	return c.NoContent(http.StatusOK)
+}
+
+// Refresh перевыпускает JWT для уже авторизованного пользователя.
+// Обменять на сессию можно только сессию: API токен с урезанными скоупами получил бы полный доступ
+func (h *AuthHandler) Refresh(c echo.Context) error {
+	userID, ok := appctx.UserID(c.Request().Context())
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid user ID in context"})
+	}
+
+	if !appctx.IsSession(c.Request().Context()) {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "only session can be refreshed"})
+	}
+
+	user, err := h.userUsecase.GetUserByID(c.Request().Context(), userID)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "user not found"})
+	}
+
+	if user.IsBot {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "bots cannot have sessions"})
+	}
+
+	token, err := h.userUsecase.GenerateJWT(user)
+	if err != nil {
+		slog.Error("generate JWT failed", slog.Any(constant.Error, err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "could not create token"})
+	}
+
+	c.SetCookie(h.cookies.New(token))
 
 	return c.NoContent(http.StatusOK)
 }
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
 
@@ -9,6 +10,7 @@ import (
 
 	"github.com/qrave1/RoomSpeak/internal/application/constant"
 	"github.com/qrave1/RoomSpeak/internal/domain/input"
+	"github.com/qrave1/RoomSpeak/internal/domain/models"
 	postrepo "github.com/qrave1/RoomSpeak/internal/infra/adapters/postgres/repository"
 	"github.com/qrave1/RoomSpeak/internal/infra/appctx"
 	"github.com/qrave1/RoomSpeak/internal/infra/ports/http/dto"
@@ -54,7 +56,7 @@ func (h *ChannelHandler) ListChannelsHandler(c echo.Context) error {
 		// Преобразуем ActiveUser в ActiveUserInfo
 		activeUserInfos := make([]dto.ActiveUserInfo, 0, len(activeUsers))
 		for _, activeUser := range activeUsers {
-			user, err := h.userRepo.GetUserByID(activeUser.ID)
+			user, err := h.userRepo.GetUserByID(c.Request().Context(), activeUser.ID)
 			if err != nil {
 				continue // Пропускаем пользователей, которых не можем найти
 			}
@@ -80,6 +82,10 @@ func (h *ChannelHandler) CreateChannelHandler(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "name is required"})
 	}
 
+	if req.Type != "" && !models.ChannelType(req.Type).IsValid() {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid channel type"})
+	}
+
 	// Получаем userID из JWT токена
 	userID, ok := appctx.UserID(c.Request().Context())
 	if !ok {
@@ -89,6 +95,7 @@ func (h *ChannelHandler) CreateChannelHandler(c echo.Context) error {
 	createChannelInput := &input.CreateChannelInput{
 		Name:      req.Name,
 		IsPublic:  req.IsPublic,
+		Type:      req.Type,
 		CreatorID: userID,
 	}
 
@@ -112,36 +119,181 @@ func (h *ChannelHandler) CreateChannelHandler(c echo.Context) error {
 }
 
 func (h *ChannelHandler) DeleteChannelHandler(c echo.Context) error {
-	channelIDStr := c.Param("id")
-	channelID, err := uuid.Parse(channelIDStr)
+	channel, err := h.creatorChannel(c, nil, "delete the channel")
+	if channel == nil {
+		return err
+	}
+
+	// Удаляем канал из базы данных
+	if err = h.channelUsecase.DeleteChannel(c.Request().Context(), channel.ID); err != nil {
+		slog.Error("delete channel", slog.Any(constant.Error, err))
+
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to delete channel"})
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+// UpdateAudioProfileHandler меняет профиль звука канала, доступно только создателю
+func (h *ChannelHandler) UpdateAudioProfileHandler(c echo.Context) error {
+	var req dto.UpdateAudioProfileRequest
+
+	channel, err := h.creatorChannel(c, &req, "change audio settings")
+	if channel == nil {
+		return err
+	}
+
+	channel, err = h.channelUsecase.UpdateAudioProfile(c.Request().Context(), channel.ID, models.AudioProfile{
+		OpusMaxBitrate: req.OpusMaxBitrate,
+		OpusStereo:     req.OpusStereo,
+		OpusDTX:        req.OpusDTX,
+		OpusFEC:        req.OpusFEC,
+		OpusPtime:      req.OpusPtime,
+		LegacyCodecs:   req.LegacyCodecs,
+	})
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid channel id"})
+		if errors.Is(err, usecase.ErrInvalidAudioProfile) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+
+		slog.Error("update audio profile", slog.Any(constant.Error, err))
+
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to update audio settings"})
+	}
+
+	return c.JSON(http.StatusOK, channel)
+}
+
+// UpdateFloorControlHandler меняет настройки ptt канала, доступно только создателю
+func (h *ChannelHandler) UpdateFloorControlHandler(c echo.Context) error {
+	var req dto.UpdateFloorControlRequest
+
+	channel, err := h.creatorChannel(c, &req, "change floor control")
+	if channel == nil {
+		return err
+	}
+
+	channel, err = h.channelUsecase.UpdateFloorControl(c.Request().Context(), channel.ID, models.FloorControl{
+		MaxTalkSeconds: req.MaxTalkSeconds,
+		MaxHolders:     req.MaxHolders,
+	})
+	if err != nil {
+		if errors.Is(err, usecase.ErrInvalidFloorControl) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+
+		slog.Error("update floor control", slog.Any(constant.Error, err))
+
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to update floor control"})
+	}
+
+	return c.JSON(http.StatusOK, channel)
+}
+
+// UpdateAdmissionHandler меняет предел участников и зал ожидания канала, доступно только создателю
+func (h *ChannelHandler) UpdateAdmissionHandler(c echo.Context) error {
+	var req dto.UpdateAdmissionRequest
+
+	channel, err := h.creatorChannel(c, &req, "change admission settings")
+	if channel == nil {
+		return err
+	}
+
+	channel, err = h.channelUsecase.UpdateAdmission(c.Request().Context(), channel.ID, models.Admission{
+		MaxUsers:    req.MaxUsers,
+		WaitingRoom: req.WaitingRoom,
+	})
+	if err != nil {
+		if errors.Is(err, usecase.ErrInvalidAdmission) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+
+		slog.Error("update admission", slog.Any(constant.Error, err))
+
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to update admission settings"})
+	}
+
+	return c.JSON(http.StatusOK, channel)
+}
+
+// UpdatePasswordHandler ставит, меняет или снимает пароль канала, доступно только создателю
+func (h *ChannelHandler) UpdatePasswordHandler(c echo.Context) error {
+	var req dto.UpdateChannelPasswordRequest
+
+	channel, err := h.creatorChannel(c, &req, "change password")
+	if channel == nil {
+		return err
+	}
+
+	if err = h.channelUsecase.UpdatePassword(c.Request().Context(), channel.ID, req.Password); err != nil {
+		if errors.Is(err, usecase.ErrInvalidChannelPassword) || errors.Is(err, usecase.ErrChannelNotPublic) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+
+		slog.Error("update channel password", slog.Any(constant.Error, err))
+
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to update password"})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// SetPrioritySpeakerHandler назначает или снимает приоритетного говорящего ptt канала, доступно только создателю
+func (h *ChannelHandler) SetPrioritySpeakerHandler(c echo.Context) error {
+	memberID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+	}
+
+	var req dto.SetPrioritySpeakerRequest
+
+	channel, err := h.creatorChannel(c, &req, "change priority speakers")
+	if channel == nil {
+		return err
+	}
+
+	if err = h.channelUsecase.SetPrioritySpeaker(c.Request().Context(), memberID, channel.ID, req.Priority); err != nil {
+		if errors.Is(err, usecase.ErrNotChannelMember) {
+			return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+		}
+
+		slog.Error("set priority speaker", slog.Any(constant.Error, err))
+
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to update priority speaker"})
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// creatorChannel разбирает id канала и тело запроса в req и проверяет, что запрос делает создатель канала.
+// nil канал - ответ с ошибкой уже записан, вернуть нужно err
+func (h *ChannelHandler) creatorChannel(c echo.Context, req any, action string) (*models.Channel, error) {
+	channelID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return nil, c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid channel id"})
+	}
+
+	if req != nil {
+		if err = c.Bind(req); err != nil {
+			return nil, c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+		}
 	}
 
-	// Получаем userID из JWT токена
 	userID, ok := appctx.UserID(c.Request().Context())
 	if !ok {
-		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid user"})
+		return nil, c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid user"})
 	}
 
-	// Проверяем, что пользователь является создателем канала
 	channel, err := h.channelUsecase.GetChannel(c.Request().Context(), channelID)
 	if err != nil {
 		slog.Error("get channel", slog.Any(constant.Error, err))
 
-		return c.JSON(http.StatusNotFound, map[string]string{"error": "channel not found"})
+		return nil, c.JSON(http.StatusNotFound, map[string]string{"error": "channel not found"})
 	}
 
 	if channel.CreatorID != userID {
-		return c.JSON(http.StatusForbidden, map[string]string{"error": "only channel creator can delete the channel"})
-	}
-
-	// Удаляем канал из базы данных
-	if err := h.channelUsecase.DeleteChannel(c.Request().Context(), channelID); err != nil {
-		slog.Error("delete channel", slog.Any(constant.Error, err))
-
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to delete channel"})
+		return nil, c.JSON(http.StatusForbidden, map[string]string{"error": "only channel creator can " + action})
 	}
 
-	return c.NoContent(http.StatusOK)
+	return channel, nil
 }
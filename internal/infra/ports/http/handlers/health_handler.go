@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/labstack/echo/v4"
+
+	"github.com/qrave1/RoomSpeak/internal/application/config"
+	"github.com/qrave1/RoomSpeak/internal/infra/adapters/memory"
+	"github.com/qrave1/RoomSpeak/internal/infra/adapters/postgres"
+	"github.com/qrave1/RoomSpeak/internal/infra/ports/http/dto"
+	"github.com/qrave1/RoomSpeak/internal/usecase"
+)
+
+const readinessTimeout = 3 * time.Second
+
+type HealthHandler struct {
+	cfg *config.Config
+	db  *sqlx.DB
+
+	pcRepo         memory.PeerConnectionRepository
+	localWSRepo    memory.WebsocketConnectionRepository
+	activeUserRepo memory.ActiveUserRepository
+
+	signalingUsecase usecase.SignalingUsecase
+}
+
+func NewHealthHandler(
+	cfg *config.Config,
+	db *sqlx.DB,
+	pcRepo memory.PeerConnectionRepository,
+	localWSRepo memory.WebsocketConnectionRepository,
+	activeUserRepo memory.ActiveUserRepository,
+	signalingUsecase usecase.SignalingUsecase,
+) *HealthHandler {
+	return &HealthHandler{
+		cfg:            cfg,
+		db:             db,
+		pcRepo:         pcRepo,
+		localWSRepo:    localWSRepo,
+		activeUserRepo: activeUserRepo,
+
+		signalingUsecase: signalingUsecase,
+	}
+}
+
+// Liveness - процесс жив и обслуживает HTTP
+func (h *HealthHandler) Liveness(c echo.Context) error {
+	return c.JSON(http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readiness - нода готова принимать пользователей
+func (h *HealthHandler) Readiness(c echo.Context) error {
+	ctx, cancel := context.WithTimeout(c.Request().Context(), readinessTimeout)
+	defer cancel()
+
+	checks := map[string]func(context.Context) error{
+		"postgres":   h.db.PingContext,
+		"migrations": h.checkMigrations,
+		"turn":       func(context.Context) error { return h.cfg.CheckTURN() },
+		"draining":   h.checkDraining,
+	}
+
+	resp := dto.ReadinessResponse{Status: "ok", Checks: make(map[string]string, len(checks))}
+	status := http.StatusOK
+
+	for name, check := range checks {
+		if err := check(ctx); err != nil {
+			resp.Checks[name] = err.Error()
+			resp.Status = "unavailable"
+			status = http.StatusServiceUnavailable
+			continue
+		}
+
+		resp.Checks[name] = "ok"
+	}
+
+	return c.JSON(status, resp)
+}
+
+// checkDraining снимает ноду с балансировки, как только началась остановка
+func (h *HealthHandler) checkDraining(context.Context) error {
+	if h.signalingUsecase.Draining() {
+		return errors.New("node is shutting down")
+	}
+
+	return nil
+}
+
+func (h *HealthHandler) checkMigrations(ctx context.Context) error {
+	current, latest, err := postgres.MigrationVersions(ctx, h.db.DB)
+	if err != nil {
+		return err
+	}
+
+	if current < latest {
+		return fmt.Errorf("database at version %d, latest is %d", current, latest)
+	}
+
+	return nil
+}
+
+// DebugState показывает состояние каналов, пиров и WebSocket соединений этой ноды
+func (h *HealthHandler) DebugState(c echo.Context) error {
+	resp := dto.DebugStateResponse{
+		NodeID:      h.cfg.Cluster.NodeID,
+		Channels:    make([]dto.DebugChannel, 0),
+		Peers:       make([]dto.DebugPeer, 0),
+		Connections: h.localWSRepo.GetAllConnected(),
+	}
+
+	peersByChannel := make(map[uuid.UUID]int)
+
+	for _, peer := range h.pcRepo.GetAll() {
+		peersByChannel[peer.ChannelID]++
+
+		resp.Peers = append(resp.Peers, dto.DebugPeer{
+			UserID:          peer.UserID,
+			ChannelID:       peer.ChannelID,
+			ConnectionState: peer.Conn.ConnectionState().String(),
+			ICEState:        peer.Conn.ICEConnectionState().String(),
+			ICEGathering:    peer.Conn.ICEGatheringState().String(),
+			DTLSState:       peer.Conn.SCTP().Transport().State().String(),
+			SignalingState:  peer.Conn.SignalingState().String(),
+		})
+	}
+
+	for channelID, peers := range peersByChannel {
+		activeUsers := h.activeUserRepo.GetInChannel(c.Request().Context(), channelID)
+
+		userIDs := make([]uuid.UUID, 0, len(activeUsers))
+		for _, activeUser := range activeUsers {
+			userIDs = append(userIDs, activeUser.ID)
+		}
+
+		resp.Channels = append(resp.Channels, dto.DebugChannel{
+			ID:          channelID,
+			ActiveUsers: userIDs,
+			Peers:       peers,
+		})
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
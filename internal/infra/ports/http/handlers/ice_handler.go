@@ -1,17 +1,15 @@
 package handlers
 
 import (
-	"crypto/hmac"
-	"crypto/sha1"
-	"encoding/base64"
-	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/labstack/echo/v4"
-	"github.com/pion/webrtc/v4"
 
 	"github.com/qrave1/RoomSpeak/internal/application/config"
+	"github.com/qrave1/RoomSpeak/internal/infra/adapters/turn"
+	"github.com/qrave1/RoomSpeak/internal/infra/appctx"
+	"github.com/qrave1/RoomSpeak/internal/infra/ports/http/dto"
 )
 
 type IceHandler struct {
@@ -22,24 +20,21 @@ func NewIceHandler(cfg *config.Config) *IceHandler {
 	return &IceHandler{cfg: cfg}
 }
 
-// Handler для выдачи ICE серверов
+// Handler для выдачи ICE серверов с временными TURN кредами, привязанными к пользователю
 func (h *IceHandler) IceServers(c echo.Context) error {
-	expiration := time.Now().Add(time.Hour).Unix()
-	username := fmt.Sprintf("%d", expiration)
-
-	// Создаём HMAC-SHA1 с использованием static-auth-secret
-	mac := hmac.New(sha1.New, []byte(h.cfg.CoturnServer.Secret))
-	mac.Write([]byte(username))
-	password := base64.StdEncoding.EncodeToString(mac.Sum(nil))
-
-	response := webrtc.ICEServer{
-		URLs: []string{
-			h.cfg.TurnUDPServer.URLs[0],
-			h.cfg.TurnTCPServer.URLs[0],
-		},
-		Username:   username,
-		Credential: password,
+	userID, ok := appctx.UserID(c.Request().Context())
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid user"})
 	}
 
-	return c.JSON(http.StatusOK, response)
+	expiresAt := time.Now().Add(h.cfg.ICE.CredentialTTL)
+	username, credential := turn.Credentials(h.cfg.TURNSecret(), userID.String(), expiresAt)
+
+	return c.JSON(http.StatusOK, dto.IceServersResponse{
+		URLs:       h.cfg.ICE.TURN(),
+		Username:   username,
+		Credential: credential,
+		ICEServers: h.cfg.ICE.ICEServers(username, credential),
+		ExpiresAt:  expiresAt,
+	})
 }
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"github.com/qrave1/RoomSpeak/internal/application/constant"
+	"github.com/qrave1/RoomSpeak/internal/domain/input"
+	"github.com/qrave1/RoomSpeak/internal/infra/appctx"
+	"github.com/qrave1/RoomSpeak/internal/infra/ports/http/dto"
+	"github.com/qrave1/RoomSpeak/internal/usecase"
+)
+
+type ProfileHandler struct {
+	profileUsecase usecase.ProfileUsecase
+}
+
+func NewProfileHandler(profileUsecase usecase.ProfileUsecase) *ProfileHandler {
+	return &ProfileHandler{profileUsecase: profileUsecase}
+}
+
+func (h *ProfileHandler) GetMyProfile(c echo.Context) error {
+	userID, ok := appctx.UserID(c.Request().Context())
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid user"})
+	}
+
+	profile, err := h.profileUsecase.GetProfile(c.Request().Context(), userID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "user not found"})
+	}
+
+	return c.JSON(http.StatusOK, profile)
+}
+
+func (h *ProfileHandler) UpdateMyProfile(c echo.Context) error {
+	var req dto.UpdateProfileRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+
+	userID, ok := appctx.UserID(c.Request().Context())
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid user"})
+	}
+
+	profile, err := h.profileUsecase.UpdateProfile(c.Request().Context(), &input.UpdateProfileInput{
+		UserID:      userID,
+		DisplayName: req.DisplayName,
+		Bio:         req.Bio,
+		AccentColor: req.AccentColor,
+	})
+	if err != nil {
+		if errors.Is(err, usecase.ErrInvalidProfile) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+
+		slog.Error("update profile", slog.Any(constant.Error, err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "could not update profile"})
+	}
+
+	return c.JSON(http.StatusOK, profile)
+}
+
+// UploadMyAvatar принимает multipart форму с файлом в поле "avatar"
+func (h *ProfileHandler) UploadMyAvatar(c echo.Context) error {
+	userID, ok := appctx.UserID(c.Request().Context())
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid user"})
+	}
+
+	fileHeader, err := c.FormFile("avatar")
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "avatar file is required"})
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "could not read avatar file"})
+	}
+	defer file.Close()
+
+	profile, err := h.profileUsecase.UploadAvatar(c.Request().Context(), userID, file, fileHeader.Size)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrAvatarTooLarge):
+			return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{"error": err.Error()})
+		case errors.Is(err, usecase.ErrUnsupportedAvatar):
+			return c.JSON(http.StatusUnsupportedMediaType, map[string]string{"error": err.Error()})
+		}
+
+		slog.Error("upload avatar", slog.Any(constant.Error, err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "could not upload avatar"})
+	}
+
+	return c.JSON(http.StatusOK, profile)
+}
+
+func (h *ProfileHandler) DeleteMyAvatar(c echo.Context) error {
+	userID, ok := appctx.UserID(c.Request().Context())
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid user"})
+	}
+
+	profile, err := h.profileUsecase.DeleteAvatar(c.Request().Context(), userID)
+	if err != nil {
+		slog.Error("delete avatar", slog.Any(constant.Error, err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "could not delete avatar"})
+	}
+
+	return c.JSON(http.StatusOK, profile)
+}
+
+func (h *ProfileHandler) GetUserProfile(c echo.Context) error {
+	userID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid user id"})
+	}
+
+	profile, err := h.profileUsecase.GetProfile(c.Request().Context(), userID)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "user not found"})
+	}
+
+	return c.JSON(http.StatusOK, profile)
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"github.com/qrave1/RoomSpeak/internal/application/constant"
+	"github.com/qrave1/RoomSpeak/internal/infra/appctx"
+	"github.com/qrave1/RoomSpeak/internal/infra/ports/http/dto"
+	"github.com/qrave1/RoomSpeak/internal/usecase"
+)
+
+type QualityHandler struct {
+	qualityUsecase usecase.QualityUsecase
+}
+
+func NewQualityHandler(qualityUsecase usecase.QualityUsecase) *QualityHandler {
+	return &QualityHandler{qualityUsecase: qualityUsecase}
+}
+
+// ChannelStats отдает потери, джиттер, RTT, битрейт и тип ICE кандидата участников канала
+func (h *QualityHandler) ChannelStats(c echo.Context) error {
+	userID, ok := appctx.UserID(c.Request().Context())
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid user"})
+	}
+
+	channelID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid channel id"})
+	}
+
+	qualities, err := h.qualityUsecase.ChannelQuality(c.Request().Context(), userID, channelID)
+	if err != nil {
+		switch {
+		case errors.Is(err, usecase.ErrChannelNotFound):
+			return c.JSON(http.StatusNotFound, map[string]string{"error": "channel not found"})
+		case errors.Is(err, usecase.ErrChannelAccessDenied):
+			return c.JSON(http.StatusForbidden, map[string]string{"error": "no access to channel"})
+		}
+
+		slog.Error("get channel quality", slog.Any(constant.Error, err))
+
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "failed to get channel stats"})
+	}
+
+	return c.JSON(http.StatusOK, dto.ChannelStatsResponse{
+		ChannelID:    channelID.String(),
+		Participants: qualities,
+	})
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+
+	"github.com/qrave1/RoomSpeak/internal/application/constant"
+	"github.com/qrave1/RoomSpeak/internal/domain/input"
+	"github.com/qrave1/RoomSpeak/internal/infra/appctx"
+	"github.com/qrave1/RoomSpeak/internal/infra/ports/http/dto"
+	"github.com/qrave1/RoomSpeak/internal/usecase"
+)
+
+type TokenHandler struct {
+	tokenUsecase usecase.TokenUsecase
+}
+
+func NewTokenHandler(tokenUsecase usecase.TokenUsecase) *TokenHandler {
+	return &TokenHandler{tokenUsecase: tokenUsecase}
+}
+
+func (h *TokenHandler) CreateBot(c echo.Context) error {
+	var req dto.CreateBotRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+
+	if req.Username == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "username is required"})
+	}
+
+	userID, ok := appctx.UserID(c.Request().Context())
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid user"})
+	}
+
+	bot, err := h.tokenUsecase.CreateBot(c.Request().Context(), userID, req.Username)
+	if err != nil {
+		if errors.Is(err, usecase.ErrNotTokenOwner) {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": "bots cannot create bots"})
+		}
+
+		if errors.Is(err, usecase.ErrUsernameTaken) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": "username is already taken"})
+		}
+
+		slog.Error("create bot", slog.Any(constant.Error, err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "could not create bot"})
+	}
+
+	return c.JSON(http.StatusCreated, dto.NewBotResponseFromModel(bot))
+}
+
+func (h *TokenHandler) ListBots(c echo.Context) error {
+	userID, ok := appctx.UserID(c.Request().Context())
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid user"})
+	}
+
+	bots, err := h.tokenUsecase.GetBots(c.Request().Context(), userID)
+	if err != nil {
+		slog.Error("get bots", slog.Any(constant.Error, err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "could not get bots"})
+	}
+
+	resp := make([]dto.BotResponse, 0, len(bots))
+	for _, bot := range bots {
+		resp = append(resp, dto.NewBotResponseFromModel(bot))
+	}
+
+	return c.JSON(http.StatusOK, resp)
+}
+
+func (h *TokenHandler) CreateToken(c echo.Context) error {
+	var req dto.CreateTokenRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid request"})
+	}
+
+	if req.Name == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "name is required"})
+	}
+
+	if req.TTLHours < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "ttl_hours must not be negative"})
+	}
+
+	userID, ok := appctx.UserID(c.Request().Context())
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid user"})
+	}
+
+	createTokenInput := &input.CreateTokenInput{
+		UserID: userID,
+		Name:   req.Name,
+		Scopes: req.Scopes,
+		TTL:    time.Duration(req.TTLHours) * time.Hour,
+	}
+
+	if req.BotID != nil {
+		createTokenInput.UserID = *req.BotID
+	}
+
+	scopes, ok := appctx.Scopes(c.Request().Context())
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid user"})
+	}
+
+	raw, token, err := h.tokenUsecase.CreateToken(c.Request().Context(), userID, scopes, createTokenInput)
+	if err != nil {
+		return h.tokenError(c, "create token", err)
+	}
+
+	return c.JSON(http.StatusCreated, dto.CreateTokenResponse{Token: raw, APIToken: token})
+}
+
+func (h *TokenHandler) ListTokens(c echo.Context) error {
+	userID, ok := appctx.UserID(c.Request().Context())
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid user"})
+	}
+
+	ownerID := userID
+
+	if botIDStr := c.QueryParam("bot_id"); botIDStr != "" {
+		botID, err := uuid.Parse(botIDStr)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid bot id"})
+		}
+
+		ownerID = botID
+	}
+
+	tokens, err := h.tokenUsecase.ListTokens(c.Request().Context(), userID, ownerID)
+	if err != nil {
+		return h.tokenError(c, "list tokens", err)
+	}
+
+	return c.JSON(http.StatusOK, dto.ListTokensResponse{Tokens: tokens})
+}
+
+func (h *TokenHandler) RevokeToken(c echo.Context) error {
+	tokenID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid token id"})
+	}
+
+	userID, ok := appctx.UserID(c.Request().Context())
+	if !ok {
+		return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid user"})
+	}
+
+	if err = h.tokenUsecase.RevokeToken(c.Request().Context(), userID, tokenID); err != nil {
+		return h.tokenError(c, "revoke token", err)
+	}
+
+	return c.NoContent(http.StatusOK)
+}
+
+func (h *TokenHandler) tokenError(c echo.Context, op string, err error) error {
+	switch {
+	case errors.Is(err, usecase.ErrNotTokenOwner), errors.Is(err, usecase.ErrScopeNotHeld):
+		return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+	case errors.Is(err, usecase.ErrUnknownScope):
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	case errors.Is(err, usecase.ErrTokenNotFound), errors.Is(err, usecase.ErrBotNotFound):
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	default:
+		slog.Error(op, slog.Any(constant.Error, err))
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "could not " + op})
+	}
+}
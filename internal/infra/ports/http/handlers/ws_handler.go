@@ -13,24 +13,64 @@ import (
 
 	"github.com/gorilla/websocket"
 	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/qrave1/RoomSpeak/internal/application/config"
 	"github.com/qrave1/RoomSpeak/internal/application/constant"
+	"github.com/qrave1/RoomSpeak/internal/application/metrics"
+	"github.com/qrave1/RoomSpeak/internal/application/tracing"
 	"github.com/qrave1/RoomSpeak/internal/domain/events"
+	"github.com/qrave1/RoomSpeak/internal/domain/runtime"
 	"github.com/qrave1/RoomSpeak/internal/infra/adapters/memory"
 	"github.com/qrave1/RoomSpeak/internal/infra/appctx"
 	"github.com/qrave1/RoomSpeak/internal/usecase"
 )
 
+// knownMessageTypes ограничивает метки метрик, чтобы клиент не мог создать произвольные
+var knownMessageTypes = map[string]bool{
+	"join":              true,
+	"offer":             true,
+	"answer":            true,
+	"candidate":         true,
+	"leave":             true,
+	"mute":              true,
+	"ping":              true,
+	"set_status":        true,
+	"set_custom_status": true,
+	"track_meta":        true,
+	"video_constraints": true,
+	"raise_hand":        true,
+	"lower_hand":        true,
+	"approve_speaker":   true,
+	"move_to_audience":  true,
+	"ptt_start":         true,
+	"ptt_stop":          true,
+	"whisper_start":     true,
+	"whisper_stop":      true,
+	"breakout_start":    true,
+	"breakout_stop":     true,
+	"admit_user":        true,
+	"reject_user":       true,
+}
+
 type WebSocketHandler struct {
 	upgrader *websocket.Upgrader
 
 	signalingUsecase usecase.SignalingUsecase
+	presenceUsecase  usecase.PresenceUsecase
+	floorUsecase     usecase.FloorUsecase
 
 	wsConnRepo memory.WebsocketConnectionRepository
 }
 
-func NewWebSocketHandler(cfg *config.Config, signalingUsecase usecase.SignalingUsecase, wsConnRepo memory.WebsocketConnectionRepository) *WebSocketHandler {
+func NewWebSocketHandler(
+	cfg *config.Config,
+	signalingUsecase usecase.SignalingUsecase,
+	presenceUsecase usecase.PresenceUsecase,
+	floorUsecase usecase.FloorUsecase,
+	wsConnRepo memory.WebsocketConnectionRepository,
+) *WebSocketHandler {
 	return &WebSocketHandler{
 		upgrader: &websocket.Upgrader{
 			ReadBufferSize:  1024,
@@ -40,15 +80,25 @@ func NewWebSocketHandler(cfg *config.Config, signalingUsecase usecase.SignalingU
 					return true
 				}
 
-				return r.Header.Get("Origin") == cfg.Domain
+				origin := r.Header.Get("Origin")
+
+				// Боты и CLI клиенты не присылают Origin, браузер присылает всегда
+				return origin == "" || origin == cfg.Domain
 			},
 		},
 		signalingUsecase: signalingUsecase,
+		presenceUsecase:  presenceUsecase,
+		floorUsecase:     floorUsecase,
 		wsConnRepo:       wsConnRepo,
 	}
 }
 
 func (h *WebSocketHandler) Handle(c echo.Context) error {
+	// Остановившаяся нода не берет новые соединения, клиент переподключится к другой
+	if h.signalingUsecase.Draining() {
+		return c.JSON(http.StatusServiceUnavailable, map[string]string{"error": "server is shutting down"})
+	}
+
 	ws, err := h.upgrader.Upgrade(c.Response(), c.Request(), nil)
 	if err != nil {
 		slog.Error(
@@ -67,6 +117,15 @@ func (h *WebSocketHandler) Handle(c echo.Context) error {
 	h.wsConnRepo.Add(userID, ws)
 	defer h.wsConnRepo.Remove(userID)
 
+	metrics.WebsocketConnections.Inc()
+	defer metrics.WebsocketConnections.Dec()
+
+	h.presenceUsecase.Connect(c.Request().Context(), userID)
+	defer h.presenceUsecase.Disconnect(context.WithoutCancel(c.Request().Context()), userID)
+
+	// Ожидающий входа не в звонке, поэтому HandleLeave его не найдет
+	defer h.signalingUsecase.LeaveWaitingRoom(context.WithoutCancel(c.Request().Context()), userID)
+
 	err = ws.SetReadDeadline(time.Now().Add(60 * time.Second))
 	if err != nil {
 		return err
@@ -123,9 +182,31 @@ func (h *WebSocketHandler) Handle(c echo.Context) error {
 				return nil
 			}
 
-			if err = h.handleMessage(c.Request().Context(), signalMessage); err != nil {
+			messageType := signalMessage.Type
+			if !knownMessageTypes[messageType] {
+				messageType = "unknown"
+			}
+
+			// Соединение живет долго, поэтому каждое сообщение - отдельный трейс
+			// со ссылкой на спан подключения
+			msgCtx, span := tracing.Start(c.Request().Context(), "signaling."+messageType,
+				trace.WithNewRoot(),
+				trace.WithLinks(trace.LinkFromContext(c.Request().Context())),
+				trace.WithAttributes(attribute.String("user.id", userID.String())),
+			)
+
+			start := time.Now()
+
+			result := "ok"
+			if err = h.handleMessage(msgCtx, signalMessage); err != nil {
+				result = "error"
 				slog.Error("handle message", slog.Any(constant.Error, err))
 			}
+
+			tracing.End(span, err)
+
+			metrics.SignalingMessages.WithLabelValues(messageType, result).Inc()
+			metrics.SignalingDuration.WithLabelValues(messageType).Observe(time.Since(start).Seconds())
 		}
 	}
 }
@@ -202,7 +283,160 @@ func (h *WebSocketHandler) handleMessage(
 		}
 
 	case "ping":
-		h.signalingUsecase.HandlePing(ctx, userID)
+		var pingEvent events.PingEvent
+
+		// Старые клиенты присылают ping без данных
+		if len(msg.Data) > 0 {
+			if err := json.Unmarshal(msg.Data, &pingEvent); err != nil {
+				return fmt.Errorf("unmarshal ping event: %w", err)
+			}
+		}
+
+		h.signalingUsecase.HandlePing(ctx, userID, pingEvent)
+
+	case "set_status":
+		var statusEvent events.SetStatusEvent
+
+		if err := json.Unmarshal(msg.Data, &statusEvent); err != nil {
+			return fmt.Errorf("unmarshal set status event: %w", err)
+		}
+
+		h.presenceUsecase.SetStatus(ctx, userID, runtime.PresenceStatus(statusEvent.Status))
+
+	case "set_custom_status":
+		var customStatusEvent events.SetCustomStatusEvent
+
+		if err := json.Unmarshal(msg.Data, &customStatusEvent); err != nil {
+			return fmt.Errorf("unmarshal set custom status event: %w", err)
+		}
+
+		h.presenceUsecase.SetCustomStatus(
+			ctx,
+			userID,
+			customStatusEvent.Text,
+			time.Duration(max(customStatusEvent.ExpiresIn, 0))*time.Second,
+		)
+
+	case "track_meta":
+		var trackMetaEvent events.TrackMetaEvent
+
+		if err := json.Unmarshal(msg.Data, &trackMetaEvent); err != nil {
+			return fmt.Errorf("unmarshal track meta event: %w", err)
+		}
+
+		if err := h.signalingUsecase.HandleTrackMeta(ctx, userID, trackMetaEvent); err != nil {
+			return fmt.Errorf("handle track meta: %w", err)
+		}
+
+	case "video_constraints":
+		var videoConstraintsEvent events.VideoConstraintsEvent
+
+		if err := json.Unmarshal(msg.Data, &videoConstraintsEvent); err != nil {
+			return fmt.Errorf("unmarshal video constraints event: %w", err)
+		}
+
+		if err := h.signalingUsecase.HandleVideoConstraints(ctx, userID, videoConstraintsEvent); err != nil {
+			return fmt.Errorf("handle video constraints: %w", err)
+		}
+
+	case "raise_hand":
+		if err := h.signalingUsecase.HandleRaiseHand(ctx, userID); err != nil {
+			return fmt.Errorf("handle raise hand: %w", err)
+		}
+
+	case "lower_hand":
+		stageUserEvent, ok, err := h.stageUserEvent(userID, msg, false)
+		if err != nil || !ok {
+			return err
+		}
+
+		if err = h.signalingUsecase.HandleLowerHand(ctx, userID, stageUserEvent); err != nil {
+			return fmt.Errorf("handle lower hand: %w", err)
+		}
+
+	case "approve_speaker":
+		stageUserEvent, ok, err := h.stageUserEvent(userID, msg, true)
+		if err != nil || !ok {
+			return err
+		}
+
+		if err = h.signalingUsecase.HandleApproveSpeaker(ctx, userID, stageUserEvent); err != nil {
+			return fmt.Errorf("handle approve speaker: %w", err)
+		}
+
+	case "move_to_audience":
+		stageUserEvent, ok, err := h.stageUserEvent(userID, msg, false)
+		if err != nil || !ok {
+			return err
+		}
+
+		if err = h.signalingUsecase.HandleMoveToAudience(ctx, userID, stageUserEvent); err != nil {
+			return fmt.Errorf("handle move to audience: %w", err)
+		}
+
+	case "ptt_start":
+		if err := h.floorUsecase.RequestFloor(ctx, userID); err != nil {
+			return fmt.Errorf("request floor: %w", err)
+		}
+
+	case "ptt_stop":
+		if err := h.floorUsecase.ReleaseFloor(ctx, userID); err != nil {
+			return fmt.Errorf("release floor: %w", err)
+		}
+
+	case "whisper_start":
+		var whisperStartEvent events.WhisperStartEvent
+
+		if err := json.Unmarshal(msg.Data, &whisperStartEvent); err != nil {
+			return fmt.Errorf("unmarshal whisper start event: %w", err)
+		}
+
+		if err := h.signalingUsecase.HandleWhisperStart(ctx, userID, whisperStartEvent); err != nil {
+			return fmt.Errorf("handle whisper start: %w", err)
+		}
+
+	case "whisper_stop":
+		if err := h.signalingUsecase.HandleWhisperStop(ctx, userID); err != nil {
+			return fmt.Errorf("handle whisper stop: %w", err)
+		}
+
+	case "breakout_start":
+		var breakoutStartEvent events.BreakoutStartEvent
+
+		if err := json.Unmarshal(msg.Data, &breakoutStartEvent); err != nil {
+			return fmt.Errorf("unmarshal breakout start event: %w", err)
+		}
+
+		if err := h.signalingUsecase.HandleBreakoutStart(ctx, userID, breakoutStartEvent); err != nil {
+			return fmt.Errorf("handle breakout start: %w", err)
+		}
+
+	case "breakout_stop":
+		if err := h.signalingUsecase.HandleBreakoutStop(ctx, userID); err != nil {
+			return fmt.Errorf("handle breakout stop: %w", err)
+		}
+
+	case "admit_user":
+		var waitingUserEvent events.WaitingUserEvent
+
+		if err := json.Unmarshal(msg.Data, &waitingUserEvent); err != nil {
+			return fmt.Errorf("unmarshal admit user event: %w", err)
+		}
+
+		if err := h.signalingUsecase.HandleAdmitUser(ctx, userID, waitingUserEvent); err != nil {
+			return fmt.Errorf("handle admit user: %w", err)
+		}
+
+	case "reject_user":
+		var waitingUserEvent events.WaitingUserEvent
+
+		if err := json.Unmarshal(msg.Data, &waitingUserEvent); err != nil {
+			return fmt.Errorf("unmarshal reject user event: %w", err)
+		}
+
+		if err := h.signalingUsecase.HandleRejectUser(ctx, userID, waitingUserEvent); err != nil {
+			return fmt.Errorf("handle reject user: %w", err)
+		}
 
 	default:
 		return errors.New("unknown message type")
@@ -211,6 +445,29 @@ func (h *WebSocketHandler) handleMessage(
 	return nil
 }
 
+// stageUserEvent разбирает участника, над которым выполняется действие на сцене.
+// Без данных действие выполняется над собой, если цель не обязательна
+func (h *WebSocketHandler) stageUserEvent(
+	userID uuid.UUID,
+	msg *events.Message,
+	targetRequired bool,
+) (events.StageUserEvent, bool, error) {
+	var stageUserEvent events.StageUserEvent
+
+	if len(msg.Data) > 0 {
+		if err := json.Unmarshal(msg.Data, &stageUserEvent); err != nil {
+			return events.StageUserEvent{}, false, fmt.Errorf("unmarshal stage user event: %w", err)
+		}
+	}
+
+	if targetRequired && stageUserEvent.UserID == "" {
+		h.wsConnRepo.Write(userID, map[string]any{"type": constant.Error, "message": "user_id is required"})
+		return events.StageUserEvent{}, false, nil
+	}
+
+	return stageUserEvent, true, nil
+}
+
 func (h *WebSocketHandler) handleWebsocketError(ctx context.Context, err error) {
 	userID, ok := appctx.UserID(ctx)
 	if !ok {
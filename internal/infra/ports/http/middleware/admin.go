@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/qrave1/RoomSpeak/internal/application/config"
+	"github.com/qrave1/RoomSpeak/internal/infra/appctx"
+)
+
+// AdminOnly пропускает только пользователей из ADMIN_USER_IDS, ставится после JWTAuthMiddleware
+func AdminOnly(cfg *config.Config) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			userID, ok := appctx.UserID(c.Request().Context())
+			if !ok || !cfg.IsAdmin(userID) {
+				return c.JSON(http.StatusForbidden, map[string]string{"error": "admin access required"})
+			}
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+
+	"github.com/qrave1/RoomSpeak/internal/application/config"
+)
+
+// SessionCookies собирает cookie с JWT по настройкам из config.CookieConfig,
+// чтобы login, logout и refresh выставляли одинаковые атрибуты
+type SessionCookies struct {
+	name     string
+	domain   string
+	secure   bool
+	sameSite http.SameSite
+	ttl      time.Duration
+}
+
+// NewSessionCookies ожидает настройки, уже проверенные в config.New
+func NewSessionCookies(cfg *config.Config) *SessionCookies {
+	secure := cfg.CookieSecure()
+
+	sameSite := http.SameSiteLaxMode
+	switch cfg.Cookie.SameSite {
+	case "strict":
+		sameSite = http.SameSiteStrictMode
+	case "none":
+		sameSite = http.SameSiteNoneMode
+	case "auto":
+		if secure {
+			sameSite = http.SameSiteNoneMode
+		}
+	}
+
+	domain := cfg.Cookie.Domain
+	if domain == "auto" {
+		domain = BuildCookieDomain(hostFromURL(cfg.Domain))
+	}
+
+	return &SessionCookies{
+		name:     cfg.Cookie.Name,
+		domain:   domain,
+		secure:   secure,
+		sameSite: sameSite,
+		ttl:      cfg.Cookie.TTL,
+	}
+}
+
+// Name возвращает имя cookie с JWT
+func (s *SessionCookies) Name() string {
+	return s.name
+}
+
+// TTL возвращает время жизни сессии
+func (s *SessionCookies) TTL() time.Duration {
+	return s.ttl
+}
+
+// New создает cookie с токеном сессии
+func (s *SessionCookies) New(token string) *http.Cookie {
+	return s.build(token, time.Now().Add(s.ttl), int(s.ttl.Seconds()))
+}
+
+// Expired создает cookie, удаляющую сессию в браузере
+func (s *SessionCookies) Expired() *http.Cookie {
+	return s.build("", time.Unix(0, 0), -1)
+}
+
+func (s *SessionCookies) build(value string, expires time.Time, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     s.name,
+		Value:    value,
+		Expires:  expires,
+		MaxAge:   maxAge,
+		Domain:   s.domain,
+		Path:     "/",
+		Secure:   s.secure,
+		HttpOnly: true,
+		SameSite: s.sameSite,
+	}
+}
+
+// BuildCookieDomain возвращает значение для cookie.Domain или пустую строку, если Domain не нужно задавать.
+//
+// Для поддоменов возвращается зарегистрированный домен с точкой: api.example.com -> .example.com,
+// app.example.co.uk -> .example.co.uk (с учетом public suffix list).
+func BuildCookieDomain(host string) string {
+	// Убираем порт если передан: example.com:8080 -> example.com
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSpace(host)
+	host = strings.ToLower(host)
+	host = strings.TrimSuffix(host, ".")
+	if host == "" {
+		return ""
+	}
+
+	// Если localhost или IP — не указываем Domain, браузер привяжет cookie к хосту
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ""
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ""
+	}
+
+	root, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		// host сам является публичным суффиксом или не содержит точек
+		return ""
+	}
+
+	return "." + root
+}
+
+// hostFromURL достает хост из DOMAIN, который может быть задан как URL или как голый хост
+func hostFromURL(raw string) string {
+	if u, err := url.Parse(raw); err == nil && u.Host != "" {
+		return u.Host
+	}
+
+	return raw
+}
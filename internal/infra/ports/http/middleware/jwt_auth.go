@@ -1,92 +1,145 @@
 package middleware
 
 import (
-	"net"
+	"context"
+	"errors"
+	"log/slog"
 	"net/http"
 	"strings"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
 	"github.com/labstack/echo/v4"
 
+	"github.com/qrave1/RoomSpeak/internal/application/constant"
+	"github.com/qrave1/RoomSpeak/internal/domain/models"
 	"github.com/qrave1/RoomSpeak/internal/infra/appctx"
 )
 
-func JWTAuthMiddleware(secret string) echo.MiddlewareFunc {
+// TokenAuthenticator проверяет персональные API токены и владельцев сессий
+type TokenAuthenticator interface {
+	AuthenticateToken(ctx context.Context, raw string) (*models.APIToken, error)
+
+	// SessionUserExists - жив ли пользователь из JWT. У удаленного аккаунта сессия
+	// должна умереть сразу, а не по истечении cookie
+	SessionUserExists(ctx context.Context, userID uuid.UUID) (bool, error)
+}
+
+// JWTAuthMiddleware авторизует запрос по cookie сессии или по заголовку
+// "Authorization: Bearer <token>", где token - JWT или персональный API токен.
+func JWTAuthMiddleware(secret string, cookies *SessionCookies, tokenAuth TokenAuthenticator) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			cookie, err := c.Cookie("jwt")
-			if err != nil {
-				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "missing or malformed jwt"})
+			var (
+				userID uuid.UUID
+				err    error
+				// Сессия пользователя может все, API токен - только выданное ему
+				scopes = models.AllScopes
+				// session - запрос пришел с JWT, только такой можно обменять на новую сессию
+				session = true
+			)
+
+			raw, isBearer := bearerToken(c.Request())
+
+			switch {
+			case isBearer && strings.HasPrefix(raw, models.APITokenPrefix):
+				token, authErr := tokenAuth.AuthenticateToken(c.Request().Context(), raw)
+				if authErr != nil {
+					return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid or revoked api token"})
+				}
+
+				if scope := requiredScope(c.Request()); !token.HasScope(scope) {
+					return c.JSON(http.StatusForbidden, map[string]string{"error": "api token lacks scope " + scope})
+				}
+
+				userID = token.UserID
+				scopes = token.Scopes
+				session = false
+			case isBearer:
+				userID, err = parseJWT(raw, secret)
+			default:
+				cookie, cookieErr := c.Cookie(cookies.Name())
+				if cookieErr != nil {
+					return c.JSON(http.StatusUnauthorized, map[string]string{"error": "missing or malformed jwt"})
+				}
+
+				userID, err = parseJWT(cookie.Value, secret)
 			}
 
-			token, err := jwt.ParseWithClaims(cookie.Value, &jwt.RegisteredClaims{}, func(token *jwt.Token) (any, error) {
-				return []byte(secret), nil
-			})
 			if err != nil {
-				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid or expired jwt"})
+				return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
 			}
 
-			claims, ok := token.Claims.(*jwt.RegisteredClaims)
-			if !ok || !token.Valid {
-				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid or expired jwt"})
+			if session {
+				exists, existsErr := tokenAuth.SessionUserExists(c.Request().Context(), userID)
+				if existsErr != nil {
+					slog.Error("check session user", slog.Any(constant.Error, existsErr), slog.Any(constant.UserID, userID))
+
+					return c.JSON(http.StatusInternalServerError, map[string]string{"error": "could not check session"})
+				}
+
+				if !exists {
+					return c.JSON(http.StatusUnauthorized, map[string]string{"error": "user no longer exists"})
+				}
 			}
 
-			userID, err := uuid.Parse(claims.Subject)
-			if err != nil {
-				return c.JSON(http.StatusUnauthorized, map[string]string{"error": "invalid subject"})
+			ctx := appctx.WithUserID(c.Request().Context(), userID)
+			ctx = appctx.WithScopes(ctx, scopes)
+
+			if session {
+				ctx = appctx.WithSession(ctx)
 			}
 
-			c.SetRequest(
-				c.Request().WithContext(
-					appctx.WithUserID(c.Request().Context(), userID),
-				),
-			)
+			c.SetRequest(c.Request().WithContext(ctx))
 
 			return next(c)
 		}
 	}
 }
 
-// BuildCookieDomain возвращает значение для cookie.Domain или пустую строку, если Domain не нужно задавать.
-//
-// host может быть взят из cfg.Domain или r.Host (request.Host).
-// prodMode — если true, более строгое поведение (вставляем .example.com для поддоменов).
-func BuildCookieDomain(host string, isProd bool) string {
-	// Убираем порт если передан: example.com:8080 -> example.com
-	if i := strings.Index(host, ":"); i != -1 {
-		host = host[:i]
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get(echo.HeaderAuthorization)
+
+	raw, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || raw == "" {
+		return "", false
 	}
-	host = strings.TrimSpace(host)
-	host = strings.ToLower(host)
-	if host == "" {
-		return ""
+
+	return strings.TrimSpace(raw), true
+}
+
+func parseJWT(raw, secret string) (uuid.UUID, error) {
+	token, err := jwt.ParseWithClaims(raw, &jwt.RegisteredClaims{}, func(token *jwt.Token) (any, error) {
+		return []byte(secret), nil
+	})
+	if err != nil {
+		return uuid.Nil, errors.New("invalid or expired jwt")
 	}
 
-	// Если localhost или loopback IP — не указываем Domain
-	if host == "localhost" {
-		return ""
+	claims, ok := token.Claims.(*jwt.RegisteredClaims)
+	if !ok || !token.Valid {
+		return uuid.Nil, errors.New("invalid or expired jwt")
 	}
-	if ip := net.ParseIP(host); ip != nil {
-		// для реального IP (127.0.0.1, 192.168.x.x) не указываем Domain
-		return ""
+
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, errors.New("invalid subject")
 	}
 
-	// Если это субдомен вида api.example.com -> хотим .example.com
-	parts := strings.Split(host, ".")
-	if len(parts) >= 2 {
-		// для домена из двух частей (example.com) ставим .example.com
-		// для более длинных (sub.api.example.co.uk) логика оставляет последние 2 части:
-		// можно усложнить, если надо поддержать co.uk — но это базовый вариант.
-		root := strings.Join(parts[len(parts)-2:], ".")
-		// Если isProd мы уверены, что хотим корневой домен; в dev можно вернуть host (без dot) или empty.
-		if isProd {
-			return "." + root
-		}
-		// dev: если host уже равен root (example.com) — возвращаем .example.com, иначе .root
-		return "." + root
+	return userID, nil
+}
+
+// requiredScope определяет скоуп токена, необходимый для запроса
+func requiredScope(r *http.Request) string {
+	if websocket.IsWebSocketUpgrade(r) {
+		return models.ScopeWS
 	}
 
-	// fallback: ничего
-	return ""
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return models.ScopeRead
+	default:
+		return models.ScopeWrite
+	}
 }
@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/qrave1/RoomSpeak/internal/application/metrics"
+)
+
+// Metrics считает HTTP запросы и их длительность по шаблону маршрута
+func Metrics() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+
+			err := next(c)
+			if err != nil {
+				c.Error(err)
+			}
+
+			// Шаблон маршрута вместо URI, чтобы не плодить метки на каждый id
+			route := c.Path()
+			if route == "" {
+				route = "unknown"
+			}
+
+			metrics.HTTPRequests.WithLabelValues(c.Request().Method, route, strconv.Itoa(c.Response().Status)).Inc()
+			metrics.HTTPDuration.WithLabelValues(c.Request().Method, route).Observe(time.Since(start).Seconds())
+
+			return nil
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/qrave1/RoomSpeak/internal/application/config"
+)
+
+// NewMetrics - сервер /metrics на внутреннем адресе METRICS_LISTEN_ADDR
+func NewMetrics(cfg *config.Config) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.Handler())
+
+	return &http.Server{
+		Addr:              cfg.Metrics.ListenAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+}
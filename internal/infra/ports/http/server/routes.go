@@ -3,18 +3,27 @@ package server
 import (
 	"github.com/labstack/echo/v4"
 	emiddleware "github.com/labstack/echo/v4/middleware"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/labstack/echo/otelecho"
 
 	"github.com/qrave1/RoomSpeak/internal/application/config"
+	"github.com/qrave1/RoomSpeak/internal/infra/adapters/storage"
 	"github.com/qrave1/RoomSpeak/internal/infra/ports/http/handlers"
 	"github.com/qrave1/RoomSpeak/internal/infra/ports/http/middleware"
 )
 
 func New(
 	cfg *config.Config,
+	cookies *middleware.SessionCookies,
 	authHandler *handlers.AuthHandler,
+	tokenHandler *handlers.TokenHandler,
+	profileHandler *handlers.ProfileHandler,
+	accountHandler *handlers.AccountHandler,
+	tokenAuth middleware.TokenAuthenticator,
 	channelHandler *handlers.ChannelHandler,
+	qualityHandler *handlers.QualityHandler,
 	iceHandler *handlers.IceHandler,
 	wsHandler *handlers.WebSocketHandler,
+	healthHandler *handlers.HealthHandler,
 ) *echo.Echo {
 	e := echo.New()
 
@@ -29,18 +38,46 @@ func New(
 
 	//e.Use(middleware.SlogLogger())
 
+	e.Use(middleware.Metrics())
+
+	e.Use(otelecho.Middleware(cfg.Tracing.ServiceName, otelecho.WithSkipper(func(c echo.Context) bool {
+		switch c.Path() {
+		case "/healthz", "/readyz":
+			return true
+		}
+
+		return false
+	})))
+
+	e.GET("/healthz", healthHandler.Liveness)
+	e.GET("/readyz", healthHandler.Readiness)
+
+	debug := e.Group("/debug", middleware.JWTAuthMiddleware(cfg.JWTSecret, cookies, tokenAuth), middleware.AdminOnly(cfg))
+	{
+		debug.GET("/state", healthHandler.DebugState)
+	}
+
 	api := e.Group("/api")
 	{
 		authGroup := api.Group("/auth")
 		{
 			authGroup.POST("/register", authHandler.Register)
 			authGroup.POST("/login", authHandler.Login)
+			authGroup.POST("/logout", authHandler.Logout)
 		}
 
 		v1 := api.Group("/v1")
-		v1.Use(middleware.JWTAuthMiddleware(cfg.JWTSecret))
+		v1.Use(middleware.JWTAuthMiddleware(cfg.JWTSecret, cookies, tokenAuth))
 		{
 			v1.GET("/me", authHandler.GetMe)
+			v1.DELETE("/me", accountHandler.DeleteMe)
+			v1.GET("/me/export", accountHandler.ExportMe)
+			v1.POST("/auth/refresh", authHandler.Refresh)
+
+			v1.GET("/me/profile", profileHandler.GetMyProfile)
+			v1.PATCH("/me/profile", profileHandler.UpdateMyProfile)
+			v1.PUT("/me/avatar", profileHandler.UploadMyAvatar)
+			v1.DELETE("/me/avatar", profileHandler.DeleteMyAvatar)
 
 			v1.GET("/ice", iceHandler.IceServers)
 
@@ -49,11 +86,29 @@ func New(
 			v1.GET("/channels", channelHandler.ListChannelsHandler)
 			v1.POST("/channels", channelHandler.CreateChannelHandler)
 			v1.DELETE("/channels/:id", channelHandler.DeleteChannelHandler)
+			v1.GET("/channels/:id/stats", qualityHandler.ChannelStats)
+			v1.PUT("/channels/:id/audio", channelHandler.UpdateAudioProfileHandler)
+			v1.PUT("/channels/:id/floor", channelHandler.UpdateFloorControlHandler)
+			v1.PUT("/channels/:id/admission", channelHandler.UpdateAdmissionHandler)
+			v1.PUT("/channels/:id/password", channelHandler.UpdatePasswordHandler)
+			v1.PUT("/channels/:id/members/:user_id/priority", channelHandler.SetPrioritySpeakerHandler)
 
 			v1.GET("/users/online", authHandler.GetOnlineUsers)
+			v1.GET("/users/:id", profileHandler.GetUserProfile)
+
+			v1.GET("/bots", tokenHandler.ListBots)
+			v1.POST("/bots", tokenHandler.CreateBot)
+
+			v1.GET("/tokens", tokenHandler.ListTokens)
+			v1.POST("/tokens", tokenHandler.CreateToken)
+			v1.DELETE("/tokens/:id", tokenHandler.RevokeToken)
 		}
 	}
 
+	if cfg.Storage.Backend == config.StorageBackendLocal {
+		e.Static(storage.LocalPublicPath, cfg.Storage.LocalDir)
+	}
+
 	e.Static("/", "web")
 
 	return e
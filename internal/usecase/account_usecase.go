@@ -0,0 +1,260 @@
+package usecase
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/qrave1/RoomSpeak/internal/application/constant"
+	"github.com/qrave1/RoomSpeak/internal/domain/models"
+	"github.com/qrave1/RoomSpeak/internal/infra/adapters/memory"
+	"github.com/qrave1/RoomSpeak/internal/infra/adapters/postgres/repository"
+	"github.com/qrave1/RoomSpeak/internal/infra/adapters/storage"
+)
+
+var ErrInvalidPassword = errors.New("invalid password")
+
+// AccountUsecase - удаление аккаунта и выгрузка персональных данных (GDPR)
+type AccountUsecase interface {
+	// DeleteAccount удаляет пользователя вместе с его ботами.
+	// Каналы пользователя передаются самому давнему участнику, а если участников нет - удаляются
+	DeleteAccount(ctx context.Context, userID uuid.UUID, password string) error
+
+	// Export пишет в w ZIP архив со всеми данными, хранящимися о пользователе
+	Export(ctx context.Context, userID uuid.UUID, w io.Writer) error
+}
+
+type accountUsecase struct {
+	userRepo    repository.UserRepository
+	channelRepo repository.ChannelRepository
+	tokenRepo   repository.APITokenRepository
+	transactor  repository.Transactor
+
+	wsRepo         memory.WebsocketConnectionRepository
+	activeUserRepo memory.ActiveUserRepository
+
+	avatarStorage storage.AvatarStorage
+
+	signalingUsecase SignalingUsecase
+}
+
+func NewAccountUsecase(
+	userRepo repository.UserRepository,
+	channelRepo repository.ChannelRepository,
+	tokenRepo repository.APITokenRepository,
+	transactor repository.Transactor,
+	wsRepo memory.WebsocketConnectionRepository,
+	activeUserRepo memory.ActiveUserRepository,
+	avatarStorage storage.AvatarStorage,
+	signalingUsecase SignalingUsecase,
+) AccountUsecase {
+	return &accountUsecase{
+		userRepo:         userRepo,
+		channelRepo:      channelRepo,
+		tokenRepo:        tokenRepo,
+		transactor:       transactor,
+		wsRepo:           wsRepo,
+		activeUserRepo:   activeUserRepo,
+		avatarStorage:    avatarStorage,
+		signalingUsecase: signalingUsecase,
+	}
+}
+
+func (uc *accountUsecase) DeleteAccount(ctx context.Context, userID uuid.UUID, password string) error {
+	user, err := uc.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get user: %w", err)
+	}
+
+	// Бот удаляется по токену владельца или своему, у него нет пароля
+	if !user.IsBot {
+		if err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+			return ErrInvalidPassword
+		}
+	}
+
+	bots, err := uc.userRepo.GetBotsByOwner(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get bots: %w", err)
+	}
+
+	// Сначала боты: их каналы тоже держат внешний ключ creator_id
+	users := append(bots, user)
+
+	// Данные удаляются одной транзакцией, чтобы сбой не оставил аккаунт с частью переданных каналов
+	err = uc.transactor.InTx(ctx, func(ctx context.Context) error {
+		for _, u := range users {
+			if err := uc.deleteUserData(ctx, u); err != nil {
+				return fmt.Errorf("delete user %s: %w", u.ID, err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, u := range users {
+		uc.closeUserSessions(ctx, u)
+	}
+
+	return nil
+}
+
+// deleteUserData передает или удаляет каналы пользователя, отзывает его токены и удаляет его самого
+func (uc *accountUsecase) deleteUserData(ctx context.Context, user *models.User) error {
+	channels, err := uc.channelRepo.GetByCreator(ctx, user.ID)
+	if err != nil {
+		return fmt.Errorf("get created channels: %w", err)
+	}
+
+	// channels.creator_id без каскада, поэтому каналы нужно передать или удалить до удаления пользователя
+	for _, channel := range channels {
+		if err = uc.releaseChannel(ctx, channel, user.ID); err != nil {
+			return fmt.Errorf("release channel %s: %w", channel.ID, err)
+		}
+	}
+
+	// Токен, проверенный до удаления, не должен пережить пользователя
+	if err = uc.tokenRepo.RevokeAllByUser(ctx, user.ID); err != nil {
+		return fmt.Errorf("revoke api tokens: %w", err)
+	}
+
+	return uc.userRepo.DeleteUser(ctx, user.ID)
+}
+
+// closeUserSessions выводит удаленного пользователя из звонка и закрывает его WebSocket
+func (uc *accountUsecase) closeUserSessions(ctx context.Context, user *models.User) {
+	if _, inCall := uc.activeUserRepo.GetByID(ctx, user.ID); inCall {
+		if err := uc.signalingUsecase.HandleLeave(ctx, user.ID); err != nil {
+			slog.Error("leave channel after account deletion", slog.Any(constant.Error, err), slog.Any(constant.UserID, user.ID))
+		}
+	}
+
+	if user.AvatarKey != "" {
+		if err := uc.avatarStorage.Delete(ctx, user.AvatarKey); err != nil {
+			slog.Error("delete avatar of deleted user", slog.Any(constant.Error, err), slog.Any(constant.UserID, user.ID))
+		}
+	}
+
+	uc.wsRepo.Write(user.ID, map[string]any{"type": "account_deleted"})
+	uc.wsRepo.Close(user.ID, "account deleted")
+}
+
+func (uc *accountUsecase) releaseChannel(ctx context.Context, channel *models.Channel, userID uuid.UUID) error {
+	memberIDs, err := uc.channelRepo.GetMemberIDs(ctx, channel.ID)
+	if err != nil {
+		return fmt.Errorf("get members: %w", err)
+	}
+
+	for _, memberID := range memberIDs {
+		if memberID == userID {
+			continue
+		}
+
+		return uc.channelRepo.TransferOwnership(ctx, channel.ID, memberID)
+	}
+
+	return uc.channelRepo.Delete(ctx, channel.ID)
+}
+
+func (uc *accountUsecase) Export(ctx context.Context, userID uuid.UUID, w io.Writer) error {
+	user, err := uc.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get user: %w", err)
+	}
+
+	createdChannels, err := uc.channelRepo.GetByCreator(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get created channels: %w", err)
+	}
+
+	memberChannels, err := uc.channelRepo.GetMemberChannels(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get member channels: %w", err)
+	}
+
+	tokens, err := uc.tokenRepo.ListByUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get api tokens: %w", err)
+	}
+
+	bots, err := uc.userRepo.GetBotsByOwner(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get bots: %w", err)
+	}
+
+	zw := zip.NewWriter(w)
+
+	files := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", map[string]any{"user": user, "avatar_url": uc.avatarStorage.URL(user.AvatarKey)}},
+		{"channels_created.json", createdChannels},
+		{"channel_memberships.json", memberChannels},
+		{"api_tokens.json", tokens},
+		{"bots.json", bots},
+	}
+
+	for _, file := range files {
+		if err = writeZipJSON(zw, file.name, file.data); err != nil {
+			return fmt.Errorf("write %s: %w", file.name, err)
+		}
+	}
+
+	if user.AvatarKey != "" {
+		if err = uc.writeAvatar(ctx, zw, user.AvatarKey); err != nil {
+			return fmt.Errorf("write avatar: %w", err)
+		}
+	}
+
+	return zw.Close()
+}
+
+func (uc *accountUsecase) writeAvatar(ctx context.Context, zw *zip.Writer, key string) error {
+	avatar, err := uc.avatarStorage.Open(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer avatar.Close()
+
+	fw, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     "avatar" + path.Ext(key),
+		Method:   zip.Store,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(fw, avatar)
+
+	return err
+}
+
+func writeZipJSON(zw *zip.Writer, name string, data any) error {
+	fw, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(fw)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(data)
+}
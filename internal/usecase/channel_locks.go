@@ -0,0 +1,26 @@
+package usecase
+
+import (
+	"hash/maphash"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+const channelLockStripes = 64
+
+var channelLockSeed = maphash.MakeSeed()
+
+// channelLocks - мьютексы по каналу. Каналы делят фиксированный набор мьютексов,
+// поэтому память не растет с числом каналов, а редкие совпадения только сериализуют их
+type channelLocks struct {
+	stripes [channelLockStripes]sync.Mutex
+}
+
+// lock блокирует канал и возвращает функцию разблокировки
+func (l *channelLocks) lock(channelID uuid.UUID) func() {
+	mu := &l.stripes[maphash.Bytes(channelLockSeed, channelID[:])%channelLockStripes]
+	mu.Lock()
+
+	return mu.Unlock
+}
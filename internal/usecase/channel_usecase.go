@@ -2,25 +2,54 @@ package usecase
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"github.com/qrave1/RoomSpeak/internal/domain/runtime"
 	"github.com/qrave1/RoomSpeak/internal/infra/adapters/memory"
 
 	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
 
 	"github.com/qrave1/RoomSpeak/internal/domain/input"
 	"github.com/qrave1/RoomSpeak/internal/domain/models"
 	"github.com/qrave1/RoomSpeak/internal/infra/adapters/postgres/repository"
 )
 
+var (
+	ErrInvalidAudioProfile    = errors.New("invalid audio profile")
+	ErrInvalidFloorControl    = errors.New("invalid floor control")
+	ErrInvalidAdmission       = errors.New("invalid admission settings")
+	ErrNotChannelMember       = errors.New("user is not a channel member")
+	ErrInvalidChannelPassword = errors.New("invalid channel password")
+	ErrChannelNotPublic       = errors.New("channel is not public")
+)
+
+// Пароль канала. bcrypt учитывает только первые 72 байта, длиннее не принимаем
+const (
+	minChannelPasswordLen = 4
+	maxChannelPasswordLen = 72
+)
+
 type ChannelUsecase interface {
 	CreateChannel(ctx context.Context, input *input.CreateChannelInput) (*models.Channel, error)
 	GetChannel(ctx context.Context, id uuid.UUID) (*models.Channel, error)
 	UpdateChannel(ctx context.Context, update *input.UpdateChannelInput) (*models.Channel, error)
+	// UpdateAudioProfile меняет звук канала, применяется к участникам при следующем входе
+	UpdateAudioProfile(ctx context.Context, channelID uuid.UUID, profile models.AudioProfile) (*models.Channel, error)
+	// UpdateFloorControl меняет настройки ptt канала, применяются со следующего ptt_start
+	UpdateFloorControl(ctx context.Context, channelID uuid.UUID, floor models.FloorControl) (*models.Channel, error)
+	// UpdateAdmission меняет предел участников и зал ожидания, применяется к следующим входам
+	UpdateAdmission(ctx context.Context, channelID uuid.UUID, admission models.Admission) (*models.Channel, error)
+	// UpdatePassword ставит или меняет пароль публичного канала, пустой пароль снимает защиту.
+	// Уже вошедших участников смена пароля не затрагивает. Для приватного канала - ErrChannelNotPublic
+	UpdatePassword(ctx context.Context, channelID uuid.UUID, password string) error
 	DeleteChannel(ctx context.Context, id uuid.UUID) error
 
 	AddUserToChannel(ctx context.Context, userID, channelID uuid.UUID) error
 	RemoveUserFromChannel(ctx context.Context, userID, channelID uuid.UUID) error
+	// SetPrioritySpeaker разрешает участнику перебивать говорящих в ptt канале
+	SetPrioritySpeaker(ctx context.Context, userID, channelID uuid.UUID, priority bool) error
 	GetAvailableChannelsForUser(ctx context.Context, userID uuid.UUID) ([]*models.Channel, error)
 
 	GetActiveUsersByID(ctx context.Context, channelID uuid.UUID) ([]runtime.ActiveUser, error)
@@ -46,7 +75,17 @@ func (uc *channelUsecase) CreateChannel(ctx context.Context, input *input.Create
 }
 
 func (uc *channelUsecase) GetChannel(ctx context.Context, id uuid.UUID) (*models.Channel, error) {
-	return uc.channelRepo.GetByID(ctx, id)
+	channel, err := uc.channelRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Комнатами breakout управляет только сессия breakout, через REST их не видно
+	if channel.IsBreakout() {
+		return nil, sql.ErrNoRows
+	}
+
+	return channel, nil
 }
 
 func (uc *channelUsecase) UpdateChannel(ctx context.Context, update *input.UpdateChannelInput) (*models.Channel, error) {
@@ -67,6 +106,101 @@ func (uc *channelUsecase) UpdateChannel(ctx context.Context, update *input.Updat
 	return channel, nil
 }
 
+func (uc *channelUsecase) UpdateAudioProfile(ctx context.Context, channelID uuid.UUID, profile models.AudioProfile) (*models.Channel, error) {
+	if err := profile.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidAudioProfile, err)
+	}
+
+	channel, err := uc.channelRepo.GetByID(ctx, channelID)
+	if err != nil {
+		return nil, fmt.Errorf("get channel by id: %w", err)
+	}
+
+	if err = uc.channelRepo.UpdateAudioProfile(ctx, channelID, profile); err != nil {
+		return nil, fmt.Errorf("update audio profile: %w", err)
+	}
+
+	channel.AudioProfile = profile
+
+	return channel, nil
+}
+
+func (uc *channelUsecase) UpdateFloorControl(ctx context.Context, channelID uuid.UUID, floor models.FloorControl) (*models.Channel, error) {
+	if err := floor.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidFloorControl, err)
+	}
+
+	channel, err := uc.channelRepo.GetByID(ctx, channelID)
+	if err != nil {
+		return nil, fmt.Errorf("get channel by id: %w", err)
+	}
+
+	if err = uc.channelRepo.UpdateFloorControl(ctx, channelID, floor); err != nil {
+		return nil, fmt.Errorf("update floor control: %w", err)
+	}
+
+	channel.FloorControl = floor
+
+	return channel, nil
+}
+
+func (uc *channelUsecase) UpdateAdmission(ctx context.Context, channelID uuid.UUID, admission models.Admission) (*models.Channel, error) {
+	if err := admission.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidAdmission, err)
+	}
+
+	channel, err := uc.channelRepo.GetByID(ctx, channelID)
+	if err != nil {
+		return nil, fmt.Errorf("get channel by id: %w", err)
+	}
+
+	if err = uc.channelRepo.UpdateAdmission(ctx, channelID, admission); err != nil {
+		return nil, fmt.Errorf("update admission: %w", err)
+	}
+
+	channel.Admission = admission
+
+	return channel, nil
+}
+
+func (uc *channelUsecase) UpdatePassword(ctx context.Context, channelID uuid.UUID, password string) error {
+	channel, err := uc.channelRepo.GetByID(ctx, channelID)
+	if err != nil {
+		return fmt.Errorf("get channel by id: %w", err)
+	}
+
+	// В приватный канал пускают только участников, пароль там ничего не значит
+	if !channel.IsPublic {
+		return ErrChannelNotPublic
+	}
+
+	var passwordHash string
+
+	if password != "" {
+		if len(password) < minChannelPasswordLen || len(password) > maxChannelPasswordLen {
+			return fmt.Errorf(
+				"%w: length must be between %d and %d bytes",
+				ErrInvalidChannelPassword,
+				minChannelPasswordLen,
+				maxChannelPasswordLen,
+			)
+		}
+
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return fmt.Errorf("hash password: %w", err)
+		}
+
+		passwordHash = string(hashedPassword)
+	}
+
+	if err = uc.channelRepo.UpdatePassword(ctx, channelID, passwordHash); err != nil {
+		return fmt.Errorf("update password: %w", err)
+	}
+
+	return nil
+}
+
 func (uc *channelUsecase) DeleteChannel(ctx context.Context, id uuid.UUID) error {
 	return uc.channelRepo.Delete(ctx, id)
 }
@@ -79,6 +213,15 @@ func (uc *channelUsecase) RemoveUserFromChannel(ctx context.Context, userID, cha
 	return uc.channelRepo.RemoveUserFromChannel(ctx, userID, channelID)
 }
 
+func (uc *channelUsecase) SetPrioritySpeaker(ctx context.Context, userID, channelID uuid.UUID, priority bool) error {
+	err := uc.channelRepo.SetPrioritySpeaker(ctx, userID, channelID, priority)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotChannelMember
+	}
+
+	return err
+}
+
 func (uc *channelUsecase) GetAvailableChannelsForUser(ctx context.Context, userID uuid.UUID) ([]*models.Channel, error) {
 	return uc.channelRepo.GetAvailableChannelsForUser(ctx, userID)
 }
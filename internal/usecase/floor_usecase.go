@@ -0,0 +1,370 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/qrave1/RoomSpeak/internal/application/constant"
+	"github.com/qrave1/RoomSpeak/internal/domain"
+	"github.com/qrave1/RoomSpeak/internal/domain/events"
+	"github.com/qrave1/RoomSpeak/internal/domain/models"
+	"github.com/qrave1/RoomSpeak/internal/infra/adapters/memory"
+	postrepo "github.com/qrave1/RoomSpeak/internal/infra/adapters/postgres/repository"
+)
+
+// Причины, по которым у говорящего забрали слово
+const (
+	FloorRevokeTimeout   = "timeout"
+	FloorRevokePreempted = "preempted"
+)
+
+// FloorUsecase раздает слово в ptt каналах. Звук пересылается только от держателей слова,
+// остальные ждут в очереди. Приоритетные говорящие (модератор и отмеченные участники)
+// встают в начало очереди и перебивают обычных, если мест нет.
+// Состояние хранится на ноде, которая обслуживает медиа канала
+type FloorUsecase interface {
+	// RequestFloor - ptt_start: дает слово или ставит в очередь
+	RequestFloor(ctx context.Context, userID uuid.UUID) error
+	// ReleaseFloor - ptt_stop: отдает слово следующему или убирает из очереди
+	ReleaseFloor(ctx context.Context, userID uuid.UUID) error
+
+	// SendState отправляет состояние слова только что вошедшему участнику
+	SendState(channelID, userID uuid.UUID)
+	// Forget освобождает слово и место в очереди ушедшего участника
+	Forget(peer *domain.Peer)
+}
+
+type floorHolder struct {
+	priority  bool
+	grantedAt time.Time
+	expiresAt time.Time
+	timer     *time.Timer
+}
+
+type floorRequest struct {
+	userID   uuid.UUID
+	priority bool
+}
+
+type channelFloor struct {
+	settings models.FloorControl
+	holders  map[uuid.UUID]*floorHolder
+	queue    []floorRequest
+}
+
+// floorUpdate - кому выдано и у кого забрано слово за одно изменение, рассылается после разблокировки
+type floorUpdate struct {
+	granted map[uuid.UUID]time.Time
+	revoked map[uuid.UUID]string
+}
+
+type floorUsecase struct {
+	channelRepo postrepo.ChannelRepository
+
+	pcRepo memory.PeerConnectionRepository
+	wsRepo memory.WebsocketConnectionRepository
+
+	mu     sync.Mutex
+	floors map[uuid.UUID]*channelFloor
+}
+
+func NewFloorUsecase(
+	channelRepo postrepo.ChannelRepository,
+	pcRepo memory.PeerConnectionRepository,
+	wsRepo memory.WebsocketConnectionRepository,
+) FloorUsecase {
+	return &floorUsecase{
+		channelRepo: channelRepo,
+		pcRepo:      pcRepo,
+		wsRepo:      wsRepo,
+		floors:      make(map[uuid.UUID]*channelFloor),
+	}
+}
+
+func (uc *floorUsecase) RequestFloor(ctx context.Context, userID uuid.UUID) error {
+	peer, ok := uc.pcRepo.Get(userID)
+	if !ok {
+		return fmt.Errorf("peer connection not found")
+	}
+
+	channel, err := uc.channelRepo.GetByID(ctx, peer.Room())
+	if err != nil {
+		return fmt.Errorf("get channel: %w", err)
+	}
+
+	if channel.Type != models.ChannelTypePTT {
+		uc.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "channel is not push-to-talk"})
+		return nil
+	}
+
+	priority := channel.IsModerator(userID)
+	if !priority {
+		if priority, err = uc.channelRepo.IsPrioritySpeaker(ctx, userID, channel.ID); err != nil {
+			return fmt.Errorf("check priority speaker: %w", err)
+		}
+	}
+
+	update := newFloorUpdate()
+
+	uc.mu.Lock()
+
+	floor, ok := uc.floors[channel.ID]
+	if !ok {
+		floor = &channelFloor{holders: make(map[uuid.UUID]*floorHolder)}
+		uc.floors[channel.ID] = floor
+	}
+
+	// Новые настройки канала действуют с ближайшей выдачи слова
+	floor.settings = channel.FloorControl
+
+	uc.request(floor, channel.ID, floorRequest{userID: userID, priority: priority}, update)
+	state := floorState(channel.ID, floor)
+
+	uc.mu.Unlock()
+
+	uc.publish(channel.ID, update, state)
+
+	return nil
+}
+
+func (uc *floorUsecase) ReleaseFloor(ctx context.Context, userID uuid.UUID) error {
+	peer, ok := uc.pcRepo.Get(userID)
+	if !ok {
+		return fmt.Errorf("peer connection not found")
+	}
+
+	uc.release(peer.Room(), userID, "", nil)
+
+	return nil
+}
+
+func (uc *floorUsecase) SendState(channelID, userID uuid.UUID) {
+	uc.mu.Lock()
+
+	state := events.FloorStateEvent{ChannelID: channelID.String(), Holders: []events.FloorHolderInfo{}, Queue: []events.FloorQueueEntry{}}
+	if floor, ok := uc.floors[channelID]; ok {
+		state = floorState(channelID, floor)
+	}
+
+	uc.mu.Unlock()
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		slog.Error("marshal floor state", slog.Any(constant.Error, err))
+		return
+	}
+
+	uc.wsRepo.Write(userID, events.Message{Type: "floor_state", Data: data})
+}
+
+func (uc *floorUsecase) Forget(peer *domain.Peer) {
+	uc.release(peer.Room(), peer.UserID, "", nil)
+}
+
+// release забирает слово или место в очереди. holder задан, когда слово истекло по таймеру:
+// если пользователь успел получить слово заново, старый таймер ничего не делает
+func (uc *floorUsecase) release(channelID, userID uuid.UUID, reason string, holder *floorHolder) {
+	update := newFloorUpdate()
+
+	uc.mu.Lock()
+
+	floor, ok := uc.floors[channelID]
+	if !ok {
+		uc.mu.Unlock()
+		return
+	}
+
+	current, held := floor.holders[userID]
+	if holder != nil && current != holder {
+		uc.mu.Unlock()
+		return
+	}
+
+	if held {
+		uc.revoke(floor, userID, reason, update)
+		uc.promote(floor, channelID, update)
+	} else {
+		floor.queue = slices.DeleteFunc(floor.queue, func(req floorRequest) bool { return req.userID == userID })
+	}
+
+	state := floorState(channelID, floor)
+
+	if len(floor.holders) == 0 && len(floor.queue) == 0 {
+		delete(uc.floors, channelID)
+	}
+
+	uc.mu.Unlock()
+
+	uc.publish(channelID, update, state)
+}
+
+func (uc *floorUsecase) request(floor *channelFloor, channelID uuid.UUID, req floorRequest, update *floorUpdate) {
+	if _, held := floor.holders[req.userID]; held {
+		return
+	}
+
+	for _, queued := range floor.queue {
+		if queued.userID == req.userID {
+			return
+		}
+	}
+
+	if len(floor.holders) < floor.settings.MaxHolders {
+		uc.grant(floor, channelID, req, update)
+		return
+	}
+
+	// Приоритетный говорящий забирает слово у того обычного, кто говорит дольше всех
+	if req.priority {
+		var (
+			victimID uuid.UUID
+			victim   *floorHolder
+		)
+
+		for holderID, holder := range floor.holders {
+			if !holder.priority && (victim == nil || holder.grantedAt.Before(victim.grantedAt)) {
+				victimID, victim = holderID, holder
+			}
+		}
+
+		if victim != nil {
+			uc.revoke(floor, victimID, FloorRevokePreempted, update)
+			uc.grant(floor, channelID, req, update)
+
+			return
+		}
+	}
+
+	// Приоритетные встают после приоритетных, обычные - в конец
+	position := len(floor.queue)
+	if req.priority {
+		position = 0
+		for position < len(floor.queue) && floor.queue[position].priority {
+			position++
+		}
+	}
+
+	floor.queue = slices.Insert(floor.queue, position, req)
+}
+
+func (uc *floorUsecase) grant(floor *channelFloor, channelID uuid.UUID, req floorRequest, update *floorUpdate) {
+	now := time.Now()
+	holder := &floorHolder{
+		priority:  req.priority,
+		grantedAt: now,
+		expiresAt: now.Add(floor.settings.MaxTalkTime()),
+	}
+
+	holder.timer = time.AfterFunc(floor.settings.MaxTalkTime(), func() {
+		uc.release(channelID, req.userID, FloorRevokeTimeout, holder)
+	})
+
+	floor.holders[req.userID] = holder
+	uc.setListenOnly(req.userID, false)
+
+	update.granted[req.userID] = holder.expiresAt
+}
+
+func (uc *floorUsecase) revoke(floor *channelFloor, userID uuid.UUID, reason string, update *floorUpdate) {
+	floor.holders[userID].timer.Stop()
+	delete(floor.holders, userID)
+	uc.setListenOnly(userID, true)
+
+	// Пустая причина - пользователь отпустил кнопку сам, уведомление ему не нужно
+	if reason != "" {
+		update.revoked[userID] = reason
+	}
+}
+
+// promote отдает освободившиеся места первым в очереди
+func (uc *floorUsecase) promote(floor *channelFloor, channelID uuid.UUID, update *floorUpdate) {
+	for len(floor.holders) < floor.settings.MaxHolders && len(floor.queue) > 0 {
+		next := floor.queue[0]
+		floor.queue = floor.queue[1:]
+
+		uc.grant(floor, channelID, next, update)
+	}
+}
+
+func (uc *floorUsecase) setListenOnly(userID uuid.UUID, listenOnly bool) {
+	if peer, ok := uc.pcRepo.Get(userID); ok {
+		peer.ListenOnly.Store(listenOnly)
+	}
+}
+
+// publish уведомляет получивших и потерявших слово и рассылает каналу новое состояние
+func (uc *floorUsecase) publish(channelID uuid.UUID, update *floorUpdate, state events.FloorStateEvent) {
+	for userID, expiresAt := range update.granted {
+		data, err := json.Marshal(events.FloorGrantedEvent{ChannelID: channelID.String(), ExpiresAt: expiresAt})
+		if err != nil {
+			slog.Error("marshal floor granted", slog.Any(constant.Error, err))
+			continue
+		}
+
+		uc.wsRepo.Write(userID, events.Message{Type: "floor_granted", Data: data})
+	}
+
+	for userID, reason := range update.revoked {
+		data, err := json.Marshal(events.FloorRevokedEvent{ChannelID: channelID.String(), Reason: reason})
+		if err != nil {
+			slog.Error("marshal floor revoked", slog.Any(constant.Error, err))
+			continue
+		}
+
+		uc.wsRepo.Write(userID, events.Message{Type: "floor_revoked", Data: data})
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		slog.Error("marshal floor state", slog.Any(constant.Error, err))
+		return
+	}
+
+	for _, peer := range uc.pcRepo.GetInRoom(channelID) {
+		uc.wsRepo.Write(peer.UserID, events.Message{Type: "floor_state", Data: data})
+	}
+}
+
+func newFloorUpdate() *floorUpdate {
+	return &floorUpdate{
+		granted: make(map[uuid.UUID]time.Time),
+		revoked: make(map[uuid.UUID]string),
+	}
+}
+
+func floorState(channelID uuid.UUID, floor *channelFloor) events.FloorStateEvent {
+	state := events.FloorStateEvent{
+		ChannelID: channelID.String(),
+		Holders:   make([]events.FloorHolderInfo, 0, len(floor.holders)),
+		Queue:     make([]events.FloorQueueEntry, 0, len(floor.queue)),
+	}
+
+	for userID, holder := range floor.holders {
+		state.Holders = append(state.Holders, events.FloorHolderInfo{
+			UserID:    userID.String(),
+			Priority:  holder.priority,
+			GrantedAt: holder.grantedAt,
+			ExpiresAt: holder.expiresAt,
+		})
+	}
+
+	slices.SortFunc(state.Holders, func(a, b events.FloorHolderInfo) int {
+		return a.GrantedAt.Compare(b.GrantedAt)
+	})
+
+	for _, req := range floor.queue {
+		state.Queue = append(state.Queue, events.FloorQueueEntry{
+			UserID:   req.userID.String(),
+			Priority: req.priority,
+		})
+	}
+
+	return state
+}
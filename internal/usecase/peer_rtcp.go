@@ -0,0 +1,69 @@
+package usecase
+
+import (
+	"github.com/pion/rtcp"
+	"github.com/pion/webrtc/v4"
+
+	"github.com/qrave1/RoomSpeak/internal/application/metrics"
+	"github.com/qrave1/RoomSpeak/internal/domain"
+)
+
+// watchBandwidth пересчитывает слои симулкаста получателя, когда оценка полосы до него меняется
+func (p *peerUsecase) watchBandwidth(peer *domain.Peer) {
+	if peer.Bandwidth == nil {
+		return
+	}
+
+	peer.Bandwidth.OnTargetBitrateChange(func(bitrate int) {
+		p.onBandwidthEstimate(peer, bitrate)
+	})
+}
+
+func (p *peerUsecase) onBandwidthEstimate(peer *domain.Peer, bitrate int) {
+	if peer.SetEstimatedBandwidth(bitrate / 1000) {
+		p.UpdateLayers(peer)
+	}
+}
+
+// readSenderRTCP читает RTCP от получателя по одному отправляемому потоку. Без чтения
+// интерсепторы не видят NACK и TWCC, и потерянные пакеты не повторяются.
+// onKeyframeRequest вызывается на PLI/FIR, может быть nil
+func (p *peerUsecase) readSenderRTCP(peer *domain.Peer, sender *webrtc.RTPSender, onKeyframeRequest func()) {
+	for {
+		packets, _, err := sender.ReadRTCP()
+		if err != nil {
+			return
+		}
+
+		for _, pkt := range packets {
+			metrics.RTCPPacketsReceived.WithLabelValues(rtcpPacketType(pkt)).Inc()
+
+			switch pkt := pkt.(type) {
+			case *rtcp.PictureLossIndication, *rtcp.FullIntraRequest:
+				if onKeyframeRequest != nil {
+					onKeyframeRequest()
+				}
+			case *rtcp.ReceiverEstimatedMaximumBitrate:
+				// Клиенты без transport-cc сообщают полосу через REMB
+				p.onBandwidthEstimate(peer, int(pkt.Bitrate))
+			}
+		}
+	}
+}
+
+func rtcpPacketType(pkt rtcp.Packet) string {
+	switch pkt.(type) {
+	case *rtcp.TransportLayerNack:
+		return metrics.RTCPTypeNACK
+	case *rtcp.PictureLossIndication, *rtcp.FullIntraRequest:
+		return metrics.RTCPTypeKeyframeRequest
+	case *rtcp.TransportLayerCC:
+		return metrics.RTCPTypeTWCC
+	case *rtcp.ReceiverEstimatedMaximumBitrate:
+		return metrics.RTCPTypeREMB
+	case *rtcp.ReceiverReport:
+		return metrics.RTCPTypeReceiverReport
+	default:
+		return metrics.RTCPTypeOther
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"io"
 	"log/slog"
+	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/pion/rtp"
@@ -13,44 +15,97 @@ import (
 
 	"github.com/qrave1/RoomSpeak/internal/application/config"
 	"github.com/qrave1/RoomSpeak/internal/application/constant"
+	"github.com/qrave1/RoomSpeak/internal/application/metrics"
 	"github.com/qrave1/RoomSpeak/internal/domain"
+	"github.com/qrave1/RoomSpeak/internal/domain/models"
 	"github.com/qrave1/RoomSpeak/internal/infra/adapters/memory"
+	"github.com/qrave1/RoomSpeak/internal/infra/adapters/relay"
+	"github.com/qrave1/RoomSpeak/internal/infra/adapters/turn"
 )
 
+const serverCredentialTTL = 24 * time.Hour
+
 type PeerUsecase interface {
-	CreateWebrtcPeer(ctx context.Context, userID uuid.UUID, channelID uuid.UUID) (*domain.Peer, error)
+	CreateWebrtcPeer(ctx context.Context, userID uuid.UUID, channelID uuid.UUID, profile models.AudioProfile) (*domain.Peer, error)
+
+	// SubscribeExisting отправляет участнику видео, опубликованное до его подключения
+	SubscribeExisting(subscriber *domain.Peer)
+	// SetTrackSource сохраняет метаданные видео трека от клиента
+	SetTrackSource(publisher *domain.Peer, trackID string, source domain.TrackSource)
+	// UpdateLayers пересчитывает слои симулкаста после новых ограничений получателя
+	UpdateLayers(subscriber *domain.Peer)
+	// MoveToRoom переводит звук и видео участника в другую комнату без переподключения
+	MoveToRoom(peer *domain.Peer, roomID uuid.UUID)
 }
 
 type peerUsecase struct {
 	cfg *config.Config
+	api domain.PeerConnectionFactory
 
 	pcRepo         memory.PeerConnectionRepository
 	wsRepo         memory.WebsocketConnectionRepository
 	activeUserRepo memory.ActiveUserRepository
+	relayRepo      memory.ChannelRelayRepository
+
+	relayTransport relay.Transport
 }
 
 func NewPeerUsecase(
 	cfg *config.Config,
+	api domain.PeerConnectionFactory,
 	pcRepo memory.PeerConnectionRepository,
 	wsRepo memory.WebsocketConnectionRepository,
 	activeUserRepo memory.ActiveUserRepository,
+	relayRepo memory.ChannelRelayRepository,
+	relayTransport relay.Transport,
 ) *peerUsecase {
-	return &peerUsecase{
+	p := &peerUsecase{
 		cfg:            cfg,
+		api:            api,
 		pcRepo:         pcRepo,
 		wsRepo:         wsRepo,
 		activeUserRepo: activeUserRepo,
+		relayRepo:      relayRepo,
+		relayTransport: relayTransport,
 	}
+
+	// Пакеты с других нод раздаются только локальным пирам, дальше не пересылаются.
+	// Между нодами ходит только Opus
+	relayTransport.OnPacket(func(channelID, senderID uuid.UUID, pkt *rtp.Packet) {
+		p.forwardLocal(pkt, webrtc.MimeTypeOpus, senderID, channelID)
+	})
+
+	return p
 }
 
-func (p *peerUsecase) CreateWebrtcPeer(ctx context.Context, userID uuid.UUID, channelID uuid.UUID) (*domain.Peer, error) {
-	peer, err := domain.NewPeer(userID, channelID, p.cfg)
+func (p *peerUsecase) CreateWebrtcPeer(
+	ctx context.Context,
+	userID uuid.UUID,
+	channelID uuid.UUID,
+	profile models.AudioProfile,
+) (*domain.Peer, error) {
+	// Креды сервера живут дольше клиентских: TURN аллокация продлевается весь звонок
+	username, credential := turn.Credentials(
+		p.cfg.TURNSecret(),
+		"node-"+p.cfg.Cluster.NodeID,
+		time.Now().Add(serverCredentialTTL),
+	)
+
+	peer, err := domain.NewPeer(userID, channelID, p.api, p.cfg.ICE.ICEServers(username, credential), profile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create peer: %w", err)
 	}
 
 	peer.Conn.OnTrack(func(track *webrtc.TrackRemote, receiver *webrtc.RTPReceiver) {
-		go func(ctx context.Context, userID uuid.UUID, channelID uuid.UUID) {
+		if track.Kind() == webrtc.RTPCodecTypeVideo {
+			go p.publishVideo(peer, track)
+			return
+		}
+
+		peer.InboundAudioSSRC.Store(uint32(track.SSRC()))
+		mimeType := track.Codec().MimeType
+
+		go func(ctx context.Context) {
 			for {
 				select {
 				case <-ctx.Done():
@@ -66,11 +121,25 @@ func (p *peerUsecase) CreateWebrtcPeer(ctx context.Context, userID uuid.UUID, ch
 					}
 
 					if track.Kind() == webrtc.RTPCodecTypeAudio {
-						p.broadcastRTP(ctx, pkt, userID, channelID)
+						p.broadcastRTP(ctx, pkt, mimeType, peer)
 					}
 				}
 			}
-		}(ctx, userID, channelID)
+		}(ctx)
+	})
+
+	go p.readSenderRTCP(peer, peer.AudioSender, nil)
+	p.watchBandwidth(peer)
+
+	// Сервер сам предлагает новый offer, когда у получателя добавились или пропали видео треки
+	peer.Conn.OnNegotiationNeeded(func() {
+		if peer.Negotiated.Load() {
+			p.renegotiate(peer)
+		}
+	})
+
+	peer.Conn.OnICEConnectionStateChange(func(state webrtc.ICEConnectionState) {
+		metrics.ICEConnectionStates.WithLabelValues(state.String()).Inc()
 	})
 
 	peer.Conn.OnICECandidate(func(c *webrtc.ICECandidate) {
@@ -84,29 +153,99 @@ func (p *peerUsecase) CreateWebrtcPeer(ctx context.Context, userID uuid.UUID, ch
 	return peer, nil
 }
 
-func (p *peerUsecase) broadcastRTP(ctx context.Context, pkt *rtp.Packet, userID uuid.UUID, channelID uuid.UUID) {
-	activeUsers := p.activeUserRepo.GetInChannel(ctx, channelID)
+func (p *peerUsecase) broadcastRTP(ctx context.Context, pkt *rtp.Packet, mimeType string, sender *domain.Peer) {
+	// Клиент слушателя может продолжать слать звук, сервер его не раздает
+	if sender.ListenOnly.Load() {
+		metrics.RTPPacketsDropped.WithLabelValues(metrics.DropReasonListenOnly).Inc()
+		return
+	}
 
-	for _, activeUser := range activeUsers {
-		if activeUser.ID == userID {
-			continue
-		}
+	userID, channelID := sender.UserID, sender.Room()
 
-		pc, ok := p.pcRepo.Get(activeUser.ID)
-		if !ok {
-			slog.Error("get peer connection in broadcast")
-			continue
+	if route := sender.Whisper(); route != nil {
+		p.whisperRTP(pkt, mimeType, sender, route)
+		return
+	}
+
+	p.forwardLocal(pkt, mimeType, userID, channelID)
+	p.relayRTP(pkt, mimeType, userID, channelID)
+}
+
+// whisperRTP отправляет звук только получателям шепота. Шепот возможен только в канал этой ноды,
+// поэтому звук не уходит на другие ноды: там вошедшие в канал не получили бы whisper_started
+func (p *peerUsecase) whisperRTP(pkt *rtp.Packet, mimeType string, sender *domain.Peer, route *domain.WhisperRoute) {
+	if route.IsChannel() {
+		p.forwardLocal(pkt, mimeType, sender.UserID, route.ChannelID)
+
+		return
+	}
+
+	// Получатель мог уйти в другой канал или комнату раньше, чем его убрали из маршрута
+	for _, targetID := range route.TargetIDs {
+		if peer, ok := p.pcRepo.Get(targetID); ok && peer.Room() == sender.Room() {
+			p.writeAudio(peer, pkt, mimeType, sender.UserID)
 		}
+	}
+}
+
+// relayRTP пересылает звук на другие ноды с участниками канала
+func (p *peerUsecase) relayRTP(pkt *rtp.Packet, mimeType string, userID uuid.UUID, channelID uuid.UUID) {
+	if !strings.EqualFold(mimeType, webrtc.MimeTypeOpus) {
+		return
+	}
 
-		err := pc.AudioTrack.WriteRTP(pkt)
+	// При каскадировании участники канала могут быть на других нодах
+	for _, node := range p.relayRepo.RemoteNodes(channelID) {
+		if err := p.relayTransport.Send(node.RelayAddr, channelID, userID, pkt); err != nil {
+			metrics.RTPPacketsDropped.WithLabelValues(metrics.DropReasonRelayError).Inc()
 
-		if err != nil {
 			slog.Error(
-				"write RTP",
+				"relay RTP",
 				slog.Any(constant.Error, err),
-				slog.Any(constant.UserID, userID),
+				slog.String("node_id", node.ID),
 				slog.Any(constant.ChannelID, channelID),
 			)
+
+			continue
 		}
+
+		metrics.RTPPacketsForwarded.Inc()
+		metrics.RTPBytesForwarded.Add(float64(len(pkt.Payload)))
 	}
 }
+
+// forwardLocal отправляет пакет всем пирам канала на этой ноде, кроме отправителя.
+// Звук не перекодируется: пир с другим кодеком пакет не получает
+func (p *peerUsecase) forwardLocal(pkt *rtp.Packet, mimeType string, userID uuid.UUID, channelID uuid.UUID) {
+	for _, peer := range p.pcRepo.GetInRoom(channelID) {
+		if peer.UserID == userID {
+			continue
+		}
+
+		p.writeAudio(peer, pkt, mimeType, userID)
+	}
+}
+
+func (p *peerUsecase) writeAudio(peer *domain.Peer, pkt *rtp.Packet, mimeType string, userID uuid.UUID) {
+	audioTrack := peer.AudioTrack()
+	if !strings.EqualFold(audioTrack.Codec().MimeType, mimeType) {
+		metrics.RTPPacketsDropped.WithLabelValues(metrics.DropReasonCodecMismatch).Inc()
+		return
+	}
+
+	if err := audioTrack.WriteRTP(pkt); err != nil {
+		metrics.RTPPacketsDropped.WithLabelValues(metrics.DropReasonWriteError).Inc()
+
+		slog.Error(
+			"write RTP",
+			slog.Any(constant.Error, err),
+			slog.Any(constant.UserID, userID),
+			slog.Any(constant.ChannelID, peer.ChannelID),
+		)
+
+		return
+	}
+
+	metrics.RTPPacketsForwarded.Inc()
+	metrics.RTPBytesForwarded.Add(float64(len(pkt.Payload)))
+}
@@ -0,0 +1,295 @@
+package usecase
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pion/rtcp"
+	"github.com/pion/webrtc/v4"
+
+	"github.com/qrave1/RoomSpeak/internal/application/constant"
+	"github.com/qrave1/RoomSpeak/internal/application/metrics"
+	"github.com/qrave1/RoomSpeak/internal/domain"
+	"github.com/qrave1/RoomSpeak/internal/domain/events"
+)
+
+// supportedVideoCodecs - кодеки, которые пересылаются получателям без перекодирования
+var supportedVideoCodecs = map[string]bool{
+	strings.ToLower(webrtc.MimeTypeVP8):  true,
+	strings.ToLower(webrtc.MimeTypeVP9):  true,
+	strings.ToLower(webrtc.MimeTypeH264): true,
+}
+
+// publishVideo раздает слой видео трека участникам канала, пока публикующий его не остановит.
+// При симулкасте OnTrack вызывается для каждого RID отдельно
+func (p *peerUsecase) publishVideo(publisher *domain.Peer, remote *webrtc.TrackRemote) {
+	codec := remote.Codec()
+
+	if !supportedVideoCodecs[strings.ToLower(codec.MimeType)] {
+		slog.Warn(
+			"unsupported video codec",
+			slog.String("mime_type", codec.MimeType),
+			slog.Any(constant.UserID, publisher.UserID),
+		)
+		return
+	}
+
+	rid := remote.RID()
+
+	track, created := publisher.PublishLayer(remote.ID(), rid, remote.SSRC(), codec.RTPCodecCapability)
+	defer p.unpublishLayer(publisher, track, rid)
+
+	if created {
+		for _, peer := range p.pcRepo.GetInRoom(publisher.Room()) {
+			if peer.UserID != publisher.UserID {
+				p.subscribeVideo(peer, publisher, track)
+			}
+		}
+
+		p.notifyLocalPeers(publisher, "track_published", trackPublishedEvent(track, publisher.TrackSource(track.ID)))
+	} else {
+		// Появился новый слой, получатели могут перейти на него
+		p.reselectLayers(publisher, track)
+	}
+
+	for {
+		pkt, _, err := remote.ReadRTP()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				slog.Error("video RTP read error", slog.Any(constant.Error, err))
+			}
+
+			return
+		}
+
+		keyframe := domain.IsKeyframeStart(track.MimeType, pkt.Payload)
+
+		for _, sub := range track.Subscribers() {
+			sent, err := sub.Forwarder.Forward(rid, pkt, keyframe)
+			if err != nil && !errors.Is(err, io.ErrClosedPipe) {
+				metrics.RTPPacketsDropped.WithLabelValues(metrics.DropReasonWriteError).Inc()
+				continue
+			}
+
+			if sent {
+				metrics.RTPPacketsForwarded.Inc()
+				metrics.RTPBytesForwarded.Add(float64(len(pkt.Payload)))
+			}
+		}
+	}
+}
+
+// unpublishLayer убирает закончившийся слой, трек снимается с публикации вместе с последним слоем
+func (p *peerUsecase) unpublishLayer(publisher *domain.Peer, track *domain.PublishedTrack, rid string) {
+	if !publisher.UnpublishLayer(track, rid) {
+		p.reselectLayers(publisher, track)
+		return
+	}
+
+	for _, peer := range p.pcRepo.GetInRoom(publisher.Room()) {
+		if err := peer.Unsubscribe(track.ID); err != nil {
+			slog.Error("remove forwarded video track", slog.Any(constant.Error, err), slog.Any(constant.UserID, peer.UserID))
+		}
+	}
+
+	p.notifyLocalPeers(publisher, "track_unpublished", events.TrackUnpublishedEvent{
+		TrackID: track.ID,
+		OwnerID: track.OwnerID.String(),
+	})
+}
+
+// subscribeVideo начинает отправлять трек получателю. До первого offer получателя
+// подписка откладывается до SubscribeExisting
+func (p *peerUsecase) subscribeVideo(subscriber, publisher *domain.Peer, track *domain.PublishedTrack) {
+	if !subscriber.Negotiated.Load() {
+		return
+	}
+
+	sub, added, err := subscriber.Subscribe(track)
+	if err != nil {
+		slog.Error("subscribe to video track", slog.Any(constant.Error, err), slog.Any(constant.UserID, subscriber.UserID))
+		return
+	}
+
+	if !added {
+		return
+	}
+
+	// Запросы ключевого кадра от получателя пересылаются публикующему для слоя,
+	// который получатель должен видеть
+	go p.readSenderRTCP(subscriber, sub.Sender, func() {
+		if layer, ok := sub.Track.Layer(sub.Forwarder.Target()); ok {
+			p.requestKeyframe(publisher, layer)
+		}
+	})
+
+	// Новому получателю нужен ключевой кадр, иначе картинка появится только на следующем
+	p.selectLayer(subscriber, publisher, sub)
+}
+
+// selectLayer выбирает слой под ограничения получателя. Переключение произойдет
+// на ближайшем ключевом кадре нового слоя, поэтому он сразу запрашивается
+func (p *peerUsecase) selectLayer(subscriber, publisher *domain.Peer, sub *domain.Subscription) {
+	bandwidthKbps, height := subscriber.LayerConstraints(sub.Track.ID)
+	rid := sub.Track.SelectLayer(bandwidthKbps, height)
+
+	if !sub.Forwarder.SetTarget(rid) {
+		return
+	}
+
+	if layer, ok := sub.Track.Layer(rid); ok {
+		p.requestKeyframe(publisher, layer)
+	}
+}
+
+// reselectLayers пересчитывает слои всех получателей трека после изменения набора слоев
+func (p *peerUsecase) reselectLayers(publisher *domain.Peer, track *domain.PublishedTrack) {
+	for _, sub := range track.Subscribers() {
+		if subscriber, ok := p.pcRepo.Get(sub.SubscriberID); ok {
+			p.selectLayer(subscriber, publisher, sub)
+		}
+	}
+}
+
+func (p *peerUsecase) UpdateLayers(subscriber *domain.Peer) {
+	for _, sub := range subscriber.Subscriptions() {
+		publisher, ok := p.pcRepo.Get(sub.Track.OwnerID)
+		if !ok {
+			continue
+		}
+
+		p.selectLayer(subscriber, publisher, sub)
+	}
+}
+
+// SetTrackSource сохраняет метаданные трека. Если трек уже раздается, участники
+// получают track_published повторно с новым источником
+func (p *peerUsecase) SetTrackSource(publisher *domain.Peer, trackID string, source domain.TrackSource) {
+	track, published := publisher.SetTrackSource(trackID, source)
+	if published {
+		p.notifyLocalPeers(publisher, "track_published", trackPublishedEvent(track, source))
+	}
+}
+
+// MoveToRoom отписывает участника от видео старой комнаты и комнату от его видео,
+// затем связывает его с участниками новой
+func (p *peerUsecase) MoveToRoom(peer *domain.Peer, roomID uuid.UUID) {
+	if peer.Room() == roomID {
+		return
+	}
+
+	for _, sub := range peer.Subscriptions() {
+		p.writeEvent(peer, "track_unpublished", events.TrackUnpublishedEvent{
+			TrackID: sub.Track.ID,
+			OwnerID: sub.Track.OwnerID.String(),
+		})
+	}
+
+	peer.UnsubscribeAll()
+
+	published := peer.Published()
+	for _, track := range published {
+		for _, other := range p.pcRepo.GetInRoom(peer.Room()) {
+			if err := other.Unsubscribe(track.ID); err != nil {
+				slog.Error("remove forwarded video track", slog.Any(constant.Error, err), slog.Any(constant.UserID, other.UserID))
+			}
+		}
+
+		p.notifyLocalPeers(peer, "track_unpublished", events.TrackUnpublishedEvent{
+			TrackID: track.ID,
+			OwnerID: track.OwnerID.String(),
+		})
+	}
+
+	peer.SetRoom(roomID)
+
+	p.SubscribeExisting(peer)
+
+	for _, track := range published {
+		for _, other := range p.pcRepo.GetInRoom(roomID) {
+			if other.UserID != peer.UserID {
+				p.subscribeVideo(other, peer, track)
+			}
+		}
+
+		p.notifyLocalPeers(peer, "track_published", trackPublishedEvent(track, peer.TrackSource(track.ID)))
+	}
+}
+
+// SubscribeExisting подписывает участника на видео, опубликованное до его подключения
+func (p *peerUsecase) SubscribeExisting(subscriber *domain.Peer) {
+	for _, publisher := range p.pcRepo.GetInRoom(subscriber.Room()) {
+		if publisher.UserID == subscriber.UserID {
+			continue
+		}
+
+		for _, track := range publisher.Published() {
+			p.subscribeVideo(subscriber, publisher, track)
+
+			p.writeEvent(subscriber, "track_published", trackPublishedEvent(track, publisher.TrackSource(track.ID)))
+		}
+	}
+}
+
+func (p *peerUsecase) requestKeyframe(publisher *domain.Peer, layer *domain.Layer) {
+	if !layer.AllowKeyframeRequest(time.Now()) {
+		return
+	}
+
+	err := publisher.Conn.WriteRTCP([]rtcp.Packet{
+		&rtcp.PictureLossIndication{MediaSSRC: uint32(layer.SSRC)},
+	})
+	if err != nil && !errors.Is(err, io.ErrClosedPipe) {
+		slog.Error("send PLI to publisher", slog.Any(constant.Error, err), slog.Any(constant.UserID, publisher.UserID))
+	}
+}
+
+// renegotiate отправляет клиенту offer сервера после изменения набора треков
+func (p *peerUsecase) renegotiate(peer *domain.Peer) {
+	offer, err := peer.Conn.CreateOffer(nil)
+	if err != nil {
+		slog.Error("create renegotiation offer", slog.Any(constant.Error, err), slog.Any(constant.UserID, peer.UserID))
+		return
+	}
+
+	if err = peer.Conn.SetLocalDescription(offer); err != nil {
+		slog.Error("set renegotiation offer", slog.Any(constant.Error, err), slog.Any(constant.UserID, peer.UserID))
+		return
+	}
+
+	p.wsRepo.Write(peer.UserID, map[string]any{"type": "offer", "sdp": offer.SDP})
+}
+
+// notifyLocalPeers отправляет событие участникам комнаты на этой ноде, видео между нодами не пересылается
+func (p *peerUsecase) notifyLocalPeers(publisher *domain.Peer, eventType string, payload any) {
+	for _, peer := range p.pcRepo.GetInRoom(publisher.Room()) {
+		if peer.UserID != publisher.UserID {
+			p.writeEvent(peer, eventType, payload)
+		}
+	}
+}
+
+func (p *peerUsecase) writeEvent(peer *domain.Peer, eventType string, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("marshal event", slog.Any(constant.Error, err), slog.String("type", eventType))
+		return
+	}
+
+	p.wsRepo.Write(peer.UserID, events.Message{Type: eventType, Data: data})
+}
+
+func trackPublishedEvent(track *domain.PublishedTrack, source domain.TrackSource) events.TrackPublishedEvent {
+	return events.TrackPublishedEvent{
+		TrackID:  track.ID,
+		StreamID: track.ID,
+		OwnerID:  track.OwnerID.String(),
+		Source:   string(source),
+		MimeType: track.MimeType,
+	}
+}
@@ -0,0 +1,256 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/qrave1/RoomSpeak/internal/application/constant"
+	"github.com/qrave1/RoomSpeak/internal/domain/events"
+	"github.com/qrave1/RoomSpeak/internal/domain/runtime"
+	"github.com/qrave1/RoomSpeak/internal/infra/adapters/memory"
+)
+
+const (
+	maxCustomStatusLen = 128
+
+	// presenceCheckInterval - как часто проверяем переходы в away и истечение кастомных статусов
+	presenceCheckInterval = 15 * time.Second
+
+	// presenceRetention - сколько помним выставленный статус отключившегося пользователя
+	presenceRetention = 7 * 24 * time.Hour
+)
+
+// PresenceUsecase отслеживает присутствие пользователей и рассылает presence_updated
+type PresenceUsecase interface {
+	// Run периодически пересчитывает статусы по неактивности до отмены ctx
+	Run(ctx context.Context)
+
+	Connect(ctx context.Context, userID uuid.UUID)
+	Disconnect(ctx context.Context, userID uuid.UUID)
+
+	// Heartbeat отмечает активность пользователя, idle - время бездействия по данным клиента
+	Heartbeat(ctx context.Context, userID uuid.UUID, idle time.Duration)
+
+	SetStatus(ctx context.Context, userID uuid.UUID, status runtime.PresenceStatus)
+	SetCustomStatus(ctx context.Context, userID uuid.UUID, text string, ttl time.Duration)
+
+	// VisibleStatus возвращает статус и текст статуса пользователя так, как их видят остальные
+	VisibleStatus(ctx context.Context, userID uuid.UUID) (runtime.PresenceStatus, string)
+}
+
+type presenceView struct {
+	status runtime.PresenceStatus
+	custom string
+}
+
+type presenceUsecase struct {
+	idleTimeout time.Duration
+
+	presenceRepo memory.PresenceRepository
+	wsRepo       memory.WebsocketConnectionRepository
+
+	// mu сериализует изменения присутствия вместе с рассылкой, иначе события одного пользователя
+	// могли бы уйти не в том порядке. lastSent - последнее разосланное состояние подключенных пользователей
+	mu       sync.Mutex
+	lastSent map[uuid.UUID]presenceView
+}
+
+func NewPresenceUsecase(
+	idleTimeout time.Duration,
+	presenceRepo memory.PresenceRepository,
+	wsRepo memory.WebsocketConnectionRepository,
+) PresenceUsecase {
+	return &presenceUsecase{
+		idleTimeout:  idleTimeout,
+		presenceRepo: presenceRepo,
+		wsRepo:       wsRepo,
+		lastSent:     make(map[uuid.UUID]presenceView),
+	}
+}
+
+func (uc *presenceUsecase) Run(ctx context.Context) {
+	ticker := time.NewTicker(presenceCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			uc.presenceRepo.DeleteDisconnected(ctx, time.Now().Add(-presenceRetention))
+
+			uc.mu.Lock()
+			for _, presence := range uc.presenceRepo.GetConnected(ctx) {
+				// Переходы пользователей других нод рассылают их ноды
+				if !uc.wsRepo.IsConnected(presence.UserID) {
+					continue
+				}
+
+				if view, ok := uc.trackSent(presence); ok {
+					uc.publish(presence, view)
+				}
+			}
+			uc.mu.Unlock()
+		}
+	}
+}
+
+func (uc *presenceUsecase) Connect(ctx context.Context, userID uuid.UUID) {
+	uc.update(ctx, userID, func(p *runtime.Presence) {
+		p.Connected = true
+		p.LastActivity = time.Now()
+		p.DisconnectedAt = nil
+	})
+}
+
+func (uc *presenceUsecase) Disconnect(ctx context.Context, userID uuid.UUID) {
+	uc.update(ctx, userID, func(p *runtime.Presence) {
+		now := time.Now()
+
+		p.Connected = false
+		p.DisconnectedAt = &now
+	})
+}
+
+func (uc *presenceUsecase) Heartbeat(ctx context.Context, userID uuid.UUID, idle time.Duration) {
+	uc.update(ctx, userID, func(p *runtime.Presence) {
+		p.LastActivity = time.Now().Add(-idle)
+	})
+}
+
+func (uc *presenceUsecase) SetStatus(ctx context.Context, userID uuid.UUID, status runtime.PresenceStatus) {
+	if !status.IsManual() {
+		uc.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "invalid status"})
+		return
+	}
+
+	uc.update(ctx, userID, func(p *runtime.Presence) {
+		p.Status = status
+		p.LastActivity = time.Now()
+	})
+}
+
+func (uc *presenceUsecase) SetCustomStatus(ctx context.Context, userID uuid.UUID, text string, ttl time.Duration) {
+	if utf8.RuneCountInString(text) > maxCustomStatusLen {
+		uc.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "custom status is too long"})
+		return
+	}
+
+	uc.update(ctx, userID, func(p *runtime.Presence) {
+		p.CustomText = text
+		p.CustomExpiresAt = nil
+
+		if text != "" && ttl > 0 {
+			expiresAt := time.Now().Add(ttl)
+			p.CustomExpiresAt = &expiresAt
+		}
+	})
+}
+
+func (uc *presenceUsecase) VisibleStatus(ctx context.Context, userID uuid.UUID) (runtime.PresenceStatus, string) {
+	presence, ok := uc.presenceRepo.Get(ctx, userID)
+	if !ok {
+		return runtime.PresenceOffline, ""
+	}
+
+	now := time.Now()
+
+	return presence.Visible(now, uc.idleTimeout), presence.CustomStatus(now)
+}
+
+func (uc *presenceUsecase) update(ctx context.Context, userID uuid.UUID, fn func(p *runtime.Presence)) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	presence, ok := uc.presenceRepo.Get(ctx, userID)
+	if !ok {
+		presence = runtime.Presence{
+			UserID: userID,
+			Status: runtime.PresenceOnline,
+		}
+	}
+
+	fn(&presence)
+
+	uc.presenceRepo.Set(ctx, presence)
+
+	if view, changed := uc.trackSent(presence); changed {
+		uc.publish(presence, view)
+	}
+}
+
+// trackSent запоминает видимое состояние и возвращает его, если оно изменилось. Вызывается под mu.
+// Отключившийся пользователь забывается: следующее подключение в любом случае будет изменением
+func (uc *presenceUsecase) trackSent(presence runtime.Presence) (presenceView, bool) {
+	now := time.Now()
+
+	effective := presenceView{
+		status: presence.Effective(now, uc.idleTimeout),
+		custom: presence.CustomStatus(now),
+	}
+
+	if last, ok := uc.lastSent[presence.UserID]; ok && last == effective {
+		return presenceView{}, false
+	}
+
+	if presence.Connected {
+		uc.lastSent[presence.UserID] = effective
+	} else {
+		delete(uc.lastSent, presence.UserID)
+	}
+
+	return effective, true
+}
+
+// publish рассылает presence_updated, вызывается под mu. Сам пользователь получает свой настоящий статус, остальные - видимый
+func (uc *presenceUsecase) publish(presence runtime.Presence, effective presenceView) {
+	visible := effective
+	if visible.status == runtime.PresenceInvisible {
+		visible.status = runtime.PresenceOffline
+	}
+
+	if visible.status == runtime.PresenceOffline {
+		visible.custom = ""
+	}
+
+	ownEvent, err := uc.marshalPresence(presence, effective)
+	if err != nil {
+		slog.Error("marshal presence event", slog.Any(constant.Error, err))
+		return
+	}
+
+	visibleEvent, err := uc.marshalPresence(presence, visible)
+	if err != nil {
+		slog.Error("marshal presence event", slog.Any(constant.Error, err))
+		return
+	}
+
+	for _, recipientID := range uc.wsRepo.GetAllConnected() {
+		data := visibleEvent
+		if recipientID == presence.UserID {
+			data = ownEvent
+		}
+
+		uc.wsRepo.Write(recipientID, events.Message{Type: "presence_updated", Data: data})
+	}
+}
+
+func (uc *presenceUsecase) marshalPresence(presence runtime.Presence, view presenceView) (json.RawMessage, error) {
+	event := events.PresenceUpdatedEvent{
+		UserID:       presence.UserID.String(),
+		Status:       string(view.status),
+		CustomStatus: view.custom,
+	}
+
+	if view.custom != "" && view.status != runtime.PresenceOffline {
+		event.ExpiresAt = presence.CustomExpiresAt
+	}
+
+	return json.Marshal(event)
+}
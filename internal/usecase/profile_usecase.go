@@ -0,0 +1,189 @@
+package usecase
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/qrave1/RoomSpeak/internal/application/constant"
+	"github.com/qrave1/RoomSpeak/internal/domain/input"
+	"github.com/qrave1/RoomSpeak/internal/domain/models"
+	"github.com/qrave1/RoomSpeak/internal/domain/output"
+	"github.com/qrave1/RoomSpeak/internal/infra/adapters/postgres/repository"
+	"github.com/qrave1/RoomSpeak/internal/infra/adapters/storage"
+)
+
+const (
+	maxDisplayNameLen = 64
+	maxBioLen         = 500
+)
+
+var (
+	ErrInvalidProfile    = errors.New("invalid profile")
+	ErrAvatarTooLarge    = errors.New("avatar is too large")
+	ErrUnsupportedAvatar = errors.New("unsupported avatar image type")
+	accentColorRe        = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+	avatarExtByMediaType = map[string]string{
+		"image/png":  ".png",
+		"image/jpeg": ".jpg",
+		"image/gif":  ".gif",
+		"image/webp": ".webp",
+	}
+)
+
+// ProfileUsecase управляет профилями пользователей
+type ProfileUsecase interface {
+	GetProfile(ctx context.Context, userID uuid.UUID) (*output.UserProfile, error)
+	UpdateProfile(ctx context.Context, update *input.UpdateProfileInput) (*output.UserProfile, error)
+
+	// UploadAvatar сохраняет изображение в хранилище, тип определяется по содержимому
+	UploadAvatar(ctx context.Context, userID uuid.UUID, r io.Reader, size int64) (*output.UserProfile, error)
+	DeleteAvatar(ctx context.Context, userID uuid.UUID) (*output.UserProfile, error)
+}
+
+type profileUsecase struct {
+	avatarMaxSize int64
+
+	userRepo      repository.UserRepository
+	avatarStorage storage.AvatarStorage
+}
+
+func NewProfileUsecase(avatarMaxSize int64, userRepo repository.UserRepository, avatarStorage storage.AvatarStorage) ProfileUsecase {
+	return &profileUsecase{
+		avatarMaxSize: avatarMaxSize,
+		userRepo:      userRepo,
+		avatarStorage: avatarStorage,
+	}
+}
+
+func (uc *profileUsecase) GetProfile(ctx context.Context, userID uuid.UUID) (*output.UserProfile, error) {
+	user, err := uc.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+
+	return uc.toProfile(user), nil
+}
+
+func (uc *profileUsecase) UpdateProfile(ctx context.Context, update *input.UpdateProfileInput) (*output.UserProfile, error) {
+	user, err := uc.userRepo.GetUserByID(ctx, update.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+
+	if update.DisplayName != nil {
+		if utf8.RuneCountInString(*update.DisplayName) > maxDisplayNameLen {
+			return nil, fmt.Errorf("%w: display_name is longer than %d characters", ErrInvalidProfile, maxDisplayNameLen)
+		}
+
+		user.DisplayName = *update.DisplayName
+	}
+
+	if update.Bio != nil {
+		if utf8.RuneCountInString(*update.Bio) > maxBioLen {
+			return nil, fmt.Errorf("%w: bio is longer than %d characters", ErrInvalidProfile, maxBioLen)
+		}
+
+		user.Bio = *update.Bio
+	}
+
+	if update.AccentColor != nil {
+		if *update.AccentColor != "" && !accentColorRe.MatchString(*update.AccentColor) {
+			return nil, fmt.Errorf("%w: accent_color must be #RRGGBB", ErrInvalidProfile)
+		}
+
+		user.AccentColor = *update.AccentColor
+	}
+
+	if err = uc.userRepo.UpdateProfile(ctx, user); err != nil {
+		return nil, fmt.Errorf("update profile: %w", err)
+	}
+
+	return uc.toProfile(user), nil
+}
+
+func (uc *profileUsecase) UploadAvatar(ctx context.Context, userID uuid.UUID, r io.Reader, size int64) (*output.UserProfile, error) {
+	if size > uc.avatarMaxSize {
+		return nil, ErrAvatarTooLarge
+	}
+
+	user, err := uc.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+
+	// Тип определяем по сигнатуре файла, а не по заголовку от клиента
+	br := bufio.NewReaderSize(r, 512)
+	head, _ := br.Peek(512)
+
+	mediaType := http.DetectContentType(head)
+
+	ext, ok := avatarExtByMediaType[mediaType]
+	if !ok {
+		return nil, ErrUnsupportedAvatar
+	}
+
+	key := fmt.Sprintf("avatars/%s/%s%s", userID, uuid.NewString(), ext)
+
+	if err = uc.avatarStorage.Put(ctx, key, mediaType, br, size); err != nil {
+		return nil, fmt.Errorf("put avatar: %w", err)
+	}
+
+	if err = uc.userRepo.SetAvatarKey(ctx, userID, key); err != nil {
+		return nil, fmt.Errorf("set avatar key: %w", err)
+	}
+
+	uc.deleteObject(ctx, user.AvatarKey)
+
+	user.AvatarKey = key
+
+	return uc.toProfile(user), nil
+}
+
+func (uc *profileUsecase) DeleteAvatar(ctx context.Context, userID uuid.UUID) (*output.UserProfile, error) {
+	user, err := uc.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get user: %w", err)
+	}
+
+	if err = uc.userRepo.SetAvatarKey(ctx, userID, ""); err != nil {
+		return nil, fmt.Errorf("set avatar key: %w", err)
+	}
+
+	uc.deleteObject(ctx, user.AvatarKey)
+
+	user.AvatarKey = ""
+
+	return uc.toProfile(user), nil
+}
+
+// deleteObject удаляет старый файл. Ошибка не критична, остается лишь мусор в хранилище
+func (uc *profileUsecase) deleteObject(ctx context.Context, key string) {
+	if key == "" {
+		return
+	}
+
+	if err := uc.avatarStorage.Delete(ctx, key); err != nil {
+		slog.Error("delete old avatar", slog.Any(constant.Error, err), slog.String("key", key))
+	}
+}
+
+func (uc *profileUsecase) toProfile(user *models.User) *output.UserProfile {
+	return &output.UserProfile{
+		ID:          user.ID.String(),
+		Username:    user.Username,
+		DisplayName: user.DisplayName,
+		AvatarURL:   uc.avatarStorage.URL(user.AvatarKey),
+		Bio:         user.Bio,
+		AccentColor: user.AccentColor,
+		IsBot:       user.IsBot,
+	}
+}
@@ -0,0 +1,348 @@
+package usecase
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pion/webrtc/v4"
+
+	"github.com/qrave1/RoomSpeak/internal/application/constant"
+	"github.com/qrave1/RoomSpeak/internal/domain"
+	"github.com/qrave1/RoomSpeak/internal/domain/events"
+	"github.com/qrave1/RoomSpeak/internal/domain/runtime"
+	"github.com/qrave1/RoomSpeak/internal/infra/adapters/memory"
+	postrepo "github.com/qrave1/RoomSpeak/internal/infra/adapters/postgres/repository"
+)
+
+// opusClockRateKHz - частота RTP часов Opus, джиттер входящего потока считается в ее тиках
+const opusClockRateKHz = 48
+
+var (
+	ErrChannelNotFound     = errors.New("channel not found")
+	ErrChannelAccessDenied = errors.New("no access to channel")
+)
+
+// QualityUsecase собирает качество соединений участников из статистики pion
+// и RTCP отчетов получателей
+type QualityUsecase interface {
+	// Run раз в interval замеряет соединения и рассылает connection_quality до отмены ctx
+	Run(ctx context.Context)
+
+	// ChannelQuality возвращает последние замеры участников канала на этой ноде
+	ChannelQuality(ctx context.Context, userID, channelID uuid.UUID) ([]runtime.ConnectionQuality, error)
+
+	// Forget пишет в лог сводку по соединению ушедшего участника и забывает его
+	Forget(peer *domain.Peer)
+}
+
+// qualityCounters - накопительные счетчики потоков, по разнице между замерами считаются
+// потери и битрейт за интервал
+type qualityCounters struct {
+	at time.Time
+
+	packetsReceived uint64
+	packetsLost     int64
+	bytesReceived   uint64
+	bytesSent       uint64
+}
+
+type qualityState struct {
+	last   qualityCounters
+	latest runtime.ConnectionQuality
+
+	samples     int
+	poorSamples int
+	lossSum     float64
+	maxLoss     float64
+	rttSum      float64
+	maxRTT      float64
+}
+
+type qualityUsecase struct {
+	interval time.Duration
+
+	channelRepo postrepo.ChannelRepository
+
+	pcRepo memory.PeerConnectionRepository
+	wsRepo memory.WebsocketConnectionRepository
+
+	mu     sync.Mutex
+	states map[uuid.UUID]*qualityState
+}
+
+func NewQualityUsecase(
+	interval time.Duration,
+	channelRepo postrepo.ChannelRepository,
+	pcRepo memory.PeerConnectionRepository,
+	wsRepo memory.WebsocketConnectionRepository,
+) QualityUsecase {
+	return &qualityUsecase{
+		interval:    interval,
+		channelRepo: channelRepo,
+		pcRepo:      pcRepo,
+		wsRepo:      wsRepo,
+		states:      make(map[uuid.UUID]*qualityState),
+	}
+}
+
+func (uc *qualityUsecase) Run(ctx context.Context) {
+	ticker := time.NewTicker(uc.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			channels := make(map[uuid.UUID][]events.ParticipantQuality)
+
+			for _, peer := range uc.pcRepo.GetAll() {
+				quality, ok := uc.sample(peer)
+				if !ok {
+					continue
+				}
+
+				channels[peer.ChannelID] = append(channels[peer.ChannelID], events.ParticipantQuality{
+					UserID:   quality.UserID.String(),
+					Level:    string(quality.Level),
+					Loss:     quality.Loss(),
+					RTTMs:    quality.RTTMs,
+					JitterMs: quality.JitterMs,
+				})
+			}
+
+			for channelID, participants := range channels {
+				uc.broadcast(channelID, participants)
+			}
+		}
+	}
+}
+
+func (uc *qualityUsecase) ChannelQuality(ctx context.Context, userID, channelID uuid.UUID) ([]runtime.ConnectionQuality, error) {
+	channel, err := uc.channelRepo.GetByID(ctx, channelID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrChannelNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get channel: %w", err)
+	}
+
+	if !channel.IsPublic && channel.CreatorID != userID {
+		memberIDs, err := uc.channelRepo.GetMemberIDs(ctx, channelID)
+		if err != nil {
+			return nil, fmt.Errorf("get channel members: %w", err)
+		}
+
+		if !slices.Contains(memberIDs, userID) {
+			return nil, ErrChannelAccessDenied
+		}
+	}
+
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	peers := uc.pcRepo.GetInChannel(channelID)
+	qualities := make([]runtime.ConnectionQuality, 0, len(peers))
+
+	for _, peer := range peers {
+		if state, ok := uc.states[peer.UserID]; ok {
+			qualities = append(qualities, state.latest)
+			continue
+		}
+
+		// Участник подключился после последнего замера
+		qualities = append(qualities, runtime.ConnectionQuality{
+			UserID:    peer.UserID,
+			ChannelID: peer.ChannelID,
+			Level:     runtime.QualityUnknown,
+		})
+	}
+
+	return qualities, nil
+}
+
+func (uc *qualityUsecase) Forget(peer *domain.Peer) {
+	uc.mu.Lock()
+	state, ok := uc.states[peer.UserID]
+	delete(uc.states, peer.UserID)
+	uc.mu.Unlock()
+
+	attrs := []any{
+		slog.Any(constant.UserID, peer.UserID),
+		slog.Any(constant.ChannelID, peer.ChannelID),
+		slog.Duration("duration", time.Since(peer.JoinedAt)),
+	}
+
+	if ok && state.samples > 0 {
+		attrs = append(attrs,
+			slog.Int("samples", state.samples),
+			slog.Int("poor_samples", state.poorSamples),
+			slog.Float64("avg_loss", state.lossSum/float64(state.samples)),
+			slog.Float64("max_loss", state.maxLoss),
+			slog.Float64("avg_rtt_ms", state.rttSum/float64(state.samples)),
+			slog.Float64("max_rtt_ms", state.maxRTT),
+			slog.String("candidate_type", state.latest.CandidateType),
+		)
+	}
+
+	slog.Info("peer connection quality summary", attrs...)
+}
+
+// sample замеряет соединение участника. false - участник уже ушел
+func (uc *qualityUsecase) sample(peer *domain.Peer) (runtime.ConnectionQuality, bool) {
+	counters, quality := measureQuality(peer)
+
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+
+	// Forget вызывается после удаления пира, замер ушедшего не должен воскресить его состояние
+	if current, ok := uc.pcRepo.Get(peer.UserID); !ok || current != peer {
+		return runtime.ConnectionQuality{}, false
+	}
+
+	state, ok := uc.states[peer.UserID]
+	if !ok {
+		state = &qualityState{}
+		uc.states[peer.UserID] = state
+	}
+
+	if !state.last.at.IsZero() {
+		applyCounterDeltas(&quality, state.last, counters)
+	}
+
+	quality.Level = runtime.QualityUnknown
+	if peer.InboundAudioSSRC.Load() != 0 {
+		quality.Level = runtime.QualityLevelFor(quality.Loss(), quality.RTTMs, quality.JitterMs)
+
+		state.samples++
+		state.lossSum += quality.Loss()
+		state.maxLoss = max(state.maxLoss, quality.Loss())
+		state.rttSum += quality.RTTMs
+		state.maxRTT = max(state.maxRTT, quality.RTTMs)
+
+		if quality.Level == runtime.QualityPoor || quality.Level == runtime.QualityBad {
+			state.poorSamples++
+		}
+	}
+
+	state.last = counters
+	state.latest = quality
+
+	return quality, true
+}
+
+func (uc *qualityUsecase) broadcast(channelID uuid.UUID, participants []events.ParticipantQuality) {
+	data, err := json.Marshal(events.ConnectionQualityEvent{
+		ChannelID:    channelID.String(),
+		Participants: participants,
+	})
+	if err != nil {
+		slog.Error("marshal connection quality", slog.Any(constant.Error, err))
+		return
+	}
+
+	for _, peer := range uc.pcRepo.GetInChannel(channelID) {
+		uc.wsRepo.Write(peer.UserID, events.Message{Type: "connection_quality", Data: data})
+	}
+}
+
+// measureQuality читает статистику соединения: ICE пару, входящий звук клиента и
+// receiver reports клиента по отправляемому ему звуку
+func measureQuality(peer *domain.Peer) (qualityCounters, runtime.ConnectionQuality) {
+	counters := qualityCounters{at: time.Now()}
+	quality := runtime.ConnectionQuality{
+		UserID:     peer.UserID,
+		ChannelID:  peer.ChannelID,
+		MeasuredAt: counters.at,
+	}
+
+	quality.CandidateType, quality.RTTMs = selectedCandidatePair(peer.Conn)
+
+	if peer.Stats == nil {
+		return counters, quality
+	}
+
+	if ssrc := peer.InboundAudioSSRC.Load(); ssrc != 0 {
+		if s := peer.Stats.Get(ssrc); s != nil {
+			counters.packetsReceived = s.InboundRTPStreamStats.PacketsReceived
+			counters.packetsLost = s.InboundRTPStreamStats.PacketsLost
+			counters.bytesReceived = s.InboundRTPStreamStats.BytesReceived
+
+			quality.JitterMs = s.InboundRTPStreamStats.Jitter / opusClockRateKHz
+		}
+	}
+
+	if encodings := peer.AudioSender.GetParameters().Encodings; len(encodings) > 0 {
+		if s := peer.Stats.Get(uint32(encodings[0].SSRC)); s != nil {
+			counters.bytesSent = s.OutboundRTPStreamStats.BytesSent
+
+			quality.DownstreamLoss = s.RemoteInboundRTPStreamStats.FractionLost
+			quality.JitterMs = max(quality.JitterMs, s.RemoteInboundRTPStreamStats.Jitter*1000)
+
+			if quality.RTTMs == 0 {
+				quality.RTTMs = float64(s.RemoteInboundRTPStreamStats.RoundTripTime.Milliseconds())
+			}
+		}
+	}
+
+	return counters, quality
+}
+
+func applyCounterDeltas(quality *runtime.ConnectionQuality, prev, cur qualityCounters) {
+	received := float64(cur.packetsReceived) - float64(prev.packetsReceived)
+	lost := float64(cur.packetsLost - prev.packetsLost)
+
+	if lost > 0 && received+lost > 0 {
+		quality.UpstreamLoss = lost / (received + lost)
+	}
+
+	if seconds := cur.at.Sub(prev.at).Seconds(); seconds > 0 {
+		quality.UpstreamKbps = kbps(prev.bytesReceived, cur.bytesReceived, seconds)
+		quality.DownstreamKbps = kbps(prev.bytesSent, cur.bytesSent, seconds)
+	}
+}
+
+func kbps(prevBytes, curBytes uint64, seconds float64) int {
+	// Счетчики потока могли пропасть вместе с потоком
+	if curBytes < prevBytes {
+		return 0
+	}
+
+	return int(float64(curBytes-prevBytes) * 8 / 1000 / seconds)
+}
+
+// selectedCandidatePair возвращает тип кандидата клиента в выбранной ICE паре и RTT пары.
+// Если сервер сам ходит через TURN, соединение тоже считается relay
+func selectedCandidatePair(pc *webrtc.PeerConnection) (string, float64) {
+	var (
+		candidateType string
+		rttMs         float64
+	)
+
+	pair, err := pc.SCTP().Transport().ICETransport().GetSelectedCandidatePair()
+	if err != nil || pair == nil {
+		return candidateType, rttMs
+	}
+
+	candidateType = pair.Remote.Typ.String()
+	if pair.Local.Typ == webrtc.ICECandidateTypeRelay {
+		candidateType = webrtc.ICECandidateTypeRelay.String()
+	}
+
+	for _, stat := range pc.GetStats() {
+		if pairStats, ok := stat.(webrtc.ICECandidatePairStats); ok && pairStats.Nominated {
+			rttMs = pairStats.CurrentRoundTripTime * 1000
+			break
+		}
+	}
+
+	return candidateType, rttMs
+}
@@ -0,0 +1,446 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/qrave1/RoomSpeak/internal/application/constant"
+	"github.com/qrave1/RoomSpeak/internal/domain"
+	"github.com/qrave1/RoomSpeak/internal/domain/events"
+	"github.com/qrave1/RoomSpeak/internal/domain/models"
+	"github.com/qrave1/RoomSpeak/internal/domain/runtime"
+)
+
+const (
+	maxBreakoutRooms    = 50
+	maxBreakoutDuration = 4 * time.Hour
+
+	// breakoutCleanupGrace - сколько комната может пережить свою сессию. Дольше живут
+	// только комнаты упавшей ноды, их удаляет RunBreakoutCleanup
+	breakoutCleanupGrace    = time.Hour
+	breakoutCleanupInterval = 10 * time.Minute
+)
+
+// breakoutCountdownMarks - за сколько секунд до конца участникам рассылается обратный отсчет
+var breakoutCountdownMarks = []int{60, 30, 10, 5, 4, 3, 2, 1}
+
+// breakoutSession - идущая сессия breakout канала. Пока комнаты создаются, cancel пустой
+type breakoutSession struct {
+	rooms  []*models.Channel
+	endsAt time.Time
+	cancel context.CancelFunc
+}
+
+func (s *signalingUsecase) RunBreakoutCleanup(ctx context.Context) {
+	ticker := time.NewTicker(breakoutCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.channelRepo.DeleteExpiredBreakouts(ctx, time.Now().Add(-breakoutCleanupGrace)); err != nil {
+			slog.Error("delete expired breakout rooms", slog.Any(constant.Error, err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *signalingUsecase) HandleBreakoutStart(ctx context.Context, userID uuid.UUID, event events.BreakoutStartEvent) error {
+	peer, ok := s.pcRepo.Get(userID)
+	if !ok {
+		return fmt.Errorf("peer connection not found")
+	}
+
+	if peer.Room() != peer.ChannelID {
+		s.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "already in breakout room"})
+		return nil
+	}
+
+	channel, err := s.channelRepo.GetByID(ctx, peer.ChannelID)
+	if err != nil {
+		return fmt.Errorf("get channel: %w", err)
+	}
+
+	if !channel.IsModerator(userID) {
+		s.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "only moderator can start breakout"})
+		return nil
+	}
+
+	duration := time.Duration(event.DurationSeconds) * time.Second
+	if duration <= 0 || duration > maxBreakoutDuration {
+		s.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "invalid breakout duration"})
+		return nil
+	}
+
+	assignments, ok := s.assignBreakout(ctx, userID, channel, event)
+	if !ok {
+		return nil
+	}
+
+	// Место под сессию занимается до создания комнат, чтобы два запуска не разошлись
+	s.breakoutMu.Lock()
+	if _, running := s.breakouts[channel.ID]; running {
+		s.breakoutMu.Unlock()
+		s.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "breakout is already running"})
+
+		return nil
+	}
+	s.breakouts[channel.ID] = &breakoutSession{}
+	s.breakoutMu.Unlock()
+
+	endsAt := time.Now().Add(duration)
+
+	rooms := make([]*models.Channel, 0, len(assignments))
+	for i := range assignments {
+		room := models.NewBreakoutChannel(channel, i+1, endsAt)
+
+		if err = s.channelRepo.Create(ctx, room); err != nil {
+			s.deleteBreakoutRooms(ctx, rooms)
+
+			s.breakoutMu.Lock()
+			delete(s.breakouts, channel.ID)
+			s.breakoutMu.Unlock()
+
+			return fmt.Errorf("create breakout room: %w", err)
+		}
+
+		rooms = append(rooms, room)
+	}
+
+	sessionCtx, cancel := context.WithCancel(context.Background())
+
+	s.breakoutMu.Lock()
+	s.breakouts[channel.ID] = &breakoutSession{rooms: rooms, endsAt: endsAt, cancel: cancel}
+	s.breakoutMu.Unlock()
+
+	startedEvent := events.BreakoutStartedEvent{
+		ChannelID: channel.ID.String(),
+		EndsAt:    endsAt,
+		Rooms:     make([]events.BreakoutRoom, 0, len(rooms)),
+	}
+
+	for i, room := range rooms {
+		roomInfo := events.BreakoutRoom{ChannelID: room.ID.String(), Name: room.Name, UserIDs: []string{}}
+
+		for _, activeUser := range assignments[i] {
+			participant, ok := s.pcRepo.Get(activeUser.ID)
+			if !ok || !s.moveToRoom(ctx, participant, room.ID, channel) {
+				continue
+			}
+
+			roomInfo.UserIDs = append(roomInfo.UserIDs, activeUser.ID.String())
+		}
+
+		startedEvent.Rooms = append(startedEvent.Rooms, roomInfo)
+	}
+
+	if err = s.writeToCall(channel.ID, "breakout_started", startedEvent); err != nil {
+		return err
+	}
+
+	for _, roomID := range append([]uuid.UUID{channel.ID}, breakoutRoomIDs(rooms)...) {
+		if err = s.BroadcastActiveMembers(ctx, roomID); err != nil {
+			return fmt.Errorf("broadcast active members: %w", err)
+		}
+	}
+
+	go s.runBreakout(sessionCtx, channel.ID, endsAt)
+
+	return nil
+}
+
+func (s *signalingUsecase) HandleBreakoutStop(ctx context.Context, userID uuid.UUID) error {
+	peer, ok := s.pcRepo.Get(userID)
+	if !ok {
+		return fmt.Errorf("peer connection not found")
+	}
+
+	channel, err := s.channelRepo.GetByID(ctx, peer.ChannelID)
+	if err != nil {
+		return fmt.Errorf("get channel: %w", err)
+	}
+
+	if !channel.IsModerator(userID) {
+		s.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "only moderator can stop breakout"})
+		return nil
+	}
+
+	if !s.endBreakout(ctx, channel.ID) {
+		s.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "no breakout running"})
+	}
+
+	return nil
+}
+
+// assignBreakout раскладывает участников канала по комнатам: вручную по спискам модератора
+// или случайно поровну. Модератор остается в канале
+func (s *signalingUsecase) assignBreakout(
+	ctx context.Context,
+	userID uuid.UUID,
+	channel *models.Channel,
+	event events.BreakoutStartEvent,
+) ([][]runtime.ActiveUser, bool) {
+	participants := make(map[uuid.UUID]runtime.ActiveUser)
+	for _, activeUser := range s.activeUserRepo.GetInChannel(ctx, channel.ID) {
+		if _, local := s.pcRepo.Get(activeUser.ID); local && !channel.IsModerator(activeUser.ID) {
+			participants[activeUser.ID] = activeUser
+		}
+	}
+
+	if len(event.Assignments) > 0 {
+		if len(event.Assignments) > maxBreakoutRooms {
+			s.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "too many breakout rooms"})
+			return nil, false
+		}
+
+		assignments := make([][]runtime.ActiveUser, len(event.Assignments))
+		assigned := make(map[uuid.UUID]bool)
+
+		for i, rawUserIDs := range event.Assignments {
+			for _, rawUserID := range rawUserIDs {
+				participantID, err := uuid.Parse(rawUserID)
+				if err != nil {
+					s.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "invalid user_id"})
+					return nil, false
+				}
+
+				participant, ok := participants[participantID]
+				if !ok || assigned[participantID] {
+					s.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "user is not in channel or assigned twice"})
+					return nil, false
+				}
+
+				assigned[participantID] = true
+				assignments[i] = append(assignments[i], participant)
+			}
+		}
+
+		return assignments, true
+	}
+
+	if event.Rooms < 1 || event.Rooms > maxBreakoutRooms {
+		s.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "invalid number of breakout rooms"})
+		return nil, false
+	}
+
+	if len(participants) == 0 {
+		s.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "no participants to split"})
+		return nil, false
+	}
+
+	shuffled := make([]runtime.ActiveUser, 0, len(participants))
+	for _, participant := range participants {
+		shuffled = append(shuffled, participant)
+	}
+
+	rand.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+
+	assignments := make([][]runtime.ActiveUser, event.Rooms)
+	for i, participant := range shuffled {
+		assignments[i%event.Rooms] = append(assignments[i%event.Rooms], participant)
+	}
+
+	return assignments, true
+}
+
+// moveToRoom переводит участника в комнату или обратно в канал без переподключения.
+// Шепот и слово в ptt сбрасываются, при возвращении восстанавливаются ограничения канала.
+// false - участник уже ушел, и переводить некого
+func (s *signalingUsecase) moveToRoom(
+	ctx context.Context,
+	peer *domain.Peer,
+	roomID uuid.UUID,
+	parent *models.Channel,
+) bool {
+	if err := s.stopWhisper(ctx, peer); err != nil {
+		slog.Error("stop whisper on room change", slog.Any(constant.Error, err), slog.Any(constant.UserID, peer.UserID))
+	}
+
+	s.floorUsecase.Forget(peer)
+	s.dropWhisperTarget(peer.UserID)
+
+	returning := roomID == parent.ID
+
+	// Под блокировкой сцены, как в updateStageUser: смена роли или leave не должны потеряться
+	// или вернуть ушедшего в список
+	unlock := s.stageLocks.lock(peer.Room())
+
+	activeUser, ok := s.activeUserRepo.GetByID(ctx, peer.UserID)
+	if !ok {
+		unlock()
+		return false
+	}
+
+	activeUser.ChannelID = roomID
+	activeUser.HandRaisedAt = nil
+	activeUser.ParentChannelID = nil
+	if !returning {
+		activeUser.ParentChannelID = &parent.ID
+	}
+
+	s.activeUserRepo.Add(ctx, activeUser)
+	s.peerUsecase.MoveToRoom(peer, roomID)
+
+	listenOnly := false
+	if returning {
+		switch parent.Type {
+		case models.ChannelTypeStage:
+			listenOnly = activeUser.IsListener()
+		case models.ChannelTypePTT:
+			listenOnly = true
+		}
+	}
+
+	peer.ListenOnly.Store(listenOnly)
+	unlock()
+
+	roomEvent := events.RoomChangedEvent{ChannelID: roomID.String()}
+	if !returning {
+		roomEvent.ParentChannelID = parent.ID.String()
+	}
+
+	data, err := json.Marshal(roomEvent)
+	if err != nil {
+		slog.Error("marshal room changed event", slog.Any(constant.Error, err))
+		return true
+	}
+
+	s.wsRepo.Write(peer.UserID, events.Message{Type: "room_changed", Data: data})
+
+	s.sendChannelWhispers(peer)
+
+	return true
+}
+
+// runBreakout рассылает обратный отсчет и завершает сессию по времени
+func (s *signalingUsecase) runBreakout(ctx context.Context, channelID uuid.UUID, endsAt time.Time) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			secondsLeft := int(time.Until(endsAt).Round(time.Second).Seconds())
+
+			if secondsLeft <= 0 {
+				s.endBreakout(context.Background(), channelID)
+				return
+			}
+
+			if !slices.Contains(breakoutCountdownMarks, secondsLeft) {
+				continue
+			}
+
+			err := s.writeToCall(channelID, "breakout_countdown", events.BreakoutCountdownEvent{
+				ChannelID:   channelID.String(),
+				SecondsLeft: secondsLeft,
+			})
+			if err != nil {
+				slog.Error("broadcast breakout countdown", slog.Any(constant.Error, err), slog.Any(constant.ChannelID, channelID))
+			}
+		}
+	}
+}
+
+// endBreakout возвращает всех в канал и удаляет комнаты. false - сессии не было
+func (s *signalingUsecase) endBreakout(ctx context.Context, channelID uuid.UUID) bool {
+	s.breakoutMu.Lock()
+	session, ok := s.breakouts[channelID]
+	if !ok || session.cancel == nil {
+		s.breakoutMu.Unlock()
+		return false
+	}
+	delete(s.breakouts, channelID)
+	s.breakoutMu.Unlock()
+
+	session.cancel()
+
+	parent, err := s.channelRepo.GetByID(ctx, channelID)
+	if err != nil {
+		// Канал удалили во время сессии: участники возвращаются без его ограничений
+		slog.Error("get breakout parent channel", slog.Any(constant.Error, err), slog.Any(constant.ChannelID, channelID))
+		parent = &models.Channel{ID: channelID, Type: models.ChannelTypeVoice}
+	}
+
+	for _, room := range session.rooms {
+		// Ушедших во время сессии moveToRoom пропускает, иначе они вернулись бы в список призраками
+		for _, peer := range s.pcRepo.GetInRoom(room.ID) {
+			s.moveToRoom(ctx, peer, channelID, parent)
+		}
+	}
+
+	s.deleteBreakoutRooms(ctx, session.rooms)
+
+	if err = s.writeToCall(channelID, "breakout_ended", events.BreakoutEndedEvent{ChannelID: channelID.String()}); err != nil {
+		slog.Error("broadcast breakout ended", slog.Any(constant.Error, err), slog.Any(constant.ChannelID, channelID))
+	}
+
+	if err = s.BroadcastActiveMembers(ctx, channelID); err != nil {
+		slog.Error("broadcast active members after breakout", slog.Any(constant.Error, err), slog.Any(constant.ChannelID, channelID))
+	}
+
+	return true
+}
+
+// closeBreakouts при остановке ноды удаляет комнаты всех сессий, участники и так отключаются
+func (s *signalingUsecase) closeBreakouts(ctx context.Context) {
+	s.breakoutMu.Lock()
+	sessions := s.breakouts
+	s.breakouts = make(map[uuid.UUID]*breakoutSession)
+	s.breakoutMu.Unlock()
+
+	for _, session := range sessions {
+		if session.cancel != nil {
+			session.cancel()
+		}
+
+		s.deleteBreakoutRooms(ctx, session.rooms)
+	}
+}
+
+func (s *signalingUsecase) deleteBreakoutRooms(ctx context.Context, rooms []*models.Channel) {
+	var errs []error
+	for _, room := range rooms {
+		errs = append(errs, s.channelRepo.Delete(ctx, room.ID))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		slog.Error("delete breakout rooms", slog.Any(constant.Error, err))
+	}
+}
+
+// writeToCall отправляет событие всем участникам звонка на этой ноде, включая ушедших в комнаты
+func (s *signalingUsecase) writeToCall(channelID uuid.UUID, messageType string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("marshal %s event: %w", messageType, err)
+	}
+
+	for _, peer := range s.pcRepo.GetInChannel(channelID) {
+		s.wsRepo.Write(peer.UserID, events.Message{Type: messageType, Data: data})
+	}
+
+	return nil
+}
+
+func breakoutRoomIDs(rooms []*models.Channel) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(rooms))
+	for _, room := range rooms {
+		ids = append(ids, room.ID)
+	}
+
+	return ids
+}
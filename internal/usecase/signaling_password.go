@@ -0,0 +1,107 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/qrave1/RoomSpeak/internal/application/constant"
+	"github.com/qrave1/RoomSpeak/internal/domain/models"
+)
+
+// Коды ошибок входа в канал с паролем
+const (
+	ErrorCodePasswordRequired = "password_required"
+	ErrorCodeInvalidPassword  = "invalid_password"
+	ErrorCodeTooManyAttempts  = "too_many_attempts"
+)
+
+// После maxPasswordAttempts неверных паролей пользователя вход в канал закрыт для него до конца окна.
+// maxChannelPasswordAttempts ограничивает перебор пароля канала с многих аккаунтов
+const (
+	maxPasswordAttempts        = 5
+	maxChannelPasswordAttempts = 50
+	passwordAttemptWindow      = time.Minute
+)
+
+// isChannelInsider - модератор или участник канала. Им не нужен пароль публичного канала,
+// остальные попадают в канал с паролем или залом ожидания только через join
+func (s *signalingUsecase) isChannelInsider(ctx context.Context, userID uuid.UUID, channel *models.Channel) (bool, error) {
+	if channel.IsModerator(userID) {
+		return true, nil
+	}
+
+	memberIDs, err := s.channelRepo.GetMemberIDs(ctx, channel.ID)
+	if err != nil {
+		return false, fmt.Errorf("get channel members: %w", err)
+	}
+
+	return slices.Contains(memberIDs, userID), nil
+}
+
+// checkChannelPassword проверяет пароль из join. При отказе клиент получает ошибку с кодом
+func (s *signalingUsecase) checkChannelPassword(ctx context.Context, userID uuid.UUID, channel *models.Channel, password string) bool {
+	if password == "" {
+		s.wsRepo.Write(userID, map[string]any{
+			"type":       constant.Error,
+			"code":       ErrorCodePasswordRequired,
+			"message":    "channel password is required",
+			"channel_id": channel.ID,
+		})
+
+		return false
+	}
+
+	userKey := "user:" + userID.String() + ":channel:" + channel.ID.String()
+
+	attemptsLeft, retryAfter, ok := s.reservePasswordAttempt(ctx, userKey, channel.ID)
+	if !ok {
+		s.wsRepo.Write(userID, map[string]any{
+			"type":        constant.Error,
+			"code":        ErrorCodeTooManyAttempts,
+			"message":     "too many password attempts",
+			"channel_id":  channel.ID,
+			"retry_after": int(math.Ceil(retryAfter.Seconds())),
+		})
+
+		return false
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(channel.PasswordHash), []byte(password)); err != nil {
+		s.wsRepo.Write(userID, map[string]any{
+			"type":          constant.Error,
+			"code":          ErrorCodeInvalidPassword,
+			"message":       "invalid channel password",
+			"channel_id":    channel.ID,
+			"attempts_left": attemptsLeft,
+		})
+
+		return false
+	}
+
+	s.passwordRepo.Reset(ctx, userKey)
+
+	return true
+}
+
+// reservePasswordAttempt занимает попытку до сравнения пароля, чтобы параллельные join
+// не проверили больше maxPasswordAttempts паролей. Верный пароль сбрасывает счетчик пользователя,
+// но не канала. Возвращает, сколько попыток останется при неверном пароле, или сколько ждать следующей
+func (s *signalingUsecase) reservePasswordAttempt(ctx context.Context, userKey string, channelID uuid.UUID) (int, time.Duration, bool) {
+	attempts, resetIn := s.passwordRepo.Reserve(ctx, userKey, passwordAttemptWindow)
+	if attempts > maxPasswordAttempts {
+		return 0, resetIn, false
+	}
+
+	channelAttempts, channelResetIn := s.passwordRepo.Reserve(ctx, "channel:"+channelID.String(), passwordAttemptWindow)
+	if channelAttempts > maxChannelPasswordAttempts {
+		return 0, channelResetIn, false
+	}
+
+	return maxPasswordAttempts - attempts, 0, true
+}
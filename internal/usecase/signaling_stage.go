@@ -0,0 +1,301 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/qrave1/RoomSpeak/internal/application/constant"
+	"github.com/qrave1/RoomSpeak/internal/domain/events"
+	"github.com/qrave1/RoomSpeak/internal/domain/models"
+	"github.com/qrave1/RoomSpeak/internal/domain/runtime"
+)
+
+func (s *signalingUsecase) HandleRaiseHand(ctx context.Context, userID uuid.UUID) error {
+	channel, ok, err := s.stageChannel(ctx, userID)
+	if err != nil || !ok {
+		return err
+	}
+
+	activeUser, ok := s.activeUserRepo.GetByID(ctx, userID)
+	if !ok || !activeUser.IsListener() {
+		s.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "only listeners can raise hand"})
+		return nil
+	}
+
+	_, changed := s.updateStageUser(ctx, channel.ID, userID, func(target *runtime.ActiveUser) bool {
+		if !target.IsListener() || target.HandRaisedAt != nil {
+			return false
+		}
+
+		now := time.Now()
+		target.HandRaisedAt = &now
+
+		return true
+	})
+	if !changed {
+		return nil
+	}
+
+	return s.broadcastStage(ctx, channel.ID)
+}
+
+func (s *signalingUsecase) HandleLowerHand(ctx context.Context, userID uuid.UUID, event events.StageUserEvent) error {
+	channel, ok, err := s.stageChannel(ctx, userID)
+	if err != nil || !ok {
+		return err
+	}
+
+	target, ok := s.stageTarget(ctx, userID, channel.ID, event.UserID)
+	if !ok {
+		return nil
+	}
+
+	if target.ID != userID && !channel.IsModerator(userID) {
+		s.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "only moderator can lower other hands"})
+		return nil
+	}
+
+	_, changed := s.updateStageUser(ctx, channel.ID, target.ID, func(target *runtime.ActiveUser) bool {
+		if target.HandRaisedAt == nil {
+			return false
+		}
+
+		target.HandRaisedAt = nil
+
+		return true
+	})
+	if !changed {
+		return nil
+	}
+
+	return s.broadcastStage(ctx, channel.ID)
+}
+
+func (s *signalingUsecase) HandleApproveSpeaker(ctx context.Context, userID uuid.UUID, event events.StageUserEvent) error {
+	channel, ok, err := s.stageChannel(ctx, userID)
+	if err != nil || !ok {
+		return err
+	}
+
+	if !channel.IsModerator(userID) {
+		s.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "only moderator can approve speakers"})
+		return nil
+	}
+
+	target, ok := s.stageTarget(ctx, userID, channel.ID, event.UserID)
+	if !ok {
+		return nil
+	}
+
+	return s.setStageRole(ctx, channel.ID, target.ID, runtime.StageRoleSpeaker)
+}
+
+func (s *signalingUsecase) HandleMoveToAudience(ctx context.Context, userID uuid.UUID, event events.StageUserEvent) error {
+	channel, ok, err := s.stageChannel(ctx, userID)
+	if err != nil || !ok {
+		return err
+	}
+
+	target, ok := s.stageTarget(ctx, userID, channel.ID, event.UserID)
+	if !ok {
+		return nil
+	}
+
+	if target.ID != userID && !channel.IsModerator(userID) {
+		s.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "only moderator can move speakers to audience"})
+		return nil
+	}
+
+	return s.setStageRole(ctx, channel.ID, target.ID, runtime.StageRoleListener)
+}
+
+// stageChannel возвращает stage канал, в котором звонит пользователь.
+// Для обычного канала клиент получает ошибку, а ok - false
+func (s *signalingUsecase) stageChannel(ctx context.Context, userID uuid.UUID) (*models.Channel, bool, error) {
+	peer, ok := s.pcRepo.Get(userID)
+	if !ok {
+		return nil, false, fmt.Errorf("peer connection not found")
+	}
+
+	channel, err := s.channelRepo.GetByID(ctx, peer.Room())
+	if err != nil {
+		return nil, false, fmt.Errorf("get channel: %w", err)
+	}
+
+	if channel.Type != models.ChannelTypeStage {
+		s.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "channel is not a stage"})
+		return nil, false, nil
+	}
+
+	return channel, true, nil
+}
+
+// stageTarget находит участника канала, над которым выполняется действие. Пустой ID - сам пользователь
+func (s *signalingUsecase) stageTarget(ctx context.Context, userID, channelID uuid.UUID, rawTargetID string) (runtime.ActiveUser, bool) {
+	targetID := userID
+	if rawTargetID != "" {
+		parsed, err := uuid.Parse(rawTargetID)
+		if err != nil {
+			s.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "invalid user_id"})
+			return runtime.ActiveUser{}, false
+		}
+
+		targetID = parsed
+	}
+
+	target, ok := s.activeUserRepo.GetByID(ctx, targetID)
+	if !ok || target.ChannelID != channelID {
+		s.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "user is not in channel"})
+		return runtime.ActiveUser{}, false
+	}
+
+	return target, true
+}
+
+// setStageRole меняет роль участника и сразу включает или выключает пересылку его звука
+func (s *signalingUsecase) setStageRole(ctx context.Context, channelID, targetID uuid.UUID, role runtime.StageRole) error {
+	_, changed := s.updateStageUser(ctx, channelID, targetID, func(target *runtime.ActiveUser) bool {
+		if target.Role == role {
+			return false
+		}
+
+		target.Role = role
+		target.HandRaisedAt = nil
+
+		return true
+	})
+	if !changed {
+		return nil
+	}
+
+	roleEvent, err := json.Marshal(events.StageRoleEvent{
+		UserID: targetID.String(),
+		Role:   string(role),
+	})
+	if err != nil {
+		return fmt.Errorf("marshal stage role event: %w", err)
+	}
+
+	for _, activeUser := range s.activeUserRepo.GetInChannel(ctx, channelID) {
+		s.wsRepo.Write(activeUser.ID, events.Message{Type: "stage_role", Data: roleEvent})
+	}
+
+	return s.broadcastStage(ctx, channelID)
+}
+
+// updateStageUser атомарно для канала читает участника сцены, меняет его через update и
+// сохраняет. Пересылка звука выставляется по сохраненной роли, поэтому параллельные
+// raise_hand и approve_speaker не расходятся с ListenOnly пира. false - участник ушел или не изменился
+func (s *signalingUsecase) updateStageUser(
+	ctx context.Context,
+	channelID, userID uuid.UUID,
+	update func(target *runtime.ActiveUser) bool,
+) (runtime.ActiveUser, bool) {
+	unlock := s.stageLocks.lock(channelID)
+	defer unlock()
+
+	target, ok := s.activeUserRepo.GetByID(ctx, userID)
+	if !ok || target.ChannelID != channelID || !update(&target) {
+		return target, false
+	}
+
+	s.activeUserRepo.Add(ctx, target)
+
+	// При каскаде пир участника может быть на другой ноде, она применит роль сама
+	if peer, ok := s.pcRepo.Get(target.ID); ok && peer.Room() == channelID {
+		peer.ListenOnly.Store(target.IsListener())
+	} else {
+		s.activeUserRepo.NotifyChanged(ctx, target)
+	}
+
+	return target, true
+}
+
+func (s *signalingUsecase) RunStageSync(ctx context.Context) {
+	s.activeUserRepo.WatchChanged(ctx, func(userID, channelID uuid.UUID) {
+		peer, ok := s.pcRepo.Get(userID)
+		if !ok || peer.Room() != channelID {
+			return
+		}
+
+		// Роль перечитывается: уведомление могло отстать от следующего изменения
+		unlock := s.stageLocks.lock(channelID)
+		defer unlock()
+
+		if target, ok := s.activeUserRepo.GetByID(ctx, userID); ok && target.ChannelID == channelID && peer.Room() == channelID {
+			peer.ListenOnly.Store(target.IsListener())
+		}
+	})
+}
+
+// broadcastStage рассылает участникам очередь рук и обновленный список участников
+func (s *signalingUsecase) broadcastStage(ctx context.Context, channelID uuid.UUID) error {
+	if err := s.broadcastHandQueue(ctx, channelID); err != nil {
+		return fmt.Errorf("broadcast hand queue: %w", err)
+	}
+
+	if err := s.BroadcastActiveMembers(ctx, channelID); err != nil {
+		return fmt.Errorf("broadcast active members: %w", err)
+	}
+
+	return nil
+}
+
+func (s *signalingUsecase) broadcastHandQueue(ctx context.Context, channelID uuid.UUID) error {
+	queueEvent, err := s.handQueueEvent(ctx, channelID)
+	if err != nil {
+		return err
+	}
+
+	for _, activeUser := range s.activeUserRepo.GetInChannel(ctx, channelID) {
+		s.wsRepo.Write(activeUser.ID, events.Message{Type: "hand_queue", Data: queueEvent})
+	}
+
+	return nil
+}
+
+// sendHandQueue отправляет очередь рук только что вошедшему участнику
+func (s *signalingUsecase) sendHandQueue(ctx context.Context, channelID, userID uuid.UUID) error {
+	queueEvent, err := s.handQueueEvent(ctx, channelID)
+	if err != nil {
+		return err
+	}
+
+	s.wsRepo.Write(userID, events.Message{Type: "hand_queue", Data: queueEvent})
+
+	return nil
+}
+
+func (s *signalingUsecase) handQueueEvent(ctx context.Context, channelID uuid.UUID) (json.RawMessage, error) {
+	queue := make([]events.HandQueueEntry, 0)
+
+	for _, activeUser := range s.activeUserRepo.GetInChannel(ctx, channelID) {
+		if activeUser.HandRaisedAt == nil {
+			continue
+		}
+
+		queue = append(queue, events.HandQueueEntry{
+			UserID:   activeUser.ID.String(),
+			RaisedAt: *activeUser.HandRaisedAt,
+		})
+	}
+
+	slices.SortFunc(queue, func(a, b events.HandQueueEntry) int {
+		return a.RaisedAt.Compare(b.RaisedAt)
+	})
+
+	queueEvent, err := json.Marshal(events.HandQueueEvent{
+		ChannelID: channelID.String(),
+		Queue:     queue,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal hand queue event: %w", err)
+	}
+
+	return queueEvent, nil
+}
@@ -0,0 +1,238 @@
+package usecase
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pion/webrtc/v4"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/qrave1/RoomSpeak/internal/application/tracing"
+	"github.com/qrave1/RoomSpeak/internal/domain/events"
+)
+
+// tracedSignalingUsecase оборачивает каждый вызов SignalingUsecase в спан
+type tracedSignalingUsecase struct {
+	next SignalingUsecase
+}
+
+func NewTracedSignalingUsecase(next SignalingUsecase) SignalingUsecase {
+	return &tracedSignalingUsecase{next: next}
+}
+
+func startSignalingSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracing.Start(ctx, "SignalingUsecase."+name, trace.WithAttributes(attrs...))
+}
+
+func (t *tracedSignalingUsecase) BroadcastActiveMembers(ctx context.Context, channelID uuid.UUID) (err error) {
+	ctx, span := startSignalingSpan(ctx, "BroadcastActiveMembers", attribute.String("channel.id", channelID.String()))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.BroadcastActiveMembers(ctx, channelID)
+}
+
+func (t *tracedSignalingUsecase) HandleJoin(ctx context.Context, userID uuid.UUID, event events.JoinEvent) (err error) {
+	ctx, span := startSignalingSpan(ctx, "HandleJoin",
+		attribute.String("user.id", userID.String()),
+		attribute.String("channel.id", event.ChannelID),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.HandleJoin(ctx, userID, event)
+}
+
+func (t *tracedSignalingUsecase) HandleLeave(ctx context.Context, userID uuid.UUID) (err error) {
+	ctx, span := startSignalingSpan(ctx, "HandleLeave", attribute.String("user.id", userID.String()))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.HandleLeave(ctx, userID)
+}
+
+func (t *tracedSignalingUsecase) HandleOffer(ctx context.Context, userID uuid.UUID, sdp string) (err error) {
+	ctx, span := startSignalingSpan(ctx, "HandleOffer", attribute.String("user.id", userID.String()))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.HandleOffer(ctx, userID, sdp)
+}
+
+func (t *tracedSignalingUsecase) HandleAnswer(ctx context.Context, userID uuid.UUID, sdp string) (err error) {
+	ctx, span := startSignalingSpan(ctx, "HandleAnswer", attribute.String("user.id", userID.String()))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.HandleAnswer(ctx, userID, sdp)
+}
+
+func (t *tracedSignalingUsecase) HandleCandidate(ctx context.Context, userID uuid.UUID, candidate webrtc.ICECandidateInit) (err error) {
+	ctx, span := startSignalingSpan(ctx, "HandleCandidate", attribute.String("user.id", userID.String()))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.HandleCandidate(ctx, userID, candidate)
+}
+
+func (t *tracedSignalingUsecase) HandlePing(ctx context.Context, userID uuid.UUID, event events.PingEvent) {
+	ctx, span := startSignalingSpan(ctx, "HandlePing", attribute.String("user.id", userID.String()))
+	defer span.End()
+
+	t.next.HandlePing(ctx, userID, event)
+}
+
+func (t *tracedSignalingUsecase) HandleMute(ctx context.Context, userID uuid.UUID, isMuted bool) (err error) {
+	ctx, span := startSignalingSpan(ctx, "HandleMute",
+		attribute.String("user.id", userID.String()),
+		attribute.Bool("muted", isMuted),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.HandleMute(ctx, userID, isMuted)
+}
+
+func (t *tracedSignalingUsecase) HandleTrackMeta(ctx context.Context, userID uuid.UUID, event events.TrackMetaEvent) (err error) {
+	ctx, span := startSignalingSpan(ctx, "HandleTrackMeta",
+		attribute.String("user.id", userID.String()),
+		attribute.String("track.source", event.Source),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.HandleTrackMeta(ctx, userID, event)
+}
+
+func (t *tracedSignalingUsecase) HandleVideoConstraints(ctx context.Context, userID uuid.UUID, event events.VideoConstraintsEvent) (err error) {
+	ctx, span := startSignalingSpan(ctx, "HandleVideoConstraints",
+		attribute.String("user.id", userID.String()),
+		attribute.Int("bandwidth_kbps", event.BandwidthKbps),
+		attribute.Int("viewports", len(event.Viewports)),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.HandleVideoConstraints(ctx, userID, event)
+}
+
+func (t *tracedSignalingUsecase) HandleRaiseHand(ctx context.Context, userID uuid.UUID) (err error) {
+	ctx, span := startSignalingSpan(ctx, "HandleRaiseHand", attribute.String("user.id", userID.String()))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.HandleRaiseHand(ctx, userID)
+}
+
+func (t *tracedSignalingUsecase) HandleLowerHand(ctx context.Context, userID uuid.UUID, event events.StageUserEvent) (err error) {
+	ctx, span := startSignalingSpan(ctx, "HandleLowerHand",
+		attribute.String("user.id", userID.String()),
+		attribute.String("target.id", event.UserID),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.HandleLowerHand(ctx, userID, event)
+}
+
+func (t *tracedSignalingUsecase) HandleApproveSpeaker(ctx context.Context, userID uuid.UUID, event events.StageUserEvent) (err error) {
+	ctx, span := startSignalingSpan(ctx, "HandleApproveSpeaker",
+		attribute.String("user.id", userID.String()),
+		attribute.String("target.id", event.UserID),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.HandleApproveSpeaker(ctx, userID, event)
+}
+
+func (t *tracedSignalingUsecase) HandleMoveToAudience(ctx context.Context, userID uuid.UUID, event events.StageUserEvent) (err error) {
+	ctx, span := startSignalingSpan(ctx, "HandleMoveToAudience",
+		attribute.String("user.id", userID.String()),
+		attribute.String("target.id", event.UserID),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.HandleMoveToAudience(ctx, userID, event)
+}
+
+func (t *tracedSignalingUsecase) HandleWhisperStart(ctx context.Context, userID uuid.UUID, event events.WhisperStartEvent) (err error) {
+	ctx, span := startSignalingSpan(ctx, "HandleWhisperStart",
+		attribute.String("user.id", userID.String()),
+		attribute.Int("targets", len(event.UserIDs)),
+		attribute.String("target.channel.id", event.ChannelID),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.HandleWhisperStart(ctx, userID, event)
+}
+
+func (t *tracedSignalingUsecase) HandleWhisperStop(ctx context.Context, userID uuid.UUID) (err error) {
+	ctx, span := startSignalingSpan(ctx, "HandleWhisperStop", attribute.String("user.id", userID.String()))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.HandleWhisperStop(ctx, userID)
+}
+
+func (t *tracedSignalingUsecase) HandleBreakoutStart(ctx context.Context, userID uuid.UUID, event events.BreakoutStartEvent) (err error) {
+	ctx, span := startSignalingSpan(ctx, "HandleBreakoutStart",
+		attribute.String("user.id", userID.String()),
+		attribute.Int("rooms", max(event.Rooms, len(event.Assignments))),
+		attribute.Int("duration_seconds", event.DurationSeconds),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.HandleBreakoutStart(ctx, userID, event)
+}
+
+func (t *tracedSignalingUsecase) HandleBreakoutStop(ctx context.Context, userID uuid.UUID) (err error) {
+	ctx, span := startSignalingSpan(ctx, "HandleBreakoutStop", attribute.String("user.id", userID.String()))
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.HandleBreakoutStop(ctx, userID)
+}
+
+func (t *tracedSignalingUsecase) HandleAdmitUser(ctx context.Context, userID uuid.UUID, event events.WaitingUserEvent) (err error) {
+	ctx, span := startSignalingSpan(ctx, "HandleAdmitUser",
+		attribute.String("user.id", userID.String()),
+		attribute.String("channel.id", event.ChannelID),
+		attribute.String("target.id", event.UserID),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.HandleAdmitUser(ctx, userID, event)
+}
+
+func (t *tracedSignalingUsecase) HandleRejectUser(ctx context.Context, userID uuid.UUID, event events.WaitingUserEvent) (err error) {
+	ctx, span := startSignalingSpan(ctx, "HandleRejectUser",
+		attribute.String("user.id", userID.String()),
+		attribute.String("channel.id", event.ChannelID),
+		attribute.String("target.id", event.UserID),
+	)
+	defer func() { tracing.End(span, err) }()
+
+	return t.next.HandleRejectUser(ctx, userID, event)
+}
+
+func (t *tracedSignalingUsecase) LeaveWaitingRoom(ctx context.Context, userID uuid.UUID) bool {
+	ctx, span := startSignalingSpan(ctx, "LeaveWaitingRoom", attribute.String("user.id", userID.String()))
+	defer span.End()
+
+	return t.next.LeaveWaitingRoom(ctx, userID)
+}
+
+func (t *tracedSignalingUsecase) Drain(ctx context.Context, reconnectAfter time.Duration) {
+	ctx, span := startSignalingSpan(ctx, "Drain")
+	defer span.End()
+
+	t.next.Drain(ctx, reconnectAfter)
+}
+
+func (t *tracedSignalingUsecase) Draining() bool {
+	return t.next.Draining()
+}
+
+// RunBreakoutCleanup работает все время жизни ноды, спан на него не заводится
+func (t *tracedSignalingUsecase) RunBreakoutCleanup(ctx context.Context) {
+	t.next.RunBreakoutCleanup(ctx)
+}
+
+// RunStageSync работает все время жизни ноды, спан на него не заводится
+func (t *tracedSignalingUsecase) RunStageSync(ctx context.Context) {
+	t.next.RunStageSync(ctx)
+}
+
+// RunAdmissions работает все время жизни ноды, спан на него не заводится
+func (t *tracedSignalingUsecase) RunAdmissions(ctx context.Context) {
+	t.next.RunAdmissions(ctx)
+}
@@ -5,14 +5,22 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/pion/webrtc/v4"
 	"github.com/qrave1/RoomSpeak/internal/application/constant"
+	"github.com/qrave1/RoomSpeak/internal/application/metrics"
+	"github.com/qrave1/RoomSpeak/internal/domain"
 	"github.com/qrave1/RoomSpeak/internal/domain/events"
+	"github.com/qrave1/RoomSpeak/internal/domain/models"
 	"github.com/qrave1/RoomSpeak/internal/domain/runtime"
 	"github.com/qrave1/RoomSpeak/internal/infra/adapters/memory"
 	postrepo "github.com/qrave1/RoomSpeak/internal/infra/adapters/postgres/repository"
+	"github.com/qrave1/RoomSpeak/internal/infra/adapters/storage"
 )
 
 type SignalingUsecase interface {
@@ -25,19 +33,87 @@ type SignalingUsecase interface {
 	HandleAnswer(context.Context, uuid.UUID, string) error
 	HandleCandidate(context.Context, uuid.UUID, webrtc.ICECandidateInit) error
 
-	HandlePing(context.Context, uuid.UUID)
+	HandlePing(context.Context, uuid.UUID, events.PingEvent)
 	HandleMute(ctx context.Context, userID uuid.UUID, isMuted bool) error
+	HandleTrackMeta(ctx context.Context, userID uuid.UUID, event events.TrackMetaEvent) error
+	HandleVideoConstraints(ctx context.Context, userID uuid.UUID, event events.VideoConstraintsEvent) error
+
+	// HandleRaiseHand ставит слушателя stage канала в очередь на слово
+	HandleRaiseHand(ctx context.Context, userID uuid.UUID) error
+	// HandleLowerHand опускает свою руку или, для модератора, чужую
+	HandleLowerHand(ctx context.Context, userID uuid.UUID, event events.StageUserEvent) error
+	// HandleApproveSpeaker - модератор дает слушателю слово
+	HandleApproveSpeaker(ctx context.Context, userID uuid.UUID, event events.StageUserEvent) error
+	// HandleMoveToAudience возвращает спикера в слушатели: модератор любого, спикер себя
+	HandleMoveToAudience(ctx context.Context, userID uuid.UUID, event events.StageUserEvent) error
+
+	// HandleWhisperStart направляет голос выбранным участникам или в другой канал вместо своего
+	HandleWhisperStart(ctx context.Context, userID uuid.UUID, event events.WhisperStartEvent) error
+	// HandleWhisperStop возвращает голос в свой канал
+	HandleWhisperStop(ctx context.Context, userID uuid.UUID) error
+
+	// HandleBreakoutStart - модератор делит участников канала на временные комнаты
+	HandleBreakoutStart(ctx context.Context, userID uuid.UUID, event events.BreakoutStartEvent) error
+	// HandleBreakoutStop досрочно возвращает всех из комнат в канал
+	HandleBreakoutStop(ctx context.Context, userID uuid.UUID) error
+
+	// HandleAdmitUser - модератор впускает пользователя из зала ожидания
+	HandleAdmitUser(ctx context.Context, userID uuid.UUID, event events.WaitingUserEvent) error
+	// HandleRejectUser - модератор отклоняет запрос на вход
+	HandleRejectUser(ctx context.Context, userID uuid.UUID, event events.WaitingUserEvent) error
+	// LeaveWaitingRoom убирает отключившегося пользователя из зала ожидания. false - он там не ждал
+	LeaveWaitingRoom(ctx context.Context, userID uuid.UUID) bool
+
+	// Drain готовит ноду к остановке: новые join отклоняются, клиенты получают
+	// server_shutdown с подсказкой переподключения, пиры закрываются
+	Drain(ctx context.Context, reconnectAfter time.Duration)
+	// Draining - нода останавливается и не принимает новых пользователей
+	Draining() bool
+
+	// RunBreakoutCleanup удаляет комнаты breakout, пережившие свою сессию, при старте и
+	// затем периодически до отмены ctx. Так убираются комнаты упавших нод
+	RunBreakoutCleanup(ctx context.Context)
+
+	// RunStageSync применяет к пирам этой ноды роли на сцене, измененные с других нод, до отмены ctx
+	RunStageSync(ctx context.Context)
+
+	// RunAdmissions подключает пользователей этой ноды, которых впустили из зала ожидания
+	// с других нод, до отмены ctx
+	RunAdmissions(ctx context.Context)
 }
 
 type signalingUsecase struct {
 	channelRepo postrepo.ChannelRepository
 	userRepo    postrepo.UserRepository
 
-	pcRepo         memory.PeerConnectionRepository
-	wsRepo         memory.WebsocketConnectionRepository
-	activeUserRepo memory.ActiveUserRepository
-
-	peerUsecase PeerUsecase
+	pcRepo          memory.PeerConnectionRepository
+	wsRepo          memory.WebsocketConnectionRepository
+	activeUserRepo  memory.ActiveUserRepository
+	channelNodeRepo memory.ChannelNodeRepository
+	// pinnedNodeRepo закрепляет ptt каналы за одной нодой и при каскаде: слово и очередь
+	// FloorUsecase живут в памяти ноды, и у каждой ноды канала был бы свой говорящий
+	pinnedNodeRepo memory.ChannelNodeRepository
+	relayRepo      memory.ChannelRelayRepository
+	waitingRepo    memory.WaitingRoomRepository
+	passwordRepo   memory.PasswordAttemptRepository
+
+	avatarStorage storage.AvatarStorage
+
+	peerUsecase     PeerUsecase
+	presenceUsecase PresenceUsecase
+	qualityUsecase  QualityUsecase
+	floorUsecase    FloorUsecase
+
+	draining atomic.Bool
+
+	// stageLocks - блокировки по каналу для изменения ролей и рук на сцене
+	stageLocks channelLocks
+	// admissionLocks - блокировки по каналу для проверки max_users и входа
+	admissionLocks channelLocks
+
+	breakoutMu sync.Mutex
+	// breakouts - сессии breakout по родительскому каналу
+	breakouts map[uuid.UUID]*breakoutSession
 }
 
 func NewSignalingUsecase(
@@ -46,19 +122,45 @@ func NewSignalingUsecase(
 	pcRepo memory.PeerConnectionRepository,
 	wsRepo memory.WebsocketConnectionRepository,
 	activeUserRepo memory.ActiveUserRepository,
+	channelNodeRepo memory.ChannelNodeRepository,
+	pinnedNodeRepo memory.ChannelNodeRepository,
+	relayRepo memory.ChannelRelayRepository,
+	waitingRepo memory.WaitingRoomRepository,
+	passwordRepo memory.PasswordAttemptRepository,
+	avatarStorage storage.AvatarStorage,
 	peerUsecase PeerUsecase,
+	presenceUsecase PresenceUsecase,
+	qualityUsecase QualityUsecase,
+	floorUsecase FloorUsecase,
 ) SignalingUsecase {
 	return &signalingUsecase{
-		channelRepo:    channelRepo,
-		userRepo:       userRepo,
-		pcRepo:         pcRepo,
-		wsRepo:         wsRepo,
-		activeUserRepo: activeUserRepo,
-		peerUsecase:    peerUsecase,
+		channelRepo:     channelRepo,
+		userRepo:        userRepo,
+		pcRepo:          pcRepo,
+		wsRepo:          wsRepo,
+		activeUserRepo:  activeUserRepo,
+		channelNodeRepo: channelNodeRepo,
+		pinnedNodeRepo:  pinnedNodeRepo,
+		relayRepo:       relayRepo,
+		waitingRepo:     waitingRepo,
+		passwordRepo:    passwordRepo,
+		avatarStorage:   avatarStorage,
+		peerUsecase:     peerUsecase,
+
+		presenceUsecase: presenceUsecase,
+		qualityUsecase:  qualityUsecase,
+		floorUsecase:    floorUsecase,
+
+		breakouts: make(map[uuid.UUID]*breakoutSession),
 	}
 }
 
 func (s *signalingUsecase) HandleJoin(ctx context.Context, userID uuid.UUID, joinEvent events.JoinEvent) error {
+	if s.draining.Load() {
+		s.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "server is shutting down"})
+		return nil
+	}
+
 	if joinEvent.ChannelID == "" {
 		s.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "channel_id is required"})
 		return nil
@@ -71,32 +173,134 @@ func (s *signalingUsecase) HandleJoin(ctx context.Context, userID uuid.UUID, joi
 	}
 
 	// Проверяем, что канал существует в базе данных
-	_, err = s.channelRepo.GetByID(ctx, channelID)
+	channel, err := s.channelRepo.GetByID(ctx, channelID)
 	if err != nil {
 		slog.Error("get channel", slog.Any(constant.Error, err))
 		s.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "channel not found"})
 		return nil
 	}
 
-	peer, err := s.peerUsecase.CreateWebrtcPeer(ctx, userID, channelID)
+	// В комнаты breakout попадают только из родительского канала
+	if channel.IsBreakout() {
+		s.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "channel is a breakout room"})
+		return nil
+	}
+
+	// Медиа канала обслуживает одна нода, участников других нод отправляем туда
+	nodeRepo := s.channelNodeRepo
+	if channel.Type == models.ChannelTypePTT {
+		nodeRepo = s.pinnedNodeRepo
+	}
+
+	node, err := nodeRepo.Acquire(ctx, channelID)
 	if err != nil {
-		slog.Error("create peer connection", slog.Any(constant.Error, err))
+		return fmt.Errorf("acquire channel node: %w", err)
+	}
+
+	if node.ID != nodeRepo.Self().ID {
+		if node.PublicURL == "" {
+			s.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "channel is served by another node"})
+			return nil
+		}
 
+		s.wsRepo.Write(userID, map[string]any{"type": "redirect", "node_url": node.PublicURL, "channel_id": channelID})
 		return nil
 	}
 
-	s.pcRepo.Add(userID, peer)
+	// Модератор входит в свой канал всегда, остальные - если есть место и их впустили.
+	// Пароль публичного канала нужен только тем, кто не участник
+	if !channel.IsModerator(userID) {
+		if channel.HasPassword() {
+			insider, err := s.isChannelInsider(ctx, userID, channel)
+			if err != nil {
+				return err
+			}
+
+			if !insider && !s.checkChannelPassword(ctx, userID, channel, joinEvent.Password) {
+				return nil
+			}
+		}
+
+		if channel.IsFull(s.participants(ctx, channelID, userID)) {
+			s.writeChannelFull(userID, channel)
+			return nil
+		}
+
+		if channel.WaitingRoom {
+			return s.holdInWaitingRoom(ctx, userID, channel)
+		}
+	}
+
+	return s.join(ctx, userID, channel)
+}
+
+// join подключает пользователя к звонку канала на этой ноде
+func (s *signalingUsecase) join(ctx context.Context, userID uuid.UUID, channel *models.Channel) error {
+	channelID := channel.ID
+
+	// Место проверяется и занимается под одной блокировкой, иначе параллельные входы превысят max_users
+	unlock := s.admissionLocks.lock(channelID)
+	defer unlock()
+
+	if !channel.IsModerator(userID) && channel.IsFull(s.participants(ctx, channelID, userID)) {
+		s.writeChannelFull(userID, channel)
+		return nil
+	}
+
+	peer, err := s.peerUsecase.CreateWebrtcPeer(ctx, userID, channelID, channel.AudioProfile)
+	if err != nil {
+		slog.Error("create peer connection", slog.Any(constant.Error, err))
+
+		return nil
+	}
 
 	activeUser := runtime.ActiveUser{
 		ID:        userID,
 		ChannelID: channelID,
 	}
+
+	// На сцене сразу говорит только модератор, остальные входят слушателями
+	if channel.Type == models.ChannelTypeStage {
+		activeUser.Role = runtime.StageRoleListener
+		if channel.IsModerator(userID) {
+			activeUser.Role = runtime.StageRoleSpeaker
+		}
+
+		peer.ListenOnly.Store(activeUser.IsListener())
+	}
+
+	// В ptt канале звук идет только после ptt_start
+	if channel.Type == models.ChannelTypePTT {
+		peer.ListenOnly.Store(true)
+	}
+
+	// Вход в другой канал заменяет пир, шепоты прошлой комнаты ему больше не адресованы
+	s.dropWhisperTarget(userID)
+	s.pcRepo.Add(userID, peer)
+	s.relayRepo.Join(ctx, channelID)
+
+	s.updatePeerMetrics()
+
 	s.activeUserRepo.Add(ctx, activeUser)
+	s.sendChannelWhispers(peer)
 
 	if err = s.BroadcastActiveMembers(ctx, channelID); err != nil {
 		return fmt.Errorf("broadcast active members: %w", err)
 	}
 
+	switch channel.Type {
+	case models.ChannelTypeStage:
+		if err = s.sendHandQueue(ctx, channelID, userID); err != nil {
+			return fmt.Errorf("send hand queue: %w", err)
+		}
+	case models.ChannelTypePTT:
+		s.floorUsecase.SendState(channelID, userID)
+	}
+
+	if channel.IsModerator(userID) {
+		s.sendWaitingRoom(ctx, channel)
+	}
+
 	return nil
 }
 
@@ -107,17 +311,21 @@ func (s *signalingUsecase) BroadcastActiveMembers(ctx context.Context, channelID
 	participants := make([]events.ParticipantInfo, 0, len(activeUsers))
 
 	for _, activeUser := range activeUsers {
-		user, err := s.userRepo.GetUserByID(activeUser.ID)
+		user, err := s.userRepo.GetUserByID(ctx, activeUser.ID)
 		if err != nil {
 			continue // Skip users that can't be found
 		}
 
 		// Добавляем в новый детальный формат
 		participants = append(participants, events.ParticipantInfo{
-			ID:       activeUser.ID.String(),
-			Username: user.Username,
-			IsMuted:  false, // TODO: получать из состояния пользователя
-			IsOnline: true,
+			ID:          activeUser.ID.String(),
+			Username:    user.Username,
+			DisplayName: user.VisibleName(),
+			AvatarURL:   s.avatarStorage.URL(user.AvatarKey),
+			IsMuted:     false, // TODO: получать из состояния пользователя
+			IsOnline:    true,
+			Role:        string(activeUser.Role),
+			HandRaised:  activeUser.HandRaisedAt != nil,
 		})
 	}
 
@@ -138,17 +346,55 @@ func (s *signalingUsecase) BroadcastActiveMembers(ctx context.Context, channelID
 func (s *signalingUsecase) HandleLeave(ctx context.Context, userID uuid.UUID) error {
 	peer, ok := s.pcRepo.Get(userID)
 	if !ok {
+		// leave из зала ожидания отменяет запрос на вход
+		if s.LeaveWaitingRoom(ctx, userID) {
+			return nil
+		}
+
 		return fmt.Errorf("peer connection not found")
 	}
 
+	// Под блокировкой сцены, чтобы параллельная смена роли не вернула ушедшего в список
+	unlock := s.stageLocks.lock(peer.Room())
+	activeUser, _ := s.activeUserRepo.GetByID(ctx, userID)
 	s.activeUserRepo.Remove(ctx, userID)
+	unlock()
 
 	s.pcRepo.Remove(userID)
+	s.dropWhisperTarget(userID)
+
+	peer.UnsubscribeAll()
+	s.qualityUsecase.Forget(peer)
+	s.floorUsecase.Forget(peer)
+
+	if err := s.stopWhisper(ctx, peer); err != nil {
+		slog.Error("stop whisper on leave", slog.Any(constant.Error, err), slog.Any(constant.UserID, userID))
+	}
+
+	if err := peer.Conn.Close(); err != nil {
+		slog.Error("close peer connection", slog.Any(constant.Error, err), slog.Any(constant.UserID, userID))
+	}
 
-	if err := s.BroadcastActiveMembers(ctx, peer.ChannelID); err != nil {
+	s.updatePeerMetrics()
+
+	if len(s.pcRepo.GetInChannel(peer.ChannelID)) == 0 {
+		s.channelNodeRepo.Release(ctx, peer.ChannelID)
+		if s.pinnedNodeRepo != s.channelNodeRepo {
+			s.pinnedNodeRepo.Release(ctx, peer.ChannelID)
+		}
+		s.relayRepo.Leave(ctx, peer.ChannelID)
+	}
+
+	if err := s.BroadcastActiveMembers(ctx, peer.Room()); err != nil {
 		return fmt.Errorf("broadcast active members: %w", err)
 	}
 
+	if activeUser.HandRaisedAt != nil {
+		if err := s.broadcastHandQueue(ctx, peer.Room()); err != nil {
+			return fmt.Errorf("broadcast hand queue: %w", err)
+		}
+	}
+
 	return nil
 }
 
@@ -158,6 +404,26 @@ func (s *signalingUsecase) HandleOffer(ctx context.Context, userID uuid.UUID, of
 		return fmt.Errorf("peer connection not found")
 	}
 
+	// Кодек звука для клиента выбирается один раз, по первому offer
+	if !peer.Negotiated.Load() {
+		codec, err := domain.AudioCodecForOffer(offer, peer.AudioProfile)
+		if err != nil {
+			s.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "no supported audio codec"})
+			return nil
+		}
+
+		if err = peer.UseAudioCodec(codec); err != nil {
+			return fmt.Errorf("use audio codec: %w", err)
+		}
+	}
+
+	// Встречные offer: сервер уступает клиенту и откатывает свой, пересогласование повторится
+	if peer.Conn.SignalingState() == webrtc.SignalingStateHaveLocalOffer {
+		if err := peer.Conn.SetLocalDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeRollback}); err != nil {
+			return fmt.Errorf("rollback local offer: %w", err)
+		}
+	}
+
 	if err := peer.Conn.SetRemoteDescription(
 		webrtc.SessionDescription{
 			Type: webrtc.SDPTypeOffer,
@@ -178,6 +444,10 @@ func (s *signalingUsecase) HandleOffer(ctx context.Context, userID uuid.UUID, of
 
 	s.wsRepo.Write(userID, map[string]any{"type": "answer", "sdp": answer.SDP})
 
+	if !peer.Negotiated.Swap(true) {
+		s.peerUsecase.SubscribeExisting(peer)
+	}
+
 	return nil
 }
 
@@ -209,7 +479,9 @@ func (s *signalingUsecase) HandleCandidate(ctx context.Context, userID uuid.UUID
 	return nil
 }
 
-func (s *signalingUsecase) HandlePing(ctx context.Context, userID uuid.UUID) {
+func (s *signalingUsecase) HandlePing(ctx context.Context, userID uuid.UUID, pingEvent events.PingEvent) {
+	s.presenceUsecase.Heartbeat(ctx, userID, time.Duration(max(pingEvent.IdleSeconds, 0))*time.Second)
+
 	s.wsRepo.Write(userID, map[string]any{"type": "pong"})
 }
 
@@ -219,9 +491,9 @@ func (s *signalingUsecase) HandleMute(ctx context.Context, userID uuid.UUID, isM
 		return fmt.Errorf("peer connection not found")
 	}
 
-	activeUsers := s.activeUserRepo.GetInChannel(ctx, peer.ChannelID)
+	activeUsers := s.activeUserRepo.GetInChannel(ctx, peer.Room())
 
-	user, err := s.userRepo.GetUserByID(userID)
+	user, err := s.userRepo.GetUserByID(ctx, userID)
 	if err != nil {
 		return fmt.Errorf("get user from postgres: %w", err)
 	}
@@ -242,9 +514,103 @@ func (s *signalingUsecase) HandleMute(ctx context.Context, userID uuid.UUID, isM
 	}
 
 	// Отправляем обновленный список участников после изменения статуса микрофона
-	if err := s.BroadcastActiveMembers(ctx, peer.ChannelID); err != nil {
+	if err := s.BroadcastActiveMembers(ctx, peer.Room()); err != nil {
 		return fmt.Errorf("broadcast active members after mute: %w", err)
 	}
 
 	return nil
 }
+
+func (s *signalingUsecase) HandleTrackMeta(ctx context.Context, userID uuid.UUID, event events.TrackMetaEvent) error {
+	peer, ok := s.pcRepo.Get(userID)
+	if !ok {
+		return fmt.Errorf("peer connection not found")
+	}
+
+	source := domain.TrackSource(event.Source)
+	if event.TrackID == "" || !source.IsValid() {
+		s.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "invalid track metadata"})
+		return nil
+	}
+
+	s.peerUsecase.SetTrackSource(peer, event.TrackID, source)
+
+	return nil
+}
+
+func (s *signalingUsecase) HandleVideoConstraints(ctx context.Context, userID uuid.UUID, event events.VideoConstraintsEvent) error {
+	peer, ok := s.pcRepo.Get(userID)
+	if !ok {
+		return fmt.Errorf("peer connection not found")
+	}
+
+	constraints := domain.VideoConstraints{
+		BandwidthKbps: max(event.BandwidthKbps, 0),
+		Viewports:     make(map[string]int, len(event.Viewports)),
+	}
+
+	// Скрытые плитки (нулевой размер) не попадают в список и получают худший слой
+	for _, viewport := range event.Viewports {
+		if viewport.TrackID != "" && viewport.Height > 0 {
+			constraints.Viewports[viewport.TrackID] = viewport.Height
+		}
+	}
+
+	peer.SetVideoConstraints(constraints)
+	s.peerUsecase.UpdateLayers(peer)
+
+	return nil
+}
+
+func (s *signalingUsecase) Drain(ctx context.Context, reconnectAfter time.Duration) {
+	s.draining.Store(true)
+
+	channels := make(map[uuid.UUID]uuid.UUID)
+	for _, peer := range s.pcRepo.GetAll() {
+		channels[peer.UserID] = peer.ChannelID
+	}
+
+	for _, userID := range s.wsRepo.GetAllConnected() {
+		if !s.wsRepo.IsConnected(userID) {
+			continue
+		}
+
+		// Разброс задержки, чтобы клиенты не переподключались одновременно
+		delay := reconnectAfter
+		if reconnectAfter > 0 {
+			delay += rand.N(reconnectAfter)
+		}
+
+		event := map[string]any{"type": "server_shutdown", "reconnect_after_ms": delay.Milliseconds()}
+		if channelID, ok := channels[userID]; ok {
+			event["channel_id"] = channelID
+		}
+
+		s.wsRepo.Write(userID, event)
+	}
+
+	s.closeBreakouts(ctx)
+
+	for userID := range channels {
+		if err := s.HandleLeave(ctx, userID); err != nil {
+			slog.Error("leave channel on drain", slog.Any(constant.Error, err), slog.Any(constant.UserID, userID))
+		}
+	}
+}
+
+func (s *signalingUsecase) Draining() bool {
+	return s.draining.Load()
+}
+
+// updatePeerMetrics пересчитывает число пиров и каналов со звонком на этой ноде
+func (s *signalingUsecase) updatePeerMetrics() {
+	peers := s.pcRepo.GetAll()
+
+	channels := make(map[uuid.UUID]struct{}, len(peers))
+	for _, peer := range peers {
+		channels[peer.ChannelID] = struct{}{}
+	}
+
+	metrics.Peers.Set(float64(len(peers)))
+	metrics.ActiveChannels.Set(float64(len(channels)))
+}
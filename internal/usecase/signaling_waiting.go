@@ -0,0 +1,228 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/qrave1/RoomSpeak/internal/application/constant"
+	"github.com/qrave1/RoomSpeak/internal/domain/events"
+	"github.com/qrave1/RoomSpeak/internal/domain/models"
+)
+
+// ErrorCodeChannelFull - код ошибки входа в заполненный канал, клиент отличает ее без разбора текста
+const ErrorCodeChannelFull = "channel_full"
+
+func (s *signalingUsecase) HandleAdmitUser(ctx context.Context, userID uuid.UUID, event events.WaitingUserEvent) error {
+	channel, targetID, ok, err := s.waitingTarget(ctx, userID, event)
+	if err != nil || !ok {
+		return err
+	}
+
+	if channel.IsFull(s.participants(ctx, channel.ID, targetID)) {
+		s.writeChannelFull(userID, channel)
+		return nil
+	}
+
+	if !s.waitingRepo.Remove(ctx, channel.ID, targetID) {
+		s.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "user is not waiting"})
+		return nil
+	}
+
+	s.sendWaitingRoom(ctx, channel)
+
+	// Пир создается на ноде WebSocket пользователя, при каскаде это может быть другая нода
+	if !s.wsRepo.IsConnected(targetID) {
+		s.waitingRepo.Admit(ctx, channel.ID, targetID)
+		return nil
+	}
+
+	return s.admit(ctx, targetID, channel)
+}
+
+func (s *signalingUsecase) RunAdmissions(ctx context.Context) {
+	s.waitingRepo.WatchAdmitted(ctx, func(channelID, userID uuid.UUID) {
+		if !s.wsRepo.IsConnected(userID) {
+			return
+		}
+
+		channel, err := s.channelRepo.GetByID(ctx, channelID)
+		if err != nil {
+			slog.Error("get channel of admitted user", slog.Any(constant.Error, err), slog.Any(constant.ChannelID, channelID))
+			return
+		}
+
+		if err = s.admit(ctx, userID, channel); err != nil {
+			slog.Error("join admitted user", slog.Any(constant.Error, err), slog.Any(constant.UserID, userID))
+		}
+	})
+}
+
+// admit подключает впущенного модератором пользователя этой ноды
+func (s *signalingUsecase) admit(ctx context.Context, userID uuid.UUID, channel *models.Channel) error {
+	if err := s.writeJoinStatus(userID, channel.ID, "join_admitted"); err != nil {
+		return err
+	}
+
+	return s.join(ctx, userID, channel)
+}
+
+func (s *signalingUsecase) HandleRejectUser(ctx context.Context, userID uuid.UUID, event events.WaitingUserEvent) error {
+	channel, targetID, ok, err := s.waitingTarget(ctx, userID, event)
+	if err != nil || !ok {
+		return err
+	}
+
+	if !s.waitingRepo.Remove(ctx, channel.ID, targetID) {
+		s.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "user is not waiting"})
+		return nil
+	}
+
+	s.sendWaitingRoom(ctx, channel)
+
+	return s.writeJoinStatus(targetID, channel.ID, "join_rejected")
+}
+
+func (s *signalingUsecase) LeaveWaitingRoom(ctx context.Context, userID uuid.UUID) bool {
+	channelIDs := s.waitingRepo.ChannelsOf(ctx, userID)
+
+	for _, channelID := range channelIDs {
+		if !s.waitingRepo.Remove(ctx, channelID, userID) {
+			continue
+		}
+
+		channel, err := s.channelRepo.GetByID(ctx, channelID)
+		if err != nil {
+			slog.Error("get channel of waiting room", slog.Any(constant.Error, err), slog.Any(constant.ChannelID, channelID))
+			continue
+		}
+
+		s.sendWaitingRoom(ctx, channel)
+	}
+
+	return len(channelIDs) > 0
+}
+
+// holdInWaitingRoom ставит пользователя в очередь на вход и показывает очередь модератору
+func (s *signalingUsecase) holdInWaitingRoom(ctx context.Context, userID uuid.UUID, channel *models.Channel) error {
+	s.waitingRepo.Add(ctx, channel.ID, userID, time.Now())
+
+	if err := s.writeJoinStatus(userID, channel.ID, "join_waiting"); err != nil {
+		return err
+	}
+
+	s.sendWaitingRoom(ctx, channel)
+
+	return nil
+}
+
+// waitingTarget проверяет, что действие над залом ожидания выполняет модератор канала
+func (s *signalingUsecase) waitingTarget(
+	ctx context.Context,
+	userID uuid.UUID,
+	event events.WaitingUserEvent,
+) (*models.Channel, uuid.UUID, bool, error) {
+	channelID, err := uuid.Parse(event.ChannelID)
+	if err != nil {
+		s.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "invalid channel_id"})
+		return nil, uuid.Nil, false, nil
+	}
+
+	targetID, err := uuid.Parse(event.UserID)
+	if err != nil {
+		s.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "invalid user_id"})
+		return nil, uuid.Nil, false, nil
+	}
+
+	channel, err := s.channelRepo.GetByID(ctx, channelID)
+	if err != nil {
+		s.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "channel not found"})
+		return nil, uuid.Nil, false, nil
+	}
+
+	if !channel.IsModerator(userID) {
+		s.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "only moderator can manage waiting room"})
+		return nil, uuid.Nil, false, nil
+	}
+
+	return channel, targetID, true, nil
+}
+
+// sendWaitingRoom отправляет модератору текущую очередь зала ожидания
+func (s *signalingUsecase) sendWaitingRoom(ctx context.Context, channel *models.Channel) {
+	requested := s.waitingRepo.Get(ctx, channel.ID)
+
+	queue := make([]events.WaitingEntry, 0, len(requested))
+	for userID, requestedAt := range requested {
+		entry := events.WaitingEntry{UserID: userID.String(), RequestedAt: requestedAt}
+
+		if user, err := s.userRepo.GetUserByID(ctx, userID); err == nil {
+			entry.Username = user.Username
+			entry.DisplayName = user.VisibleName()
+		}
+
+		queue = append(queue, entry)
+	}
+
+	slices.SortFunc(queue, func(a, b events.WaitingEntry) int {
+		return a.RequestedAt.Compare(b.RequestedAt)
+	})
+
+	data, err := json.Marshal(events.WaitingRoomEvent{ChannelID: channel.ID.String(), Queue: queue})
+	if err != nil {
+		slog.Error("marshal waiting room event", slog.Any(constant.Error, err))
+		return
+	}
+
+	s.wsRepo.Write(channel.CreatorID, events.Message{Type: "waiting_room", Data: data})
+}
+
+func (s *signalingUsecase) writeJoinStatus(userID, channelID uuid.UUID, messageType string) error {
+	data, err := json.Marshal(events.JoinStatusEvent{ChannelID: channelID.String()})
+	if err != nil {
+		return fmt.Errorf("marshal join status event: %w", err)
+	}
+
+	s.wsRepo.Write(userID, events.Message{Type: messageType, Data: data})
+
+	return nil
+}
+
+// participants - сколько участников в звонке канала на всех нодах, включая комнаты breakout,
+// не считая userID. Переподключающийся пользователь заменяет свой пир и место не занимает
+func (s *signalingUsecase) participants(ctx context.Context, channelID, userID uuid.UUID) int {
+	roomIDs := []uuid.UUID{channelID}
+
+	s.breakoutMu.Lock()
+	if session, ok := s.breakouts[channelID]; ok {
+		roomIDs = append(roomIDs, breakoutRoomIDs(session.rooms)...)
+	}
+	s.breakoutMu.Unlock()
+
+	count := 0
+
+	for _, roomID := range roomIDs {
+		for _, activeUser := range s.activeUserRepo.GetInChannel(ctx, roomID) {
+			if activeUser.ID != userID {
+				count++
+			}
+		}
+	}
+
+	return count
+}
+
+func (s *signalingUsecase) writeChannelFull(userID uuid.UUID, channel *models.Channel) {
+	s.wsRepo.Write(userID, map[string]any{
+		"type":       constant.Error,
+		"code":       ErrorCodeChannelFull,
+		"message":    "channel is full",
+		"channel_id": channel.ID,
+		"max_users":  channel.MaxUsers,
+	})
+}
@@ -0,0 +1,263 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/google/uuid"
+
+	"github.com/qrave1/RoomSpeak/internal/application/constant"
+	"github.com/qrave1/RoomSpeak/internal/domain"
+	"github.com/qrave1/RoomSpeak/internal/domain/events"
+	"github.com/qrave1/RoomSpeak/internal/domain/models"
+)
+
+func (s *signalingUsecase) HandleWhisperStart(ctx context.Context, userID uuid.UUID, event events.WhisperStartEvent) error {
+	peer, ok := s.pcRepo.Get(userID)
+	if !ok {
+		return fmt.Errorf("peer connection not found")
+	}
+
+	if (len(event.UserIDs) == 0) == (event.ChannelID == "") {
+		s.wsRepo.Write(userID, map[string]any{"type": constant.Error, "message": "whisper needs either user_ids or channel_id"})
+		return nil
+	}
+
+	var (
+		route *domain.WhisperRoute
+		err   error
+	)
+
+	if event.ChannelID != "" {
+		route, err = s.channelWhisperRoute(ctx, peer, event.ChannelID)
+	} else {
+		route, err = s.usersWhisperRoute(ctx, peer, event.UserIDs)
+	}
+
+	if err != nil || route == nil {
+		return err
+	}
+
+	if previous := peer.SetWhisper(route); previous != nil {
+		s.releaseWhisperChannel(ctx, previous)
+
+		if err = s.notifyWhisper(peer, previous, "whisper_stopped"); err != nil {
+			return err
+		}
+	}
+
+	if err = s.notifyWhisper(peer, route, "whisper_started"); err != nil {
+		return err
+	}
+
+	// Вошедшие в канал, пока маршрут собирался, его еще не видели
+	if route.IsChannel() {
+		for _, target := range s.pcRepo.GetInRoom(route.ChannelID) {
+			s.addWhisperTarget(peer, route.ChannelID, target.UserID)
+		}
+	}
+
+	return nil
+}
+
+func (s *signalingUsecase) HandleWhisperStop(ctx context.Context, userID uuid.UUID) error {
+	peer, ok := s.pcRepo.Get(userID)
+	if !ok {
+		return fmt.Errorf("peer connection not found")
+	}
+
+	return s.stopWhisper(ctx, peer)
+}
+
+// stopWhisper возвращает голос пользователя в канал и сообщает об этом получателям шепота
+func (s *signalingUsecase) stopWhisper(ctx context.Context, peer *domain.Peer) error {
+	previous := peer.SetWhisper(nil)
+	if previous == nil {
+		return nil
+	}
+
+	s.releaseWhisperChannel(ctx, previous)
+
+	return s.notifyWhisper(peer, previous, "whisper_stopped")
+}
+
+// sendChannelWhispers сообщает вошедшему в комнату о шепоте в нее и добавляет его в получатели.
+// Без этого он слышал бы шепот, не получив whisper_started
+func (s *signalingUsecase) sendChannelWhispers(peer *domain.Peer) {
+	for _, whisperer := range s.pcRepo.GetAll() {
+		if route := whisperer.Whisper(); route != nil && route.ChannelID == peer.Room() {
+			s.addWhisperTarget(whisperer, route.ChannelID, peer.UserID)
+		}
+	}
+}
+
+// addWhisperTarget добавляет получателя в шепот whisperer в канал. Маршрут общий с медиа,
+// поэтому он не меняется на месте, а заменяется копией
+func (s *signalingUsecase) addWhisperTarget(whisperer *domain.Peer, channelID, userID uuid.UUID) {
+	if whisperer.UserID == userID {
+		return
+	}
+
+	for {
+		route := whisperer.Whisper()
+		if route == nil || route.ChannelID != channelID || route.Includes(userID) {
+			return
+		}
+
+		if !whisperer.ReplaceWhisper(route, route.WithTarget(userID)) {
+			continue
+		}
+
+		if err := s.notifyWhisper(whisperer, &domain.WhisperRoute{TargetIDs: []uuid.UUID{userID}}, "whisper_started"); err != nil {
+			slog.Error("notify late whisper target", slog.Any(constant.Error, err), slog.Any(constant.UserID, userID))
+		}
+
+		return
+	}
+}
+
+// dropWhisperTarget убирает пользователя, покинувшего комнату, из чужих шепотов и сообщает ему
+// whisper_stopped. Шепот без получателей не отменяется: голос не должен внезапно уйти в канал
+func (s *signalingUsecase) dropWhisperTarget(userID uuid.UUID) {
+	for _, whisperer := range s.pcRepo.GetAll() {
+		for {
+			route := whisperer.Whisper()
+			if route == nil || !route.Includes(userID) {
+				break
+			}
+
+			if !whisperer.ReplaceWhisper(route, route.WithoutTarget(userID)) {
+				continue
+			}
+
+			if err := s.notifyWhisper(whisperer, &domain.WhisperRoute{TargetIDs: []uuid.UUID{userID}}, "whisper_stopped"); err != nil {
+				slog.Error("notify dropped whisper target", slog.Any(constant.Error, err), slog.Any(constant.UserID, userID))
+			}
+
+			break
+		}
+	}
+}
+
+// releaseWhisperChannel отпускает канал, закрепленный за нодой ради шепота, если в нем так никто и не звонит
+func (s *signalingUsecase) releaseWhisperChannel(ctx context.Context, route *domain.WhisperRoute) {
+	if !route.IsChannel() || len(s.pcRepo.GetInChannel(route.ChannelID)) > 0 {
+		return
+	}
+
+	for _, whisperer := range s.pcRepo.GetAll() {
+		if current := whisperer.Whisper(); current != nil && current.ChannelID == route.ChannelID {
+			return
+		}
+	}
+
+	s.channelNodeRepo.Release(ctx, route.ChannelID)
+}
+
+// usersWhisperRoute - шепот выбранным участникам своего канала. Ушедшие и чужие участники пропускаются
+func (s *signalingUsecase) usersWhisperRoute(ctx context.Context, peer *domain.Peer, rawUserIDs []string) (*domain.WhisperRoute, error) {
+	route := &domain.WhisperRoute{}
+
+	for _, rawUserID := range rawUserIDs {
+		targetID, err := uuid.Parse(rawUserID)
+		if err != nil {
+			s.wsRepo.Write(peer.UserID, map[string]any{"type": constant.Error, "message": "invalid user_id"})
+			return nil, nil
+		}
+
+		if targetID == peer.UserID || route.Includes(targetID) {
+			continue
+		}
+
+		target, ok := s.activeUserRepo.GetByID(ctx, targetID)
+		if !ok || target.ChannelID != peer.Room() {
+			continue
+		}
+
+		route.TargetIDs = append(route.TargetIDs, targetID)
+	}
+
+	if len(route.TargetIDs) == 0 {
+		s.wsRepo.Write(peer.UserID, map[string]any{"type": constant.Error, "message": "no whisper targets in channel"})
+		return nil, nil
+	}
+
+	return route, nil
+}
+
+// channelWhisperRoute - шепот всем участникам другого канала, к которому у пользователя есть доступ
+func (s *signalingUsecase) channelWhisperRoute(ctx context.Context, peer *domain.Peer, rawChannelID string) (*domain.WhisperRoute, error) {
+	channelID, err := uuid.Parse(rawChannelID)
+	if err != nil || channelID == peer.Room() {
+		s.wsRepo.Write(peer.UserID, map[string]any{"type": constant.Error, "message": "invalid channel_id"})
+		return nil, nil
+	}
+
+	channel, err := s.channelRepo.GetByID(ctx, channelID)
+	if err != nil {
+		s.wsRepo.Write(peer.UserID, map[string]any{"type": constant.Error, "message": "channel not found"})
+		return nil, nil
+	}
+
+	if channel.IsBreakout() {
+		s.wsRepo.Write(peer.UserID, map[string]any{"type": constant.Error, "message": "channel is a breakout room"})
+		return nil, nil
+	}
+
+	// На сцене и в ptt говорят только получившие слово, шепот обошел бы эти правила
+	if channel.Type != models.ChannelTypeVoice {
+		s.wsRepo.Write(peer.UserID, map[string]any{"type": constant.Error, "message": "whisper is only allowed into voice channels"})
+		return nil, nil
+	}
+
+	// Шепот не проходит пароль и зал ожидания, поэтому в такие каналы шепчут только свои
+	if !channel.IsPublic || channel.HasPassword() || channel.WaitingRoom {
+		insider, err := s.isChannelInsider(ctx, peer.UserID, channel)
+		if err != nil {
+			return nil, err
+		}
+
+		if !insider {
+			s.wsRepo.Write(peer.UserID, map[string]any{"type": constant.Error, "message": "no access to channel"})
+			return nil, nil
+		}
+	}
+
+	// Звук шепота раздается только локально, поэтому канал должен звонить на этой ноде.
+	// Свободный канал закрепляется за ней, и вошедшие в него попадут сюда
+	node, err := s.channelNodeRepo.Acquire(ctx, channelID)
+	if err != nil {
+		return nil, fmt.Errorf("acquire channel node: %w", err)
+	}
+
+	if node.ID != s.channelNodeRepo.Self().ID {
+		s.wsRepo.Write(peer.UserID, map[string]any{"type": constant.Error, "message": "channel is served by another node"})
+		return nil, nil
+	}
+
+	route := &domain.WhisperRoute{ChannelID: channelID}
+	for _, target := range s.pcRepo.GetInRoom(channelID) {
+		route.TargetIDs = append(route.TargetIDs, target.UserID)
+	}
+
+	return route, nil
+}
+
+// notifyWhisper сообщает получателям шепота, кто начал или перестал им шептать
+func (s *signalingUsecase) notifyWhisper(peer *domain.Peer, route *domain.WhisperRoute, messageType string) error {
+	whisperEvent, err := json.Marshal(events.WhisperEvent{
+		UserID:    peer.UserID.String(),
+		ChannelID: peer.Room().String(),
+	})
+	if err != nil {
+		return fmt.Errorf("marshal whisper event: %w", err)
+	}
+
+	for _, targetID := range route.TargetIDs {
+		s.wsRepo.Write(targetID, events.Message{Type: messageType, Data: whisperEvent})
+	}
+
+	return nil
+}
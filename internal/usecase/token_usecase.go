@@ -0,0 +1,235 @@
+package usecase
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/qrave1/RoomSpeak/internal/application/constant"
+	"github.com/qrave1/RoomSpeak/internal/domain/input"
+	"github.com/qrave1/RoomSpeak/internal/domain/models"
+	"github.com/qrave1/RoomSpeak/internal/infra/adapters/postgres/repository"
+)
+
+var (
+	ErrInvalidToken  = errors.New("invalid api token")
+	ErrUnknownScope  = errors.New("unknown scope")
+	ErrNotTokenOwner = errors.New("user is not allowed to manage tokens of this account")
+	ErrScopeNotHeld  = errors.New("scope is not granted to the caller")
+	ErrTokenNotFound = errors.New("api token not found")
+	ErrBotNotFound   = errors.New("bot not found")
+	ErrUsernameTaken = errors.New("username is already taken")
+)
+
+// TokenUsecase управляет бот-аккаунтами и персональными API токенами
+type TokenUsecase interface {
+	// Боты
+	CreateBot(ctx context.Context, ownerID uuid.UUID, username string) (*models.User, error)
+	GetBots(ctx context.Context, ownerID uuid.UUID) ([]*models.User, error)
+
+	// Токены. Возвращает сам токен, он показывается только один раз.
+	// Новый токен получает не больше скоупов, чем actorScopes у создающего его запроса
+	CreateToken(ctx context.Context, actorID uuid.UUID, actorScopes models.Scopes, in *input.CreateTokenInput) (string, *models.APIToken, error)
+	ListTokens(ctx context.Context, actorID, userID uuid.UUID) ([]*models.APIToken, error)
+	RevokeToken(ctx context.Context, actorID, tokenID uuid.UUID) error
+
+	// AuthenticateToken проверяет токен из заголовка Authorization и отмечает его использование
+	AuthenticateToken(ctx context.Context, raw string) (*models.APIToken, error)
+	// SessionUserExists - не удален ли пользователь, которому выдан JWT
+	SessionUserExists(ctx context.Context, userID uuid.UUID) (bool, error)
+}
+
+type tokenUsecase struct {
+	userRepo  repository.UserRepository
+	tokenRepo repository.APITokenRepository
+}
+
+func NewTokenUsecase(userRepo repository.UserRepository, tokenRepo repository.APITokenRepository) TokenUsecase {
+	return &tokenUsecase{userRepo: userRepo, tokenRepo: tokenRepo}
+}
+
+func (uc *tokenUsecase) CreateBot(ctx context.Context, ownerID uuid.UUID, username string) (*models.User, error) {
+	owner, err := uc.userRepo.GetUserByID(ctx, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("get owner: %w", err)
+	}
+
+	// Боты не могут создавать других ботов
+	if owner.IsBot {
+		return nil, ErrNotTokenOwner
+	}
+
+	bot := models.NewBot(ownerID, username)
+
+	if err = uc.userRepo.CreateUser(ctx, bot); err != nil {
+		if errors.Is(err, repository.ErrUsernameTaken) {
+			return nil, ErrUsernameTaken
+		}
+
+		return nil, fmt.Errorf("create bot: %w", err)
+	}
+
+	return bot, nil
+}
+
+func (uc *tokenUsecase) GetBots(ctx context.Context, ownerID uuid.UUID) ([]*models.User, error) {
+	return uc.userRepo.GetBotsByOwner(ctx, ownerID)
+}
+
+func (uc *tokenUsecase) CreateToken(
+	ctx context.Context,
+	actorID uuid.UUID,
+	actorScopes models.Scopes,
+	in *input.CreateTokenInput,
+) (string, *models.APIToken, error) {
+	if err := uc.checkOwnership(ctx, actorID, in.UserID); err != nil {
+		return "", nil, err
+	}
+
+	// Без явных скоупов токен наследует скоупы создающего, иначе токен мог бы выпустить себе больше прав
+	scopes := models.Scopes(in.Scopes)
+	if len(scopes) == 0 {
+		scopes = slices.Clone(actorScopes)
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(models.AllScopes, scope) {
+			return "", nil, fmt.Errorf("%w: %s", ErrUnknownScope, scope)
+		}
+
+		if !slices.Contains(actorScopes, scope) {
+			return "", nil, fmt.Errorf("%w: %s", ErrScopeNotHeld, scope)
+		}
+	}
+
+	raw, err := generateRawToken()
+	if err != nil {
+		return "", nil, fmt.Errorf("generate token: %w", err)
+	}
+
+	token := &models.APIToken{
+		ID:        uuid.New(),
+		UserID:    in.UserID,
+		Name:      in.Name,
+		TokenHash: hashToken(raw),
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	}
+
+	if in.TTL > 0 {
+		expiresAt := token.CreatedAt.Add(in.TTL)
+		token.ExpiresAt = &expiresAt
+	}
+
+	if err = uc.tokenRepo.Create(ctx, token); err != nil {
+		return "", nil, fmt.Errorf("create token: %w", err)
+	}
+
+	return raw, token, nil
+}
+
+func (uc *tokenUsecase) ListTokens(ctx context.Context, actorID, userID uuid.UUID) ([]*models.APIToken, error) {
+	if err := uc.checkOwnership(ctx, actorID, userID); err != nil {
+		return nil, err
+	}
+
+	return uc.tokenRepo.ListByUser(ctx, userID)
+}
+
+func (uc *tokenUsecase) RevokeToken(ctx context.Context, actorID, tokenID uuid.UUID) error {
+	token, err := uc.tokenRepo.GetByID(ctx, tokenID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrTokenNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("get token: %w", err)
+	}
+
+	if err = uc.checkOwnership(ctx, actorID, token.UserID); err != nil {
+		return err
+	}
+
+	return uc.tokenRepo.Revoke(ctx, tokenID)
+}
+
+func (uc *tokenUsecase) AuthenticateToken(ctx context.Context, raw string) (*models.APIToken, error) {
+	if !strings.HasPrefix(raw, models.APITokenPrefix) {
+		return nil, ErrInvalidToken
+	}
+
+	token, err := uc.tokenRepo.GetByHash(ctx, hashToken(raw))
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	now := time.Now()
+	if !token.IsActive(now) {
+		return nil, ErrInvalidToken
+	}
+
+	if err = uc.tokenRepo.TouchLastUsed(ctx, token.ID, now); err != nil {
+		slog.Error("touch api token last used", slog.Any(constant.Error, err))
+	}
+
+	return token, nil
+}
+
+func (uc *tokenUsecase) SessionUserExists(ctx context.Context, userID uuid.UUID) (bool, error) {
+	_, err := uc.userRepo.GetUserByID(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("get user: %w", err)
+	}
+
+	return true, nil
+}
+
+// checkOwnership разрешает управлять токенами своего аккаунта и своих ботов
+func (uc *tokenUsecase) checkOwnership(ctx context.Context, actorID, userID uuid.UUID) error {
+	if actorID == userID {
+		return nil
+	}
+
+	user, err := uc.userRepo.GetUserByID(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrBotNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("get user: %w", err)
+	}
+
+	if !user.IsBot || user.OwnerID == nil || *user.OwnerID != actorID {
+		return ErrNotTokenOwner
+	}
+
+	return nil
+}
+
+func generateRawToken() (string, error) {
+	buf := make([]byte, 32)
+
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return models.APITokenPrefix + base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+
+	return hex.EncodeToString(sum[:])
+}
@@ -10,8 +10,10 @@ import (
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/qrave1/RoomSpeak/internal/domain/models"
+	"github.com/qrave1/RoomSpeak/internal/domain/runtime"
 	"github.com/qrave1/RoomSpeak/internal/infra/adapters/memory"
 	"github.com/qrave1/RoomSpeak/internal/infra/adapters/postgres/repository"
+	"github.com/qrave1/RoomSpeak/internal/infra/adapters/storage"
 )
 
 // UserUsecase определяет интерфейс для работы с пользователями
@@ -33,24 +35,37 @@ type UserUsecase interface {
 
 type userUsecase struct {
 	jwtSecret []byte
+	jwtTTL    time.Duration
 
 	userRepo    repository.UserRepository
 	channelRepo repository.ChannelRepository
 	wsRepo      memory.WebsocketConnectionRepository
+
+	avatarStorage storage.AvatarStorage
+
+	presenceUsecase PresenceUsecase
 }
 
 // NewUserUsecase создает новый экземпляр UserUsecase
 func NewUserUsecase(
 	jwtSecret []byte,
+	jwtTTL time.Duration,
 	userRepo repository.UserRepository,
 	channelRepo repository.ChannelRepository,
 	wsRepo memory.WebsocketConnectionRepository,
+	avatarStorage storage.AvatarStorage,
+	presenceUsecase PresenceUsecase,
 ) UserUsecase {
 	return &userUsecase{
 		jwtSecret:   jwtSecret,
+		jwtTTL:      jwtTTL,
 		userRepo:    userRepo,
 		channelRepo: channelRepo,
 		wsRepo:      wsRepo,
+
+		avatarStorage: avatarStorage,
+
+		presenceUsecase: presenceUsecase,
 	}
 }
 
@@ -68,7 +83,7 @@ func (uc *userUsecase) CreateUser(ctx context.Context, username, password string
 	user.Password = string(hashedPassword)
 
 	// Сохраняем в БД
-	if err = uc.userRepo.CreateUser(user); err != nil {
+	if err = uc.userRepo.CreateUser(ctx, user); err != nil {
 		return nil, err
 	}
 
@@ -79,18 +94,18 @@ func (uc *userUsecase) CreateUser(ctx context.Context, username, password string
 
 // GetUserByID получает пользователя по ID
 func (uc *userUsecase) GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
-	return uc.userRepo.GetUserByID(id)
+	return uc.userRepo.GetUserByID(ctx, id)
 }
 
 // GetUserByUsername получает пользователя по имени пользователя
 func (uc *userUsecase) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
-	return uc.userRepo.GetUserByUsername(username)
+	return uc.userRepo.GetUserByUsername(ctx, username)
 }
 
 // ValidateCredentials проверяет учетные данные пользователя
 func (uc *userUsecase) ValidateCredentials(ctx context.Context, username, password string) (*models.User, error) {
 	// Получаем пользователя из БД
-	user, err := uc.userRepo.GetUserByUsername(username)
+	user, err := uc.userRepo.GetUserByUsername(ctx, username)
 	if err != nil {
 		return nil, err
 	}
@@ -110,7 +125,7 @@ func (uc *userUsecase) GenerateJWT(user *models.User) (string, error) {
 	claims := &jwt.RegisteredClaims{
 		Subject:   user.ID.String(),
 		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 72)),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(uc.jwtTTL)),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -125,15 +140,26 @@ func (uc *userUsecase) GetOnlineUsers(ctx context.Context) ([]output.OnlineUserI
 	result := make([]output.OnlineUserInfo, 0, len(connectedUserIDs))
 
 	for _, userID := range connectedUserIDs {
+		// Невидимые пользователи выглядят для остальных как offline
+		status, customStatus := uc.presenceUsecase.VisibleStatus(ctx, userID)
+		if status == runtime.PresenceOffline {
+			continue
+		}
+
 		// Получаем информацию о пользователе
-		user, err := uc.userRepo.GetUserByID(userID)
+		user, err := uc.userRepo.GetUserByID(ctx, userID)
 		if err != nil {
 			continue // Пропускаем пользователей, которых не можем найти
 		}
 
 		info := output.OnlineUserInfo{
-			ID:       user.ID.String(),
-			Username: user.Username,
+			ID:          user.ID.String(),
+			Username:    user.Username,
+			DisplayName: user.VisibleName(),
+			AvatarURL:   uc.avatarStorage.URL(user.AvatarKey),
+
+			Status:       string(status),
+			CustomStatus: customStatus,
 		}
 
 		result = append(result, info)
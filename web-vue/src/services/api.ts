@@ -56,7 +56,11 @@ class ApiService {
   }
 
   logout(): void {
-    document.cookie = 'jwt=; expires=Thu, 01 Jan 1970 00:00:00 UTC; path=/;'
+    // cookie с JWT HttpOnly, удалить её может только сервер
+    fetch(`${API_BASE}/auth/logout`, {
+      method: 'POST',
+      credentials: 'include'
+    }).catch((err) => console.error('Logout error:', err))
   }
 
   // Channel endpoints
@@ -17,14 +17,8 @@ class WebRTCService {
         })
         const iceData: ICEServerResponse = await iceResponse.json()
 
-        const iceServers: RTCIceServer[] = [
-            {urls: 'stun:stun.l.google.com:19302'},
-            {
-                urls: iceData.urls,
-                username: iceData.username,
-                credential: iceData.credential
-            }
-        ]
+        // Список STUN/TURN и порядок задает сервер
+        const iceServers: RTCIceServer[] = iceData.ice_servers
 
         // Создаем peer connection
         this.peerConnection = new RTCPeerConnection({iceServers})
@@ -108,8 +102,9 @@ class WebRTCService {
         const answer = await this.peerConnection.createAnswer()
         await this.peerConnection.setLocalDescription(answer)
 
+        // Сервер присылает offer при пересогласовании (видео треки участников)
         wsService.send({
-            type: 'offer',
+            type: 'answer',
             data: {
                 sdp: answer.sdp
             }
@@ -4,6 +4,7 @@ import {env} from '@/config/env'
 export class WebSocketService {
     private ws: WebSocket | null = null
     private pingInterval: number | null = null
+    private lastActivity = Date.now()
     private onMessageCallback: ((message: WSMessage) => void) | null = null
     private onCloseCallback: (() => void) | null = null
 
@@ -61,15 +62,29 @@ export class WebSocketService {
         }
     }
 
+    private markActive = () => {
+        this.lastActivity = Date.now()
+    }
+
     private startPing() {
+        // Сервер переводит пользователя в away по idle_seconds из heartbeat
+        for (const event of ['mousemove', 'keydown', 'pointerdown']) {
+            window.addEventListener(event, this.markActive, {passive: true})
+        }
+
         this.pingInterval = window.setInterval(() => {
             if (this.ws && this.ws.readyState === WebSocket.OPEN) {
-                this.send({type: 'ping'})
+                const idleSeconds = Math.floor((Date.now() - this.lastActivity) / 1000)
+                this.send({type: 'ping', data: {idle_seconds: idleSeconds}})
             }
         }, 30000)
     }
 
     private stopPing() {
+        for (const event of ['mousemove', 'keydown', 'pointerdown']) {
+            window.removeEventListener(event, this.markActive)
+        }
+
         if (this.pingInterval !== null) {
             clearInterval(this.pingInterval)
             this.pingInterval = null
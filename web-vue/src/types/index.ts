@@ -36,6 +36,8 @@ export interface CreateChannelRequest {
 export interface Participant {
   id: string
   username: string
+  display_name: string
+  avatar_url: string
   is_muted: boolean
 }
 
@@ -46,9 +48,11 @@ export interface ICEServer {
 }
 
 export interface ICEServerResponse {
-  urls: string
+  urls: string[]
   username: string
   credential: string
+  ice_servers: RTCIceServer[]
+  expires_at: string
 }
 
 export interface WSMessage {
@@ -68,6 +72,18 @@ export interface AudioDevice {
 export interface OnlineUser {
   id: string
   username: string
+  display_name: string
+  avatar_url: string
   channel_id?: string
   channel_name?: string
 }
+
+export interface UserProfile {
+  id: string
+  username: string
+  display_name: string
+  avatar_url: string
+  bio: string
+  accent_color: string
+  is_bot: boolean
+}